@@ -54,6 +54,25 @@ def is_model_loaded() -> bool:
     return tts_engine is not None
 
 
+class InvalidInputError(Exception):
+    """A request the engine can not synthesize, e.g. reference audio that is not audio."""
+
+
+def check_audio(path: str, name: str):
+    """Make sure a downloaded file can be decoded as audio before it reaches the engine.
+
+    Raises InvalidInputError so a bad upload fails the request with 422 instead of 500.
+    """
+    import librosa
+
+    try:
+        samples, _ = librosa.load(path, sr=None, duration=1.0)
+    except Exception as e:
+        raise InvalidInputError(f"{name} is not a readable audio file: {e}")
+    if len(samples) == 0:
+        raise InvalidInputError(f"{name} contains no audio")
+
+
 async def download_audio(url: str) -> str:
     """Download audio from URL to a temporary file.
 
@@ -184,6 +203,7 @@ async def synthesize(request: TTSRequest):
         if request.reference_audio:
             reference_audio = await download_audio(request.reference_audio)
             temp_files.append(reference_audio)
+            check_audio(reference_audio, "reference_audio")
         else:
             reference_audio = str(settings.MODEL_DIR / settings.DEFAULT_REFERENCE)
 
@@ -192,6 +212,7 @@ async def synthesize(request: TTSRequest):
         if request.emotion_prompt:
             emotion_prompt_path = await download_audio(request.emotion_prompt)
             temp_files.append(emotion_prompt_path)
+            check_audio(emotion_prompt_path, "emotion_prompt")
 
         # Create a temporary file for output
         with tempfile.NamedTemporaryFile(suffix=".wav", delete=False) as tmp_file:
@@ -214,6 +235,13 @@ async def synthesize(request: TTSRequest):
             kwargs["emo_alpha"] = request.emotion_alpha
         if request.use_emotion_text is not None:
             kwargs["use_emo_text"] = request.use_emotion_text
+        if request.emotion_text:
+            kwargs["use_emo_text"] = True
+            kwargs["emo_text"] = request.emotion_text
+
+        # Add advanced generation parameters
+        if request.advanced is not None:
+            kwargs.update(request.advanced.model_dump(exclude_none=True))
 
         # Run inference
         tts_engine.infer(**kwargs)
@@ -236,6 +264,19 @@ async def synthesize(request: TTSRequest):
             status_code=400,
             detail=f"Failed to download audio from URL: {str(e)}"
         )
+    except (InvalidInputError, ValueError) as e:
+        # The request can not be synthesized, trying it on another server would fail the same way
+        logger.warning(f"Invalid synthesis input: {e}")
+        raise HTTPException(
+            status_code=422,
+            detail=f"Invalid input: {str(e)}"
+        )
+    except torch.cuda.OutOfMemoryError as e:
+        logger.error(f"Out of GPU memory: {e}")
+        raise HTTPException(
+            status_code=503,
+            detail="Out of GPU memory, try again later"
+        )
     except Exception as e:
         logger.error(f"Synthesis failed: {e}")
         raise HTTPException(
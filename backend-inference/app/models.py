@@ -4,6 +4,23 @@ from typing import Optional, List
 from pydantic import BaseModel, Field, field_validator
 
 
+class GenerationParams(BaseModel):
+    """Advanced generation parameters passed to IndexTTS2 infer. Unset values use the engine defaults."""
+    temperature: Optional[float] = Field(None, ge=0.1, le=2.0, description="Sampling temperature (default 0.8)")
+    top_p: Optional[float] = Field(None, ge=0.0, le=1.0, description="Nucleus sampling probability (default 0.8)")
+    top_k: Optional[int] = Field(None, ge=0, le=100, description="Top-k sampling, 0 disables (default 30)")
+    num_beams: Optional[int] = Field(None, ge=1, le=10, description="Beam search width (default 3)")
+    repetition_penalty: Optional[float] = Field(None, ge=0.1, le=20.0, description="Repetition penalty (default 10.0)")
+    length_penalty: Optional[float] = Field(None, ge=-2.0, le=2.0, description="Length penalty (default 0.0)")
+    max_mel_tokens: Optional[int] = Field(None, ge=50, le=1815, description="Maximum mel tokens per segment (default 1500)")
+    max_text_tokens_per_segment: Optional[int] = Field(
+        None,
+        ge=20,
+        le=600,
+        description="Maximum text tokens per segment (default 120)"
+    )
+
+
 class TTSRequest(BaseModel):
     """Request model for TTS synthesis."""
     text: str = Field(..., description="Text to synthesize", min_length=1, max_length=5000)
@@ -29,6 +46,15 @@ class TTSRequest(BaseModel):
         None,
         description="Auto-detect emotion from the synthesis text content"
     )
+    emotion_text: Optional[str] = Field(
+        None,
+        max_length=500,
+        description="Emotion description such as 'whispering, scared', used instead of the synthesis text when use_emotion_text is set"
+    )
+    advanced: Optional[GenerationParams] = Field(
+        None,
+        description="Advanced generation parameters (sampling, beam search, token limits)"
+    )
 
     @field_validator('emotion_vector')
     @classmethod
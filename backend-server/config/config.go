@@ -28,14 +28,57 @@ type Config struct {
 	OSSAccessKeySecret string
 	OSSBucketName      string
 
+	// Storage
+	StorageQuotaMB int // Per-user storage quota in MB, 0 means unlimited
+
+	// Data retention
+	RetentionResultDays    int // Days to keep generated audio of free users (no plan, no paid order), 0 means forever
+	RetentionUploadDays    int // Days to keep uploaded audio of free users (no plan, no paid order), 0 means forever
+	RetentionGraceDays     int // Days before soft-deleted files are purged
+	JanitorIntervalMinutes int // Interval between cleanup runs, 0 disables the janitor
+	JanitorBatchSize       int // Max files processed per step in one run
+
 	// CORS
 	CORSOrigins string
 
+	// Share links
+	ShareBaseURL          string // Public base URL for share links, e.g. https://your-domain.com
+	ShareLimitPerLinkHour int    // Password attempts per share link per hour, 0 disables
+	ShareLimitPerIPHour   int    // Password attempts per client IP per hour, 0 disables
+
 	// Inference service
 	InferenceURL     string
 	JWTPrivateKey    string
 	JWTExpireSeconds int
 
+	// Inference backends
+	InferenceURLs                  []string // Several backends to balance across, takes precedence over INFERENCE_URL
+	InferenceHealthIntervalSeconds int      // Interval between /health probes of each backend
+	InferenceRequireGPU            bool     // Treat backends without a GPU as unhealthy
+
+	// Inference circuit breaker
+	InferenceConnectTimeoutSeconds int // Connecting to a backend fails after this long, instead of waiting for the request timeout
+	InferenceBreakerThreshold      int // Consecutive failed inference requests that open the circuit
+	InferenceBreakerOpenSeconds    int // How long the circuit stays open before a trial request, doubled after each failed trial up to 10 times
+	InferenceTaskMaxAttempts       int // A task the inference service failed on this many times fails instead of returning to the queue
+
+	// Fake inference, for development and CI without a GPU
+	InferenceFake          bool // Generate sine tones instead of calling the inference service
+	InferenceFakeLatencyMs int  // Simulated processing time per request
+
+	// Metrics
+	MetricsToken string // Bearer token required by /metrics, empty disables the endpoint
+
+	// Task scheduling
+	WorkerConcurrency          int // Tasks processed at the same time by this server
+	UserMaxProcessingTasks     int // Tasks of one user processed at the same time, 0 means unlimited; plans can override
+	SchedulerFairWindowMinutes int // Recent usage within this window decides which user is served next
+	QueueETADefaultMsPerChar   int // Processing time per character assumed for ETAs until tasks have been measured
+	TaskStuckMinutes           int // Processing tasks started longer ago can be re-queued by administrators
+
+	// Advanced generation parameters
+	AdvancedParamsAllowed []string // Parameters users may set unless their plan says otherwise, "*" for all
+
 	// SMS (Aliyun)
 	SMSAccessKeyID      string
 	SMSAccessKeySecret  string
@@ -43,16 +86,84 @@ type Config struct {
 	SMSTemplateCode     string
 	SMSCodeExpireMinutes int
 	SMSCodeCooldownSeconds int
+	SMSCodeMaxAttempts     int // Wrong guesses before a code is invalidated
+
+	// SMS providers
+	SMSProvider          string // aliyun, tencent, mock or console; empty picks aliyun when configured, console otherwise
+	SMSFallbackProvider  string // Used when the primary provider fails, empty disables failover
+	TencentSMSSecretID   string
+	TencentSMSSecretKey  string
+	TencentSMSRegion     string
+	TencentSMSAppID      string
+	TencentSMSSignName   string
+	TencentSMSTemplateID string
+	SMSMockFile          string // Mock provider appends JSON lines to this file
+	SMSMockURL           string // Mock provider posts JSON to this URL
+
+	// International phone numbers
+	PhoneAllowedCountryCodes []string // Calling codes accepted at login, empty allows all
+	SMSIntlProvider          string   // Provider for numbers outside mainland China, empty uses SMS_PROVIDER
+	SMSIntlTemplateCode      string   // Aliyun template for international numbers
+	TencentSMSIntlTemplateID string   // Tencent Cloud template for international numbers
+
+	// SMS rate limiting
+	RateLimitBackend       string // "memory" (single replica) or "db" (shared across replicas)
+	SMSLimitPerIPHour      int    // Codes per client IP per hour, 0 disables
+	SMSLimitPerPhoneDay    int    // Codes per phone number per day, 0 disables
+	SMSLimitGlobalHour     int    // Codes across all users per hour, 0 disables
+	SMSCaptchaAfterPerHour int    // Codes per client IP per hour before a captcha is required, 0 disables
+
+	// Captcha (siteverify protocol: Cloudflare Turnstile, hCaptcha, reCAPTCHA)
+	CaptchaVerifyURL string
+	CaptchaSecret    string
 
 	// User Auth
-	AuthJWTSecret      string
-	AuthJWTExpireHours int
+	AuthJWTSecret          string
+	AuthAccessTokenMinutes int // Lifetime of access tokens
+	AuthRefreshTokenDays   int // Lifetime of refresh tokens (sessions)
+
+	// Login brute-force protection
+	LoginMaxFailuresPerPhone  int // Failed logins per phone within the window before a lockout
+	LoginMaxFailuresPerIP     int // Failed logins per IP within the window before a lockout
+	LoginFailureWindowMinutes int
+	LoginLockoutMinutes       int // First lockout duration, doubled on each consecutive lockout
+	LoginLockoutMaxMinutes    int
+
+	// Email login
+	MailBackend              string // smtp or capture (logs or writes .eml files, only with GIN_MODE debug or test)
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUsername             string
+	SMTPPassword             string
+	MailFrom                 string // Sender, e.g. "IndexTTS <noreply@your-domain.com>"
+	MailCaptureDir           string // Capture backend writes .eml files here, empty logs them instead
+	EmailLinkBaseURL         string // Frontend URL that emailed links point to
+	EmailTokenExpireMinutes  int
+	EmailLimitPerAddressHour int // Emails per address per hour, 0 disables
+	EmailLimitPerIPHour      int // Emails per client IP per hour, 0 disables
+	PasswordMinLength        int
+
+	// Third-party login (OAuth2 / OIDC)
+	OAuthCallbackBaseURL string // Public URL of this server, providers redirect to {url}/api/v1/auth/oauth/{provider}/callback
+	OAuthFrontendURL     string // Frontend URL the callback redirects back to
+	OAuthLinkByEmail     bool   // Link to an existing user with the same verified email on first login
+	WeChatAppID          string // WeChat Open Platform website application
+	WeChatAppSecret      string
+	OIDCName             string // Provider name used in URLs, e.g. "oidc" or "okta"
+	OIDCDisplayName      string
+	OIDCIssuer           string // Discovery is loaded from {issuer}/.well-known/openid-configuration
+	OIDCClientID         string
+	OIDCClientSecret     string
+	OIDCScopes           []string
+
+	// Access control
+	AdminUsers []string // Phones or emails promoted to admin at startup
 
 	// Credits
 	CreditsInitial    int      // Initial credits for new users
 	CreditsPerTask    int      // Credits deducted per task
 	CreditsPerYuan    int      // Credits per 1 yuan
-	PhoneWhitelist    []string // Phone numbers that don't consume credits
+	PhoneWhitelist    []string // Deprecated: imported into the "whitelist" plan at startup
 
 	// Alipay
 	AlipayAppID        string
@@ -82,11 +193,52 @@ func Load() error {
 		OSSAccessKeyID:     getEnv("OSS_ACCESS_KEY_ID", ""),
 		OSSAccessKeySecret: getEnv("OSS_ACCESS_KEY_SECRET", ""),
 		OSSBucketName:      getEnv("OSS_BUCKET_NAME", ""),
+		StorageQuotaMB:     getEnvInt("STORAGE_QUOTA_MB", 1024),
 		CORSOrigins:        getEnv("CORS_ORIGINS", "*"),
+		ShareBaseURL:       strings.TrimRight(getEnv("SHARE_BASE_URL", ""), "/"),
 		InferenceURL:       getEnv("INFERENCE_URL", "http://localhost:8000"),
 		JWTPrivateKey:      strings.ReplaceAll(getEnv("JWT_PRIVATE_KEY", ""), `\n`, "\n"),
 		JWTExpireSeconds:   getEnvInt("JWT_EXPIRE_SECONDS", 60),
 
+		// Data retention configuration
+		RetentionResultDays:    getEnvInt("RETENTION_RESULT_DAYS", 0),
+		RetentionUploadDays:    getEnvInt("RETENTION_UPLOAD_DAYS", 0),
+		RetentionGraceDays:     getEnvInt("RETENTION_GRACE_DAYS", 7),
+		JanitorIntervalMinutes: getEnvInt("JANITOR_INTERVAL_MINUTES", 60),
+		JanitorBatchSize:       getEnvInt("JANITOR_BATCH_SIZE", 500),
+
+		// Share link password attempts
+		ShareLimitPerLinkHour: getEnvInt("SHARE_LIMIT_PER_LINK_HOUR", 10),
+		ShareLimitPerIPHour:   getEnvInt("SHARE_LIMIT_PER_IP_HOUR", 30),
+
+		// Inference backends configuration
+		InferenceURLs:                  getEnvList("INFERENCE_URLS", ","),
+		InferenceHealthIntervalSeconds: getEnvInt("INFERENCE_HEALTH_INTERVAL_SECONDS", 15),
+		InferenceRequireGPU:            getEnvBool("INFERENCE_REQUIRE_GPU", false),
+
+		// Inference circuit breaker configuration
+		InferenceConnectTimeoutSeconds: getEnvInt("INFERENCE_CONNECT_TIMEOUT_SECONDS", 10),
+		InferenceBreakerThreshold:      getEnvInt("INFERENCE_BREAKER_THRESHOLD", 5),
+		InferenceBreakerOpenSeconds:    getEnvInt("INFERENCE_BREAKER_OPEN_SECONDS", 30),
+		InferenceTaskMaxAttempts:       getEnvInt("INFERENCE_TASK_MAX_ATTEMPTS", 3),
+
+		// Fake inference configuration
+		InferenceFake:          getEnvBool("INFERENCE_FAKE", false),
+		InferenceFakeLatencyMs: getEnvInt("INFERENCE_FAKE_LATENCY_MS", 0),
+
+		// Metrics configuration
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+
+		// Task scheduling configuration
+		WorkerConcurrency:          getEnvInt("WORKER_CONCURRENCY", 1),
+		UserMaxProcessingTasks:     getEnvInt("USER_MAX_PROCESSING_TASKS", 1),
+		SchedulerFairWindowMinutes: getEnvInt("SCHEDULER_FAIR_WINDOW_MINUTES", 60),
+		QueueETADefaultMsPerChar:   getEnvInt("QUEUE_ETA_DEFAULT_MS_PER_CHAR", 100),
+		TaskStuckMinutes:           getEnvInt("TASK_STUCK_MINUTES", 30),
+
+		// Advanced generation parameters configuration
+		AdvancedParamsAllowed: getEnvList("ADVANCED_PARAMS_ALLOWED", ","),
+
 		// SMS configuration
 		SMSAccessKeyID:         getEnv("SMS_ACCESS_KEY_ID", ""),
 		SMSAccessKeySecret:     getEnv("SMS_ACCESS_KEY_SECRET", ""),
@@ -94,10 +246,76 @@ func Load() error {
 		SMSTemplateCode:        getEnv("SMS_TEMPLATE_CODE", ""),
 		SMSCodeExpireMinutes:   getEnvInt("SMS_CODE_EXPIRE_MINUTES", 5),
 		SMSCodeCooldownSeconds: getEnvInt("SMS_CODE_COOLDOWN_SECONDS", 60),
+		SMSCodeMaxAttempts:     getEnvInt("SMS_CODE_MAX_ATTEMPTS", 5),
+
+		// SMS providers
+		SMSProvider:          getEnv("SMS_PROVIDER", ""),
+		SMSFallbackProvider:  getEnv("SMS_FALLBACK_PROVIDER", ""),
+		TencentSMSSecretID:   getEnv("TENCENT_SMS_SECRET_ID", ""),
+		TencentSMSSecretKey:  getEnv("TENCENT_SMS_SECRET_KEY", ""),
+		TencentSMSRegion:     getEnv("TENCENT_SMS_REGION", "ap-guangzhou"),
+		TencentSMSAppID:      getEnv("TENCENT_SMS_APP_ID", ""),
+		TencentSMSSignName:   getEnv("TENCENT_SMS_SIGN_NAME", ""),
+		TencentSMSTemplateID: getEnv("TENCENT_SMS_TEMPLATE_ID", ""),
+		SMSMockFile:          getEnv("SMS_MOCK_FILE", ""),
+		SMSMockURL:           getEnv("SMS_MOCK_URL", ""),
+
+		// International phone numbers
+		PhoneAllowedCountryCodes: getEnvList("PHONE_ALLOWED_COUNTRY_CODES", ","),
+		SMSIntlProvider:          getEnv("SMS_INTL_PROVIDER", ""),
+		SMSIntlTemplateCode:      getEnv("SMS_INTL_TEMPLATE_CODE", ""),
+		TencentSMSIntlTemplateID: getEnv("TENCENT_SMS_INTL_TEMPLATE_ID", ""),
+
+		// SMS rate limiting
+		RateLimitBackend:       getEnv("RATE_LIMIT_BACKEND", "memory"),
+		SMSLimitPerIPHour:      getEnvInt("SMS_LIMIT_PER_IP_HOUR", 10),
+		SMSLimitPerPhoneDay:    getEnvInt("SMS_LIMIT_PER_PHONE_DAY", 10),
+		SMSLimitGlobalHour:     getEnvInt("SMS_LIMIT_GLOBAL_HOUR", 1000),
+		SMSCaptchaAfterPerHour: getEnvInt("SMS_CAPTCHA_AFTER_PER_HOUR", 3),
+		CaptchaVerifyURL:       getEnv("CAPTCHA_VERIFY_URL", ""),
+		CaptchaSecret:          getEnv("CAPTCHA_SECRET", ""),
 
 		// User Auth configuration
-		AuthJWTSecret:      getEnv("AUTH_JWT_SECRET", ""),
-		AuthJWTExpireHours: getEnvInt("AUTH_JWT_EXPIRE_HOURS", 168),
+		AuthJWTSecret:          getEnv("AUTH_JWT_SECRET", ""),
+		AuthAccessTokenMinutes: getEnvInt("AUTH_ACCESS_TOKEN_MINUTES", 30),
+		AuthRefreshTokenDays:   getEnvInt("AUTH_REFRESH_TOKEN_DAYS", 30),
+
+		// Login brute-force protection
+		LoginMaxFailuresPerPhone:  getEnvInt("LOGIN_MAX_FAILURES_PER_PHONE", 10),
+		LoginMaxFailuresPerIP:     getEnvInt("LOGIN_MAX_FAILURES_PER_IP", 30),
+		LoginFailureWindowMinutes: getEnvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+		LoginLockoutMinutes:       getEnvInt("LOGIN_LOCKOUT_MINUTES", 5),
+		LoginLockoutMaxMinutes:    getEnvInt("LOGIN_LOCKOUT_MAX_MINUTES", 1440),
+
+		// Email login
+		MailBackend:              getEnv("MAIL_BACKEND", "capture"),
+		SMTPHost:                 getEnv("SMTP_HOST", ""),
+		SMTPPort:                 getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+		MailFrom:                 getEnv("MAIL_FROM", ""),
+		MailCaptureDir:           getEnv("MAIL_CAPTURE_DIR", ""),
+		EmailLinkBaseURL:         strings.TrimRight(getEnv("EMAIL_LINK_BASE_URL", "http://localhost:3000"), "/"),
+		EmailTokenExpireMinutes:  getEnvInt("EMAIL_TOKEN_EXPIRE_MINUTES", 30),
+		EmailLimitPerAddressHour: getEnvInt("EMAIL_LIMIT_PER_ADDRESS_HOUR", 5),
+		EmailLimitPerIPHour:      getEnvInt("EMAIL_LIMIT_PER_IP_HOUR", 20),
+		PasswordMinLength:        getEnvInt("PASSWORD_MIN_LENGTH", 8),
+
+		// Third-party login (OAuth2 / OIDC)
+		OAuthCallbackBaseURL: strings.TrimRight(getEnv("OAUTH_CALLBACK_BASE_URL", "http://localhost:8080"), "/"),
+		OAuthFrontendURL:     strings.TrimRight(getEnv("OAUTH_FRONTEND_URL", "http://localhost:3000"), "/"),
+		OAuthLinkByEmail:     getEnvBool("OAUTH_LINK_BY_EMAIL", true),
+		WeChatAppID:          getEnv("WECHAT_APP_ID", ""),
+		WeChatAppSecret:      getEnv("WECHAT_APP_SECRET", ""),
+		OIDCName:             getEnv("OIDC_NAME", "oidc"),
+		OIDCDisplayName:      getEnv("OIDC_DISPLAY_NAME", "SSO"),
+		OIDCIssuer:           strings.TrimRight(getEnv("OIDC_ISSUER", ""), "/"),
+		OIDCClientID:         getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:     getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCScopes:           getEnvList("OIDC_SCOPES", " "),
+
+		// Access control configuration
+		AdminUsers: getEnvList("ADMIN_USERS", ","),
 
 		// Credits configuration
 		CreditsInitial: getEnvInt("CREDITS_INITIAL", 30),
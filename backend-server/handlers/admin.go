@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"backend-server/middleware"
+	"backend-server/models"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetUserStatusRequest represents the request to disable or enable a user
+type SetUserStatusRequest struct {
+	Reason string `json:"reason" binding:"max=256"`
+}
+
+// AdjustCreditsRequest represents the request to adjust a user's credits
+type AdjustCreditsRequest struct {
+	Amount int    `json:"amount" binding:"required"` // Positive to add, negative to deduct
+	Reason string `json:"reason" binding:"required,min=1,max=256"`
+}
+
+// respondAdminError maps admin service errors to HTTP responses
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrCannotModifySelf), errors.Is(err, services.ErrInsufficientCredits):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidCreditAmount):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+// bindOptionalJSON binds the request body if there is one
+func bindOptionalJSON(c *gin.Context, obj interface{}) bool {
+	if c.Request.ContentLength == 0 {
+		return true
+	}
+	if err := c.ShouldBindJSON(obj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// parseDateParam parses a query parameter given as a date (2006-01-02) or an RFC 3339 time
+// For dates, endOfDay moves the time to the start of the next day so it can be used as an exclusive bound
+func parseDateParam(c *gin.Context, name string, endOfDay bool) (*time.Time, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid " + name + ", expected YYYY-MM-DD or RFC 3339",
+		})
+		return nil, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, true
+}
+
+// ListUsers searches users by ID, phone, email, status, role and registration date
+// GET /api/v1/admin/users
+func ListUsers(c *gin.Context) {
+	filter := services.UserFilter{
+		Query:    c.Query("q"),
+		Phone:    c.Query("phone"),
+		Status:   models.UserStatus(c.Query("status")),
+		Role:     models.UserRole(c.Query("role")),
+		Page:     1,
+		PageSize: 20,
+	}
+
+	if v := parsePositiveIntValue(c.Query("page")); v > 0 {
+		filter.Page = v
+	}
+	if v := parsePositiveIntValue(c.Query("page_size")); v > 0 && v <= 100 {
+		filter.PageSize = v
+	}
+
+	var ok bool
+	if filter.CreatedFrom, ok = parseDateParam(c, "created_from", false); !ok {
+		return
+	}
+	if filter.CreatedTo, ok = parseDateParam(c, "created_to", true); !ok {
+		return
+	}
+
+	users, total, err := services.ListUsers(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list users",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":     users,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	})
+}
+
+// GetUserDetail shows a user with plan, permissions, task statistics and recent tasks, orders and credit logs
+// GET /api/v1/admin/users/:id
+func GetUserDetail(c *gin.Context) {
+	detail, err := services.GetUserDetail(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// DisableUser disables a user account and revokes its sessions
+// POST /api/v1/admin/users/:id/disable
+func DisableUser(c *gin.Context) {
+	setUserStatus(c, models.UserStatusDisabled)
+}
+
+// EnableUser re-enables a disabled user account
+// POST /api/v1/admin/users/:id/enable
+func EnableUser(c *gin.Context) {
+	setUserStatus(c, models.UserStatusActive)
+}
+
+func setUserStatus(c *gin.Context, status models.UserStatus) {
+	var req SetUserStatusRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	user, previous, err := services.SetUserStatus(middleware.GetUserID(c), c.Param("id"), status)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetUser,
+		gin.H{"status": previous}, gin.H{"status": user.Status})
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AdjustUserCredits adds or deducts credits with a reason, recorded as an "admin_adjust" credit log
+// POST /api/v1/admin/users/:id/credits
+func AdjustUserCredits(c *gin.Context) {
+	var req AdjustCreditsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	creditLog, err := services.AdjustCredits(middleware.GetUserID(c), c.Param("id"), req.Amount, req.Reason)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetUser,
+		gin.H{"credits": creditLog.Balance - creditLog.Amount}, gin.H{"credits": creditLog.Balance})
+
+	c.JSON(http.StatusOK, creditLog)
+}
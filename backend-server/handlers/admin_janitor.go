@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"backend-server/config"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJanitorStatus returns the retention janitor's schedule and the report of its last run
+// GET /api/v1/admin/janitor
+func GetJanitorStatus(c *gin.Context) {
+	j := services.GetJanitor()
+	if j == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "janitor is not running in this process",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":          config.Cfg.JanitorIntervalMinutes > 0,
+		"interval_minutes": config.Cfg.JanitorIntervalMinutes,
+		"last_report":      j.LastReport(),
+	})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"backend-server/middleware"
+	"backend-server/models"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FailTaskRequest represents the request to force-fail a task
+type FailTaskRequest struct {
+	Reason string `json:"reason" binding:"max=256"`
+	Refund bool   `json:"refund"` // Return the credits consumed by the task
+}
+
+// CancelTaskRequest represents the request to cancel a pending task
+type CancelTaskRequest struct {
+	Refund bool `json:"refund"`
+}
+
+// SetPriorityRequest represents the request to change the priority of a pending task
+type SetPriorityRequest struct {
+	Priority *int `json:"priority" binding:"required,min=-100,max=100"`
+}
+
+// respondTaskAdminError maps task operation errors to HTTP responses
+func respondTaskAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrTaskInvalidState):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrTaskCreditsNeeded):
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrWorkerUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+// auditTaskChange records the status and priority of a task before and after an admin operation
+func auditTaskChange(c *gin.Context, before, after *models.Task) {
+	middleware.SetAuditChange(c, services.AuditTargetTask,
+		gin.H{"status": before.Status, "priority": before.Priority},
+		gin.H{"status": after.Status, "priority": after.Priority})
+}
+
+// ListAllTasks lists tasks of all users by status, user and age
+// GET /api/v1/admin/tasks
+func ListAllTasks(c *gin.Context) {
+	filter := services.TaskFilter{
+		Status:   models.TaskStatus(c.Query("status")),
+		UserID:   c.Query("user_id"),
+		Page:     1,
+		PageSize: 20,
+	}
+
+	if v := parsePositiveIntValue(c.Query("page")); v > 0 {
+		filter.Page = v
+	}
+	if v := parsePositiveIntValue(c.Query("page_size")); v > 0 && v <= 100 {
+		filter.PageSize = v
+	}
+	if v := parsePositiveIntValue(c.Query("older_than_minutes")); v > 0 {
+		filter.OlderThan = time.Duration(v) * time.Minute
+	}
+
+	var ok bool
+	if filter.CreatedFrom, ok = parseDateParam(c, "created_from", false); !ok {
+		return
+	}
+	if filter.CreatedTo, ok = parseDateParam(c, "created_to", true); !ok {
+		return
+	}
+
+	tasks, total, err := services.ListAllTasks(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list tasks",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tasks":     tasks,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	})
+}
+
+// GetQueueStats returns queue depth, average wait and processing time, and the worker state
+// GET /api/v1/admin/queue
+func GetQueueStats(c *gin.Context) {
+	windowHours := 24
+	if v := parsePositiveIntValue(c.Query("window_hours")); v > 0 && v <= 24*30 {
+		windowHours = v
+	}
+
+	stats, err := services.GetQueueStats(windowHours)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load queue statistics",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// RequeueTask puts a failed, cancelled or stuck task back into the queue
+// POST /api/v1/admin/tasks/:id/requeue
+func RequeueTask(c *gin.Context) {
+	before, err := services.GetTaskByID(c.Param("id"))
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+
+	task, err := services.RequeueTask(c.Param("id"))
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+	auditTaskChange(c, before, task)
+
+	c.JSON(http.StatusOK, task)
+}
+
+// FailTask marks a pending or processing task as failed
+// POST /api/v1/admin/tasks/:id/fail
+func FailTask(c *gin.Context) {
+	var req FailTaskRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	before, err := services.GetTaskByID(c.Param("id"))
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+
+	task, err := services.FailTask(c.Param("id"), req.Reason, req.Refund)
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+	auditTaskChange(c, before, task)
+
+	c.JSON(http.StatusOK, task)
+}
+
+// CancelTask cancels a pending task
+// POST /api/v1/admin/tasks/:id/cancel
+func CancelTask(c *gin.Context) {
+	var req CancelTaskRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	before, err := services.GetTaskByID(c.Param("id"))
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+
+	task, err := services.CancelTask(c.Param("id"), req.Refund)
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+	auditTaskChange(c, before, task)
+
+	c.JSON(http.StatusOK, task)
+}
+
+// SetTaskPriority changes the priority of a pending task, higher runs first
+// PUT /api/v1/admin/tasks/:id/priority
+func SetTaskPriority(c *gin.Context) {
+	var req SetPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	before, err := services.GetTaskByID(c.Param("id"))
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+
+	task, err := services.SetTaskPriority(c.Param("id"), *req.Priority)
+	if err != nil {
+		respondTaskAdminError(c, err)
+		return
+	}
+	auditTaskChange(c, before, task)
+
+	c.JSON(http.StatusOK, task)
+}
+
+// PauseWorker stops the worker from picking up new tasks, e.g. during inference maintenance
+// POST /api/v1/admin/worker/pause
+func PauseWorker(c *gin.Context) {
+	w := services.GetWorker()
+	if w == nil {
+		respondTaskAdminError(c, services.ErrWorkerUnavailable)
+		return
+	}
+
+	w.Pause()
+	c.JSON(http.StatusOK, w.Status())
+}
+
+// ResumeWorker lets the worker pick up tasks again
+// POST /api/v1/admin/worker/resume
+func ResumeWorker(c *gin.Context) {
+	w := services.GetWorker()
+	if w == nil {
+		respondTaskAdminError(c, services.ErrWorkerUnavailable)
+		return
+	}
+
+	w.Resume()
+	c.JSON(http.StatusOK, w.Status())
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEvents queries the audit log by actor, action, target and time range
+// GET /api/v1/admin/audit
+func ListAuditEvents(c *gin.Context) {
+	filter := services.AuditFilter{
+		ActorID:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Page:       1,
+		PageSize:   20,
+	}
+
+	if v := parsePositiveIntValue(c.Query("page")); v > 0 {
+		filter.Page = v
+	}
+	if v := parsePositiveIntValue(c.Query("page_size")); v > 0 && v <= 100 {
+		filter.PageSize = v
+	}
+
+	var ok bool
+	if filter.From, ok = parseDateParam(c, "from", false); !ok {
+		return
+	}
+	if filter.To, ok = parseDateParam(c, "to", true); !ok {
+		return
+	}
+
+	events, total, err := services.ListAuditEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list audit events",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":    events,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	})
+}
+
+// VerifyAuditChain checks that no audit event has been changed, removed or reordered
+// GET /api/v1/admin/audit/verify
+func VerifyAuditChain(c *gin.Context) {
+	result, err := services.VerifyAuditChain()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify audit log",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
-	"regexp"
+	"strconv"
+	"time"
 
 	"backend-server/middleware"
 	"backend-server/models"
@@ -13,27 +16,83 @@ import (
 
 // SendCodeRequest represents the request body for sending verification code
 type SendCodeRequest struct {
-	Phone string `json:"phone" binding:"required"`
+	CountryCode  string `json:"country_code"` // Calling code such as "86" or "+852", defaults to 86
+	Phone        string `json:"phone" binding:"required"`
+	CaptchaToken string `json:"captcha_token"` // Required once the client crosses the captcha threshold
 }
 
 // LoginRequest represents the request body for login
 type LoginRequest struct {
-	Phone string `json:"phone" binding:"required"`
-	Code  string `json:"code" binding:"required"`
+	CountryCode string `json:"country_code"`
+	Phone       string `json:"phone" binding:"required"`
+	Code        string `json:"code" binding:"required"`
 }
 
 // LoginResponse represents the response body for successful login
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	*services.TokenPair
+	User *models.User `json:"user"`
 }
 
-// validatePhone validates Chinese phone number format
-func validatePhone(phone string) bool {
-	// Chinese mobile phone number: 11 digits starting with 1
-	pattern := `^1[3-9]\d{9}$`
-	matched, _ := regexp.MatchString(pattern, phone)
-	return matched
+// RefreshRequest represents the request body for refreshing an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// clientInfo extracts the device information of the request
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// normalizePhone validates the phone number of a request and returns it in E.164 format
+// Writes a 400 response and returns false if the number is invalid for its region
+func normalizePhone(c *gin.Context, countryCode, phone string) (string, bool) {
+	normalized, err := services.NormalizePhone(countryCode, phone)
+	if err != nil {
+		msg := "Invalid phone number format"
+		if !errors.Is(err, services.ErrInvalidPhone) {
+			msg = err.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return "", false
+	}
+	return normalized, true
+}
+
+// respondAuthError writes an authentication error, including structured details when available
+func respondAuthError(c *gin.Context, err error) {
+	var authErr *services.AuthError
+	if !errors.As(err, &authErr) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusUnauthorized
+	if authErr.Code == services.AuthErrCaptchaRequired {
+		status = http.StatusPreconditionRequired
+	}
+	body := gin.H{
+		"error": authErr.Message,
+		"code":  authErr.Code,
+	}
+	if authErr.RetryAfter > 0 {
+		status = http.StatusTooManyRequests
+		retryAfter := int(math.Ceil(authErr.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		body["retry_after"] = retryAfter
+	}
+	if authErr.AttemptsRemaining >= 0 {
+		body["attempts_remaining"] = authErr.AttemptsRemaining
+	}
+
+	c.JSON(status, body)
 }
 
 // SendCode handles sending verification code
@@ -47,16 +106,19 @@ func SendCode(c *gin.Context) {
 		return
 	}
 
-	// Validate phone number format
-	if !validatePhone(req.Phone) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid phone number format",
-		})
+	// Validate phone number format for its region
+	phone, ok := normalizePhone(c, req.CountryCode, req.Phone)
+	if !ok {
 		return
 	}
 
-	// Send verification code
-	_, err := services.SendVerificationCode(req.Phone, models.CodePurposeLogin)
+	// Send verification code, within the per-IP, per-phone and global sending limits
+	_, err := services.SendVerificationCode(phone, models.CodePurposeLogin, c.ClientIP(), req.CaptchaToken)
+	var authErr *services.AuthError
+	if errors.As(err, &authErr) {
+		respondAuthError(c, err)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -80,11 +142,9 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Validate phone number format
-	if !validatePhone(req.Phone) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid phone number format",
-		})
+	// Validate phone number format for its region
+	phone, ok := normalizePhone(c, req.CountryCode, req.Phone)
+	if !ok {
 		return
 	}
 
@@ -97,17 +157,15 @@ func Login(c *gin.Context) {
 	}
 
 	// Login with phone and code
-	user, token, err := services.LoginWithPhone(req.Phone, req.Code)
+	user, tokens, err := services.LoginWithPhone(phone, req.Code, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
+		respondAuthError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
+		TokenPair: tokens,
+		User:      user,
 	})
 }
 
@@ -132,3 +190,409 @@ func GetCurrentUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+// POST /api/v1/auth/refresh
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	user, tokens, err := services.RefreshSession(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: tokens,
+		User:      user,
+	})
+}
+
+// Logout revokes the current session
+// POST /api/v1/auth/logout
+func Logout(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	if err := services.RevokeSession(userID, middleware.GetSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out successfully",
+	})
+}
+
+// SessionResponse represents a login session in API responses
+type SessionResponse struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	Current    bool       `json:"current"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ListSessions lists the user's active sessions (devices)
+// GET /api/v1/auth/sessions
+func ListSessions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	sessions, err := services.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list sessions",
+		})
+		return
+	}
+
+	currentID := middleware.GetSessionID(c)
+	items := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		items[i] = SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			Current:    session.ID == currentID,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			CreatedAt:  session.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": items,
+	})
+}
+
+// RevokeSession revokes one of the user's sessions
+// DELETE /api/v1/auth/sessions/:id
+func RevokeSession(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	if err := services.RevokeSession(userID, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Session not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeAllSessions revokes all of the user's sessions, including the current one
+// DELETE /api/v1/auth/sessions
+func RevokeAllSessions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	if err := services.RevokeAllSessions(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "All sessions revoked successfully",
+	})
+}
+
+// EmailRegisterRequest represents the request body for email registration
+type EmailRegisterRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// EmailLoginRequest represents the request body for email + password login
+type EmailLoginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// EmailRequest represents a request body carrying only an email address
+type EmailRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// EmailTokenRequest represents the request body for consuming an emailed link
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResetPasswordRequest represents the request body for setting a new password from a reset link
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// BindEmailRequest represents the request body for binding an email to the current user
+type BindEmailRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password"` // Optional, enables password login
+}
+
+// BindPhoneRequest represents the request body for binding a phone to the current user
+type BindPhoneRequest struct {
+	CountryCode string `json:"country_code"`
+	Phone       string `json:"phone" binding:"required"`
+	Code        string `json:"code" binding:"required"`
+}
+
+// respondIdentityError writes the error of an email or identity binding request
+func respondIdentityError(c *gin.Context, err error) {
+	var authErr *services.AuthError
+	switch {
+	case errors.As(err, &authErr):
+		respondAuthError(c, err)
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrPhoneTaken):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+// RegisterEmail starts an email + password registration and mails a verification link
+// POST /api/v1/auth/email/register
+func RegisterEmail(c *gin.Context) {
+	var req EmailRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := services.RegisterWithEmail(req.Email, req.Password, c.ClientIP()); err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent, please check your inbox",
+	})
+}
+
+// LoginEmail handles login with email and password
+// POST /api/v1/auth/email/login
+func LoginEmail(c *gin.Context) {
+	var req EmailLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	user, tokens, err := services.LoginWithEmail(req.Email, req.Password, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEmail) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		respondAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: tokens,
+		User:      user,
+	})
+}
+
+// SendMagicLink mails a passwordless login link
+// POST /api/v1/auth/email/magic-link
+func SendMagicLink(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := services.RequestMagicLink(req.Email, c.ClientIP()); err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Login link sent, please check your inbox",
+	})
+}
+
+// VerifyEmail consumes a registration, bind or magic link token and logs the user in
+// POST /api/v1/auth/email/verify
+func VerifyEmail(c *gin.Context) {
+	var req EmailTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	user, tokens, err := services.VerifyEmailToken(req.Token, clientInfo(c))
+	if err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: tokens,
+		User:      user,
+	})
+}
+
+// ForgotPassword mails a password reset link
+// Always succeeds for well-formed emails so that registered accounts are not revealed
+// POST /api/v1/auth/password/forgot
+func ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := services.RequestPasswordReset(req.Email, c.ClientIP()); err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email is registered, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password from a reset link and signs out all sessions
+// POST /api/v1/auth/password/reset
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := services.ResetPassword(req.Token, req.Password); err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password reset successfully, please log in again",
+	})
+}
+
+// BindEmail mails a verification link that binds an email to the current user
+// POST /api/v1/auth/email/bind
+func BindEmail(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	var req BindEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := services.RequestEmailBind(userID, req.Email, req.Password, c.ClientIP()); err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent, please check your inbox",
+	})
+}
+
+// BindPhone binds a phone number to the current user using an SMS code from /auth/send-code
+// POST /api/v1/auth/phone/bind
+func BindPhone(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	var req BindPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	phone, ok := normalizePhone(c, req.CountryCode, req.Phone)
+	if !ok {
+		return
+	}
+
+	user, err := services.BindPhone(userID, phone, req.Code, c.ClientIP())
+	if err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"backend-server/middleware"
 	"backend-server/models"
@@ -60,6 +63,7 @@ func GetFileURL(c *gin.Context) {
 }
 
 // GetFile proxies file content from OSS with 12-hour cache
+// Supports single byte-range requests and conditional requests via ETag/Last-Modified
 func GetFile(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
@@ -79,6 +83,59 @@ func GetFile(c *gin.Context) {
 		return
 	}
 
+	// Cache for 12 hours (43200 seconds)
+	serveFileContent(c, &file, "public, max-age=43200", "inline")
+}
+
+// serveFileContent streams a file from OSS, honoring Range and conditional request headers
+func serveFileContent(c *gin.Context, file *models.File, cacheControl, disposition string) {
+	etag := fileETag(file)
+	lastModified := file.UpdatedAt.UTC().Truncate(time.Second)
+
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	c.Header("Accept-Ranges", "bytes")
+
+	if notModified(c.Request, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("Content-Disposition", disposition+"; filename=\""+file.Filename+"\"")
+
+	// Ignore Range if If-Range does not match the current representation
+	rangeHeader := c.GetHeader("Range")
+	if ifRange := c.GetHeader("If-Range"); ifRange != "" && ifRange != etag && ifRange != lastModified.Format(http.TimeFormat) {
+		rangeHeader = ""
+	}
+
+	if rangeHeader != "" {
+		start, end, ok := parseByteRange(rangeHeader, file.Size)
+		if !ok {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", file.Size))
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{
+				"error": "Requested range not satisfiable",
+			})
+			return
+		}
+		if start >= 0 {
+			reader, err := services.GetObjectRange(file.OSSKey, start, end)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to get file: " + err.Error(),
+				})
+				return
+			}
+			defer reader.Close()
+
+			c.DataFromReader(http.StatusPartialContent, end-start+1, file.ContentType, reader, map[string]string{
+				"Content-Range": fmt.Sprintf("bytes %d-%d/%d", start, end, file.Size),
+			})
+			return
+		}
+	}
+
 	// Get file content from OSS
 	reader, err := services.GetObject(file.OSSKey)
 	if err != nil {
@@ -89,14 +146,94 @@ func GetFile(c *gin.Context) {
 	}
 	defer reader.Close()
 
-	// Set cache headers (12 hours = 43200 seconds)
-	c.Header("Cache-Control", "public, max-age=43200")
-	c.Header("Content-Disposition", "inline; filename=\""+file.Filename+"\"")
-
 	// Stream file content to response
 	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, reader, nil)
 }
 
+// fileETag builds a strong ETag for a file; stored content never changes for a file ID
+func fileETag(file *models.File) string {
+	return fmt.Sprintf("\"%s-%x\"", file.ID, file.UpdatedAt.Unix())
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since for GET requests
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		// If-Modified-Since is ignored when If-None-Match is present
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseByteRange parses a Range header for a resource of the given size
+// Returns start=-1 with ok=true when the header should be ignored (unsupported unit or multiple ranges),
+// and ok=false when the range is not satisfiable
+func parseByteRange(header string, size int64) (start, end int64, ok bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return -1, -1, true
+	}
+	spec := strings.TrimSpace(strings.TrimPrefix(header, prefix))
+	if strings.Contains(spec, ",") {
+		// Multipart ranges are not supported, serve the full content instead
+		return -1, -1, true
+	}
+
+	dash := strings.Index(spec, "-")
+	if dash < 0 {
+		return -1, -1, true
+	}
+	startStr, endStr := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+
+	if startStr == "" {
+		// Suffix range: last N bytes
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n < 0 {
+			return -1, -1, true
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return -1, -1, true
+	}
+	if start >= size {
+		return 0, 0, false
+	}
+
+	end = size - 1
+	if endStr != "" {
+		e, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || e < start {
+			return -1, -1, true
+		}
+		if e < end {
+			end = e
+		}
+	}
+
+	return start, end, true
+}
+
 // GetFileMetadata retrieves file metadata by ID (without content)
 func GetFileMetadata(c *gin.Context) {
 	userID := middleware.GetUserID(c)
@@ -120,6 +257,7 @@ func GetFileMetadata(c *gin.Context) {
 	// Return metadata without sensitive OSS key
 	c.JSON(http.StatusOK, gin.H{
 		"id":           file.ID,
+		"kind":         file.Kind,
 		"filename":     file.Filename,
 		"content_type": file.ContentType,
 		"size":         file.Size,
@@ -127,3 +265,211 @@ func GetFileMetadata(c *gin.Context) {
 		"updated_at":   file.UpdatedAt,
 	})
 }
+
+// FileListItem represents a file item in list response (without sensitive OSS key)
+type FileListItem struct {
+	ID          string          `json:"id"`
+	Kind        models.FileKind `json:"kind"`
+	Filename    string          `json:"filename"`
+	ContentType string          `json:"content_type"`
+	Size        int64           `json:"size"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// ListFiles lists the user's files with pagination and optional kind filter
+// GET /api/v1/files
+func ListFiles(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	page := 1
+	pageSize := 20
+
+	if p := c.Query("page"); p != "" {
+		if v := parsePositiveIntValue(p); v > 0 {
+			page = v
+		}
+	}
+
+	if ps := c.Query("page_size"); ps != "" {
+		if v := parsePositiveIntValue(ps); v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+
+	query := models.DB.Model(&models.File{}).Where("user_id = ?", userID)
+	if kind := c.Query("kind"); kind != "" {
+		switch models.FileKind(kind) {
+		case models.FileKindReference, models.FileKindEmotionPrompt, models.FileKindResult:
+			query = query.Where("kind = ?", kind)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid kind. Allowed: reference, emotion_prompt, result",
+			})
+			return
+		}
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var files []models.File
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list files",
+		})
+		return
+	}
+
+	items := make([]FileListItem, len(files))
+	for i, file := range files {
+		items[i] = FileListItem{
+			ID:          file.ID,
+			Kind:        file.Kind,
+			Filename:    file.Filename,
+			ContentType: file.ContentType,
+			Size:        file.Size,
+			CreatedAt:   file.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			UpdatedAt:   file.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// RenameFileRequest represents the request to rename a file
+type RenameFileRequest struct {
+	Filename string `json:"filename" binding:"required,min=1,max=255"`
+}
+
+// RenameFile changes the display name of a file
+// PATCH /api/v1/files/:id
+func RenameFile(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	var req RenameFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	filename := strings.TrimSpace(req.Filename)
+	if filename == "" || strings.ContainsAny(filename, "/\\\"") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid filename",
+		})
+		return
+	}
+
+	id := c.Param("id")
+
+	var file models.File
+	if err := models.DB.First(&file, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	if err := models.DB.Model(&file).Update("filename", filename).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to rename file: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       file.ID,
+		"filename": filename,
+	})
+}
+
+// DeleteFile soft-deletes a file
+// DELETE /api/v1/files/:id
+func DeleteFile(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	id := c.Param("id")
+
+	var file models.File
+	if err := models.DB.First(&file, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	// Files used by unfinished tasks must stay available to the worker
+	inUse, err := services.IsFileInUse(file.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check file usage",
+		})
+		return
+	}
+	if inUse {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "File is used by a pending task",
+		})
+		return
+	}
+
+	if err := models.DB.Delete(&file).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete file: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "File deleted successfully",
+	})
+}
+
+// GetStorageUsage returns the user's storage usage and quota
+// GET /api/v1/files/usage
+func GetStorageUsage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	usage, err := services.GetStorageUsage(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get storage usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend-server/config"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health reports whether the server is up and whether tasks can currently be processed
+// The status is "degraded" while the inference circuit is not closed or no backend is healthy;
+// the response code stays 200 since the API itself keeps serving
+// GET /health
+func Health(c *gin.Context) {
+	circuit := services.GetCircuitStatus()
+
+	healthy := 0
+	for _, b := range services.ListInferenceBackends() {
+		if b.Enabled && b.Healthy {
+			healthy++
+		}
+	}
+
+	status := "ok"
+	if circuit.State != services.CircuitClosed || (healthy == 0 && !config.Cfg.InferenceFake) {
+		status = "degraded"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": status,
+		"inference": gin.H{
+			"circuit":          circuit.State,
+			"retry_at":         circuit.RetryAt,
+			"healthy_backends": healthy,
+		},
+	})
+}
+
+// metricsWriter formats metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	b strings.Builder
+}
+
+func (w *metricsWriter) header(name, kind, help string) {
+	fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (w *metricsWriter) value(name string, v float64, labels ...string) {
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		w.b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.b.WriteByte(',')
+			}
+			fmt.Fprintf(&w.b, "%s=%s", labels[i], strconv.Quote(labels[i+1]))
+		}
+		w.b.WriteByte('}')
+	}
+	w.b.WriteByte(' ')
+	w.b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	w.b.WriteByte('\n')
+}
+
+func (w *metricsWriter) metric(name, kind, help string, v float64) {
+	w.header(name, kind, help)
+	w.value(name, v)
+}
+
+func boolMetric(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// circuitStateValue maps circuit states to gauge values
+var circuitStateValue = map[services.CircuitState]float64{
+	services.CircuitClosed:   0,
+	services.CircuitHalfOpen: 1,
+	services.CircuitOpen:     2,
+}
+
+// Metrics exposes the inference circuit, backends, worker and queue in the Prometheus text format
+// Requires "Authorization: Bearer <METRICS_TOKEN>"; without METRICS_TOKEN the endpoint is disabled,
+// since the metrics name internal inference backend URLs
+// GET /metrics
+func Metrics(c *gin.Context) {
+	token := config.Cfg.MetricsToken
+	if token == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Metrics are disabled, set METRICS_TOKEN to enable them",
+		})
+		return
+	}
+	got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid metrics token",
+		})
+		return
+	}
+
+	var w metricsWriter
+
+	circuit := services.GetCircuitStatus()
+	w.metric("indextts_inference_circuit_state", "gauge",
+		"Inference circuit breaker state (0 closed, 1 half-open, 2 open)", circuitStateValue[circuit.State])
+	w.metric("indextts_inference_circuit_consecutive_failures", "gauge",
+		"Consecutive failed inference requests", float64(circuit.ConsecutiveFailures))
+	w.metric("indextts_inference_circuit_opens_total", "counter",
+		"Times the inference circuit opened", float64(circuit.Opens))
+	w.header("indextts_inference_requests_total", "counter", "Inference requests by result")
+	w.value("indextts_inference_requests_total", float64(circuit.Successes), "result", "success")
+	w.value("indextts_inference_requests_total", float64(circuit.Failures), "result", "failure")
+	w.value("indextts_inference_requests_total", float64(circuit.Rejected), "result", "rejected")
+
+	backends := services.ListInferenceBackends()
+	w.header("indextts_inference_backend_up", "gauge", "Whether an inference backend is enabled and healthy")
+	for _, b := range backends {
+		w.value("indextts_inference_backend_up", boolMetric(b.Enabled && b.Healthy), "backend", b.Name, "url", b.URL)
+	}
+	w.header("indextts_inference_backend_inflight", "gauge", "Inference requests in progress per backend")
+	for _, b := range backends {
+		w.value("indextts_inference_backend_inflight", float64(b.Inflight), "backend", b.Name, "url", b.URL)
+	}
+	w.header("indextts_inference_backend_requests_total", "counter", "Inference requests sent per backend")
+	for _, b := range backends {
+		w.value("indextts_inference_backend_requests_total", float64(b.Served), "backend", b.Name, "url", b.URL)
+	}
+	w.header("indextts_inference_backend_failures_total", "counter", "Failed inference requests per backend")
+	for _, b := range backends {
+		w.value("indextts_inference_backend_failures_total", float64(b.Failures), "backend", b.Name, "url", b.URL)
+	}
+
+	if worker := services.GetWorker(); worker != nil {
+		status := worker.Status()
+		w.metric("indextts_worker_paused", "gauge", "Whether the worker is paused", boolMetric(status.Paused))
+		w.metric("indextts_worker_concurrency", "gauge", "Tasks the worker processes at the same time", float64(status.Concurrency))
+		w.metric("indextts_worker_current_tasks", "gauge", "Tasks the worker is processing", float64(len(status.CurrentTasks)))
+	}
+
+	if stats, err := services.GetPublicQueueStats(); err == nil {
+		w.header("indextts_queue_tasks", "gauge", "Tasks by status across all servers")
+		w.value("indextts_queue_tasks", float64(stats.Pending), "status", "pending")
+		w.value("indextts_queue_tasks", float64(stats.Processing), "status", "processing")
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(w.b.String()))
+}
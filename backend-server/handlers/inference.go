@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"backend-server/middleware"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InferenceBackendRequest represents the request to register or update an inference backend
+type InferenceBackendRequest struct {
+	Name    string `json:"name" binding:"max=64"` // Defaults to the host of the URL
+	URL     string `json:"url" binding:"required,max=255"`
+	Enabled *bool  `json:"enabled"` // Defaults to true
+}
+
+func (r *InferenceBackendRequest) input() services.InferenceBackendInput {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	return services.InferenceBackendInput{
+		Name:    r.Name,
+		URL:     r.URL,
+		Enabled: enabled,
+	}
+}
+
+// respondInferenceBackendError maps inference backend errors to HTTP responses
+func respondInferenceBackendError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInferenceBackendNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidInferenceURL):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInferenceBackendURLTaken):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+// ListInferenceBackends lists the registered inference backends with their health and load
+// GET /api/v1/admin/inference/backends
+func ListInferenceBackends(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"backends": services.ListInferenceBackends(),
+	})
+}
+
+// CreateInferenceBackend registers an inference backend; it receives tasks once its health check passes
+// POST /api/v1/admin/inference/backends
+func CreateInferenceBackend(c *gin.Context) {
+	var req InferenceBackendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	backend, err := services.CreateInferenceBackend(req.input())
+	if err != nil {
+		respondInferenceBackendError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetBackend, nil, backend.InferenceBackend)
+
+	c.JSON(http.StatusCreated, backend)
+}
+
+// UpdateInferenceBackend changes the name or URL of an inference backend, or takes it out of rotation
+// PUT /api/v1/admin/inference/backends/:id
+func UpdateInferenceBackend(c *gin.Context) {
+	var req InferenceBackendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	before, err := services.GetInferenceBackend(c.Param("id"))
+	if err != nil {
+		respondInferenceBackendError(c, err)
+		return
+	}
+
+	backend, err := services.UpdateInferenceBackend(c.Param("id"), req.input())
+	if err != nil {
+		respondInferenceBackendError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetBackend, before, backend.InferenceBackend)
+
+	c.JSON(http.StatusOK, backend)
+}
+
+// DeleteInferenceBackend removes an inference backend; requests in progress on it are finished
+// DELETE /api/v1/admin/inference/backends/:id
+func DeleteInferenceBackend(c *gin.Context) {
+	before, err := services.GetInferenceBackend(c.Param("id"))
+	if err != nil {
+		respondInferenceBackendError(c, err)
+		return
+	}
+
+	if err := services.DeleteInferenceBackend(c.Param("id")); err != nil {
+		respondInferenceBackendError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetBackend, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Inference backend deleted",
+	})
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"backend-server/config"
+	"backend-server/middleware"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthExchangeRequest represents the request body for redeeming a login callback code
+type OAuthExchangeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// OAuthProviderItem represents a third-party login provider in API responses
+type OAuthProviderItem struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// oauthBrowserCookie holds the key binding OAuth attempts to the browser that started them
+const oauthBrowserCookie = "oauth_browser"
+
+// oauthBrowserKey returns the browser's OAuth binding key, issuing a new cookie if it has none
+// The cookie is HttpOnly and SameSite=Lax, so it is sent on the provider's top-level redirect
+// to the callback but cannot be read by scripts or attached by other sites' requests
+func oauthBrowserKey(c *gin.Context) (string, error) {
+	key, err := c.Cookie(oauthBrowserCookie)
+	if err != nil || len(key) < 32 {
+		if key, err = services.NewOAuthBrowserKey(); err != nil {
+			return "", err
+		}
+	}
+
+	secure := strings.HasPrefix(config.Cfg.OAuthCallbackBaseURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthBrowserCookie, key, int(services.OAuthStateTTL.Seconds()), "/api/v1/auth/oauth", "", secure, true)
+	return key, nil
+}
+
+// startOAuth begins an authorization attempt bound to the requesting browser
+func startOAuth(c *gin.Context, linkUserID string) (string, bool) {
+	browserKey, err := oauthBrowserKey(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return "", false
+	}
+
+	authorizeURL, err := services.StartOAuth(c.Request.Context(), c.Param("provider"), linkUserID, browserKey)
+	if err != nil {
+		if errors.Is(err, services.ErrOAuthProviderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return "", false
+		}
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": err.Error(),
+		})
+		return "", false
+	}
+	return authorizeURL, true
+}
+
+// redirectToFrontend sends the browser back to the frontend with the given query parameters
+func redirectToFrontend(c *gin.Context, params url.Values) {
+	c.Redirect(http.StatusFound, config.Cfg.OAuthFrontendURL+"/?"+params.Encode())
+}
+
+// ListOAuthProviders lists the configured third-party login providers
+// GET /api/v1/auth/oauth/providers
+func ListOAuthProviders(c *gin.Context) {
+	names := services.OAuthProviderNames()
+	items := make([]OAuthProviderItem, 0, len(names))
+	for _, name := range names {
+		p, _ := services.GetOAuthProvider(name)
+		items = append(items, OAuthProviderItem{
+			Name:        p.Name(),
+			DisplayName: p.DisplayName(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"providers": items,
+	})
+}
+
+// StartOAuth redirects the browser to the provider's login page
+// GET /api/v1/auth/oauth/:provider/start
+func StartOAuth(c *gin.Context) {
+	authorizeURL, ok := startOAuth(c, "")
+	if !ok {
+		return
+	}
+
+	c.Redirect(http.StatusFound, authorizeURL)
+}
+
+// OAuthCallback handles the provider redirect and sends the browser back to the frontend
+// On login the frontend receives a one-time oauth_code to redeem at /auth/oauth/exchange,
+// so that tokens never appear in URLs
+// GET /api/v1/auth/oauth/:provider/callback
+func OAuthCallback(c *gin.Context) {
+	provider := c.Param("provider")
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		redirectToFrontend(c, url.Values{"oauth_error": {"login was cancelled or denied"}})
+		return
+	}
+
+	browserKey, _ := c.Cookie(oauthBrowserCookie)
+	result, err := services.HandleOAuthCallback(c.Request.Context(), provider, c.Query("code"), c.Query("state"), browserKey)
+	if err != nil {
+		redirectToFrontend(c, url.Values{"oauth_error": {err.Error()}})
+		return
+	}
+
+	if result.Linked {
+		redirectToFrontend(c, url.Values{"oauth_linked": {provider}})
+		return
+	}
+	redirectToFrontend(c, url.Values{"oauth_code": {result.ExchangeCode}})
+}
+
+// ExchangeOAuthCode redeems the one-time code from a login callback for tokens
+// POST /api/v1/auth/oauth/exchange
+func ExchangeOAuthCode(c *gin.Context) {
+	var req OAuthExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	user, tokens, err := services.ExchangeOAuthCode(req.Code, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: tokens,
+		User:      user,
+	})
+}
+
+// LinkOAuth starts linking a provider to the current user
+// Returns the provider URL for the frontend to navigate to, since the redirect cannot carry the access token
+// The frontend must send the request with credentials so the browser keeps the binding cookie
+// POST /api/v1/auth/oauth/:provider/link
+func LinkOAuth(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	authorizeURL, ok := startOAuth(c, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorize_url": authorizeURL,
+	})
+}
+
+// ListOAuthIdentities lists the provider accounts linked to the current user
+// GET /api/v1/auth/oauth/identities
+func ListOAuthIdentities(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	identities, err := services.ListOAuthIdentities(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list linked accounts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"identities": identities,
+	})
+}
+
+// UnlinkOAuth removes a linked provider account from the current user
+// DELETE /api/v1/auth/oauth/:provider
+func UnlinkOAuth(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	if err := services.UnlinkOAuthIdentity(userID, c.Param("provider")); err != nil {
+		switch {
+		case errors.Is(err, services.ErrOAuthProviderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Linked account not found",
+			})
+		case errors.Is(err, services.ErrOAuthLastLoginMethod):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Account unlinked successfully",
+	})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"backend-server/middleware"
+	"backend-server/models"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlanRequest represents the request to create or update a plan
+type PlanRequest struct {
+	Name             string `json:"name" binding:"required,min=1,max=64"`
+	Description      string `json:"description" binding:"max=255"`
+	UnlimitedCredits bool   `json:"unlimited_credits"`
+	UnlimitedStorage bool   `json:"unlimited_storage"`
+
+	TaskPriority       int `json:"task_priority" binding:"min=-100,max=100"`
+	QueueWeight        int `json:"queue_weight" binding:"omitempty,min=1,max=100"` // Defaults to 1
+	MaxProcessingTasks int `json:"max_processing_tasks" binding:"min=0,max=100"`
+
+	AdvancedParams *string `json:"advanced_params" binding:"omitempty,max=255"` // Null uses ADVANCED_PARAMS_ALLOWED
+}
+
+func (r *PlanRequest) input() services.PlanInput {
+	weight := r.QueueWeight
+	if weight == 0 {
+		weight = 1
+	}
+	return services.PlanInput{
+		Name:             r.Name,
+		Description:      r.Description,
+		UnlimitedCredits: r.UnlimitedCredits,
+		UnlimitedStorage: r.UnlimitedStorage,
+
+		TaskPriority:       r.TaskPriority,
+		QueueWeight:        weight,
+		MaxProcessingTasks: r.MaxProcessingTasks,
+
+		AdvancedParams: r.AdvancedParams,
+	}
+}
+
+// AssignPlanRequest represents the request to assign a plan to a user
+type AssignPlanRequest struct {
+	PlanID string `json:"plan_id"` // Empty removes the user's plan
+}
+
+// PlanResponse represents a plan with the number of assigned users
+type PlanResponse struct {
+	models.Plan
+	UserCount int64 `json:"user_count"`
+}
+
+// respondPlanError maps plan errors to HTTP responses
+func respondPlanError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrUnknownAdvancedParam):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrPlanNameTaken):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+// ListPlans lists all plans
+// GET /api/v1/admin/plans
+func ListPlans(c *gin.Context) {
+	plans, counts, err := services.ListPlans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list plans",
+		})
+		return
+	}
+
+	items := make([]PlanResponse, len(plans))
+	for i, plan := range plans {
+		items[i] = PlanResponse{Plan: plan, UserCount: counts[plan.ID]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"plans": items,
+	})
+}
+
+// CreatePlan creates a plan
+// POST /api/v1/admin/plans
+func CreatePlan(c *gin.Context) {
+	var req PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	plan, err := services.CreatePlan(req.input())
+	if err != nil {
+		respondPlanError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetPlan, nil, plan)
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// UpdatePlan updates a plan; assigned users are affected immediately
+// PUT /api/v1/admin/plans/:id
+func UpdatePlan(c *gin.Context) {
+	var req PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	before, err := services.GetPlan(c.Param("id"))
+	if err != nil {
+		respondPlanError(c, err)
+		return
+	}
+
+	plan, err := services.UpdatePlan(c.Param("id"), req.input())
+	if err != nil {
+		respondPlanError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetPlan, before, plan)
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeletePlan deletes a plan and unassigns its users
+// DELETE /api/v1/admin/plans/:id
+func DeletePlan(c *gin.Context) {
+	before, err := services.GetPlan(c.Param("id"))
+	if err != nil {
+		respondPlanError(c, err)
+		return
+	}
+
+	if err := services.DeletePlan(c.Param("id")); err != nil {
+		respondPlanError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetPlan, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Plan deleted",
+	})
+}
+
+// AssignPlan assigns a plan to a user or removes it
+// PUT /api/v1/admin/users/:id/plan
+func AssignPlan(c *gin.Context) {
+	var req AssignPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	before, _ := services.GetUserByID(c.Param("id"))
+
+	user, err := services.AssignPlan(c.Param("id"), req.PlanID)
+	if err != nil {
+		if errors.Is(err, services.ErrPlanNotFound) {
+			respondPlanError(c, err)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetUser,
+		gin.H{"plan_id": before.PlanID}, gin.H{"plan_id": user.PlanID})
+
+	c.JSON(http.StatusOK, user)
+}
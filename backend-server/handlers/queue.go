@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPublicQueueStats returns the queue length and expected wait for the frontend status banner
+// GET /api/v1/queue/stats
+func GetPublicQueueStats(c *gin.Context) {
+	stats, err := services.GetPublicQueueStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load queue statistics",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
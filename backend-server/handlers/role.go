@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"backend-server/middleware"
+	"backend-server/models"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetRoleRequest represents the request to change a user's role
+type SetRoleRequest struct {
+	Role models.UserRole `json:"role" binding:"required"`
+}
+
+// PermissionRequest represents the request to grant a permission
+type PermissionRequest struct {
+	Permission string `json:"permission" binding:"required"`
+}
+
+// respondRBACError maps role and permission errors to HTTP responses
+func respondRBACError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvalidPermission):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+// GetAccess returns the role and effective permissions of the current user
+// GET /api/v1/auth/access
+func GetAccess(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	access, err := services.GetUserAccess(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, access)
+}
+
+// SetUserRole changes the role of a user
+// PUT /api/v1/admin/users/:id/role
+func SetUserRole(c *gin.Context) {
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	before, _ := services.GetUserByID(c.Param("id"))
+
+	user, err := services.SetUserRole(c.Param("id"), req.Role)
+	if err != nil {
+		respondRBACError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetUser,
+		gin.H{"role": before.Role}, gin.H{"role": user.Role})
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ListUserPermissions returns the effective permissions of a user and the individually granted ones
+// GET /api/v1/admin/users/:id/permissions
+func ListUserPermissions(c *gin.Context) {
+	userID := c.Param("id")
+
+	access, err := services.GetUserAccess(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	grants, err := services.ListUserPermissionGrants(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list permissions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":                  access.Role,
+		"permissions":           access.Permissions,
+		"grants":                grants,
+		"available_permissions": services.AllPermissions,
+	})
+}
+
+// GrantPermission grants a permission to a user on top of their role
+// POST /api/v1/admin/users/:id/permissions
+func GrantPermission(c *gin.Context) {
+	var req PermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := services.GrantPermission(c.Param("id"), req.Permission, middleware.GetUserID(c)); err != nil {
+		respondRBACError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetUser, nil, gin.H{"permission": req.Permission})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Permission granted",
+	})
+}
+
+// RevokePermission removes an individually granted permission from a user
+// DELETE /api/v1/admin/users/:id/permissions/:permission
+func RevokePermission(c *gin.Context) {
+	if err := services.RevokePermission(c.Param("id"), c.Param("permission")); err != nil {
+		respondRBACError(c, err)
+		return
+	}
+	middleware.SetAuditChange(c, services.AuditTargetUser, gin.H{"permission": c.Param("permission")}, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Permission revoked",
+	})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"backend-server/middleware"
+	"backend-server/models"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScriptLineRequest represents one line of a script
+type ScriptLineRequest struct {
+	Speaker             string    `json:"speaker" binding:"required"`
+	Text                string    `json:"text" binding:"required,max=1000"`
+	EmotionMode         string    `json:"emotion_mode" binding:"omitempty,oneof=same_as_reference emotion_prompt emotion_vector emotion_text emotion_description"` // Defaults to same_as_reference
+	EmotionPromptFileID string    `json:"emotion_prompt_file_id" binding:"omitempty,len=36"`
+	EmotionVector       []float64 `json:"emotion_vector" binding:"omitempty,len=8"`
+	EmotionAlpha        *float64  `json:"emotion_alpha" binding:"omitempty,min=0,max=1"`
+	EmotionText         string    `json:"emotion_text" binding:"max=200"`
+	PauseAfterMs        int       `json:"pause_after_ms" binding:"min=0,max=10000"` // Silence before the next line
+}
+
+// CreateScriptTaskRequest represents the request to create a multi-speaker script task
+type CreateScriptTaskRequest struct {
+	Speakers map[string]string   `json:"speakers" binding:"required,min=1,max=10"` // Speaker name to reference audio file ID
+	Lines    []ScriptLineRequest `json:"lines" binding:"required,min=1,max=100,dive"`
+	RunAt    *time.Time          `json:"run_at"`
+
+	Advanced *services.AdvancedParams `json:"advanced"` // Applied to every line
+}
+
+// CreateScriptTask creates a task that speaks each line of a script with the voice of its speaker
+// and joins the lines into one audio file; it costs one task's credits per line
+// POST /api/v1/tasks/script
+func CreateScriptTask(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	var req CreateScriptTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if err := services.CheckRunAt(req.RunAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := services.CheckAdvancedParams(userID, req.Advanced); err != nil {
+		respondAdvancedParamsError(c, err)
+		return
+	}
+
+	input := services.ScriptTaskInput{
+		Speakers: req.Speakers,
+		Lines:    make([]services.ScriptLineInput, len(req.Lines)),
+		RunAt:    req.RunAt,
+		Advanced: req.Advanced,
+	}
+	for i, line := range req.Lines {
+		input.Lines[i] = services.ScriptLineInput{
+			Speaker: line.Speaker,
+			Text:    line.Text,
+			Emotion: services.EmotionSettings{
+				Mode:         models.EmotionMode(line.EmotionMode),
+				PromptFileID: line.EmotionPromptFileID,
+				Vector:       line.EmotionVector,
+				Alpha:        line.EmotionAlpha,
+				Text:         line.EmotionText,
+			},
+			PauseAfterMs: line.PauseAfterMs,
+		}
+	}
+
+	task, err := services.CreateScriptTask(userID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidScript):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrScriptCreditsNeeded):
+			c.JSON(http.StatusPaymentRequired, gin.H{
+				"error":            "Insufficient credits",
+				"credits_required": services.ScriptCredits(len(req.Lines)),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         task.ID,
+		"type":       task.Type,
+		"status":     task.Status,
+		"run_at":     task.RunAt,
+		"created_at": task.CreatedAt,
+	})
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-server/config"
+	"backend-server/middleware"
+	"backend-server/models"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateShareLinkRequest represents the request to create a share link
+type CreateShareLinkRequest struct {
+	FileID         string `json:"file_id" binding:"omitempty,len=36"`
+	TaskID         string `json:"task_id" binding:"omitempty,len=36"`
+	Password       string `json:"password" binding:"omitempty,max=64"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=8760"`
+	MaxDownloads   int    `json:"max_downloads" binding:"omitempty,min=1,max=100000"`
+}
+
+// ShareLinkResponse represents a share link in API responses
+type ShareLinkResponse struct {
+	ID             string     `json:"id"`
+	Token          string     `json:"token"`
+	URL            string     `json:"url"`
+	FileID         string     `json:"file_id"`
+	TaskID         string     `json:"task_id,omitempty"`
+	HasPassword    bool       `json:"has_password"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	MaxDownloads   int        `json:"max_downloads"`
+	DownloadCount  int        `json:"download_count"`
+	ViewCount      int        `json:"view_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// shareURL builds the public URL for a share token
+func shareURL(token string) string {
+	return config.Cfg.ShareBaseURL + "/s/" + token
+}
+
+func newShareLinkResponse(link *models.ShareLink) ShareLinkResponse {
+	return ShareLinkResponse{
+		ID:             link.ID,
+		Token:          link.Token,
+		URL:            shareURL(link.Token),
+		FileID:         link.FileID,
+		TaskID:         link.TaskID,
+		HasPassword:    link.HasPassword(),
+		ExpiresAt:      link.ExpiresAt,
+		MaxDownloads:   link.MaxDownloads,
+		DownloadCount:  link.DownloadCount,
+		ViewCount:      link.ViewCount,
+		LastAccessedAt: link.LastAccessedAt,
+		RevokedAt:      link.RevokedAt,
+		CreatedAt:      link.CreatedAt,
+	}
+}
+
+// CreateShareLink creates a public share link for a file or task result
+// POST /api/v1/shares
+func CreateShareLink(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if (req.FileID == "") == (req.TaskID == "") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Exactly one of file_id or task_id is required",
+		})
+		return
+	}
+
+	link, err := services.CreateShareLink(userID, services.CreateShareLinkParams{
+		FileID:       req.FileID,
+		TaskID:       req.TaskID,
+		Password:     req.Password,
+		ExpiresIn:    time.Duration(req.ExpiresInHours) * time.Hour,
+		MaxDownloads: req.MaxDownloads,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newShareLinkResponse(link))
+}
+
+// ListShareLinks lists the user's share links with access counts
+// GET /api/v1/shares
+func ListShareLinks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	page := 1
+	pageSize := 20
+
+	if p := c.Query("page"); p != "" {
+		if v := parsePositiveIntValue(p); v > 0 {
+			page = v
+		}
+	}
+
+	if ps := c.Query("page_size"); ps != "" {
+		if v := parsePositiveIntValue(ps); v > 0 && v <= 100 {
+			pageSize = v
+		}
+	}
+
+	links, total, err := services.ListShareLinks(userID, c.Query("file_id"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list share links",
+		})
+		return
+	}
+
+	items := make([]ShareLinkResponse, len(links))
+	for i := range links {
+		items[i] = newShareLinkResponse(&links[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shares":    items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// RevokeShareLink revokes a share link
+// DELETE /api/v1/shares/:id
+func RevokeShareLink(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	link, err := services.RevokeShareLink(userID, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrShareLinkNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Share link not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newShareLinkResponse(link))
+}
+
+// shareAccessCookie remembers in a browser that the password of a share link was entered
+// It is scoped to the path of the link, see UnlockShareLink
+const shareAccessCookie = "share_access"
+
+// sharePageTemplate renders a minimal player page for a share link
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Filename}}</title>
+<style>
+body { font-family: sans-serif; max-width: 480px; margin: 64px auto; padding: 0 16px; color: #222; }
+audio { width: 100%; margin: 16px 0; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>{{.Filename}}</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .NeedPassword}}
+<form method="post">
+<input type="password" name="password" placeholder="Password" autofocus>
+<button type="submit">OK</button>
+</form>
+{{else}}
+<audio controls preload="metadata" src="{{.AudioURL}}"></audio>
+<p><a href="{{.DownloadURL}}">Download</a></p>
+{{end}}
+</body>
+</html>
+`))
+
+type sharePageData struct {
+	Filename     string
+	Error        string
+	NeedPassword bool
+	AudioURL     string
+	DownloadURL  string
+}
+
+// shareErrorStatus maps a ResolveShareLink error to an HTTP status
+func shareErrorStatus(err error) int {
+	var authErr *services.AuthError
+	switch {
+	case errors.As(err, &authErr):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrShareLinkExpired), errors.Is(err, services.ErrShareLinkRevoked):
+		return http.StatusGone
+	case errors.Is(err, services.ErrSharePasswordRequired), errors.Is(err, services.ErrSharePasswordIncorrect):
+		return http.StatusUnauthorized
+	}
+	return http.StatusNotFound
+}
+
+// respondShareError writes a ResolveShareLink error, as the password form for browsers
+// when the link exists but is locked
+func respondShareError(c *gin.Context, err error, file *models.File, page bool) {
+	status := shareErrorStatus(err)
+	if status == http.StatusTooManyRequests {
+		if !page || file == nil {
+			respondAuthError(c, err)
+			return
+		}
+		var authErr *services.AuthError
+		errors.As(err, &authErr)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(authErr.RetryAfter.Seconds()))))
+	}
+
+	if page && file != nil && (status == http.StatusUnauthorized || status == http.StatusTooManyRequests) {
+		data := sharePageData{Filename: file.Filename, NeedPassword: true}
+		if !errors.Is(err, services.ErrSharePasswordRequired) {
+			data.Error = err.Error()
+		}
+		renderSharePage(c, status, data)
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
+
+// ViewShareLink serves a shared file without authentication
+// Browsers get a minimal player page, other clients (or ?raw=1) get the audio stream and
+// ?download=1 downloads it as an attachment. Every full fetch of the audio counts towards the
+// download limit, except the player's, which uses a short-lived ?play= URL from the page.
+// Password protected links are unlocked by the X-Share-Password header or, in browsers,
+// by the cookie set by UnlockShareLink.
+// GET /s/:token
+func ViewShareLink(c *gin.Context) {
+	token := c.Param("token")
+	access, _ := c.Cookie(shareAccessCookie)
+
+	download := c.Query("download") == "1"
+	play := c.Query("play")
+	raw := c.Query("raw") == "1" || download || play != "" ||
+		!strings.Contains(c.GetHeader("Accept"), "text/html")
+
+	link, file, err := services.ResolveShareLink(token, services.ShareCredentials{
+		Password: c.GetHeader("X-Share-Password"),
+		Access:   access,
+		IP:       c.ClientIP(),
+	})
+	if err != nil {
+		respondShareError(c, err, file, !raw)
+		return
+	}
+
+	if link.DownloadsExhausted() {
+		c.JSON(http.StatusGone, gin.H{
+			"error": services.ErrShareDownloadLimit.Error(),
+		})
+		return
+	}
+
+	if !raw {
+		services.RecordShareView(link.ID)
+
+		sharePath := "/s/" + url.PathEscape(token)
+		playToken := services.SharePlayToken(link, time.Now().Add(services.SharePlayTTL))
+		renderSharePage(c, http.StatusOK, sharePageData{
+			Filename:    file.Filename,
+			AudioURL:    sharePath + "?play=" + url.QueryEscape(playToken),
+			DownloadURL: sharePath + "?download=1",
+		})
+		return
+	}
+
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+
+	// Resumed and partial fetches do not count again, nor does the player page
+	rangeHeader := c.GetHeader("Range")
+	fullFetch := rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+	if fullFetch && (download || !services.ValidSharePlayToken(link, play)) {
+		if err := services.RecordShareDownload(link.ID); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrShareDownloadLimit) {
+				status = http.StatusGone
+			}
+			c.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	serveFileContent(c, file, "private, no-cache", disposition)
+}
+
+// UnlockShareLink checks the password entered on the player page of a share link and
+// remembers it in an HttpOnly cookie for the link's path, so the password never appears
+// in a URL. Attempts are rate limited per link and per client IP.
+// POST /s/:token
+func UnlockShareLink(c *gin.Context) {
+	token := c.Param("token")
+	sharePath := "/s/" + url.PathEscape(token)
+
+	link, file, err := services.ResolveShareLink(token, services.ShareCredentials{
+		Password: c.PostForm("password"),
+		IP:       c.ClientIP(),
+	})
+	if err != nil {
+		respondShareError(c, err, file, true)
+		return
+	}
+
+	if link.HasPassword() {
+		access := services.ShareAccessToken(link, time.Now().Add(services.ShareAccessTTL))
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(shareAccessCookie, access, int(services.ShareAccessTTL.Seconds()), sharePath, "",
+			strings.HasPrefix(config.Cfg.ShareBaseURL, "https://"), true)
+	}
+	c.Redirect(http.StatusSeeOther, sharePath)
+}
+
+func renderSharePage(c *gin.Context, status int, data sharePageData) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := sharePageTemplate.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"backend-server/middleware"
 	"backend-server/models"
@@ -14,12 +16,21 @@ import (
 
 // CreateTaskRequest represents the request to create a new task
 type CreateTaskRequest struct {
-	Text                 string    `json:"text" binding:"required,min=1,max=5000"`
-	ReferenceAudioFileID string    `json:"reference_audio_file_id" binding:"required,len=36"`
-	EmotionMode          string    `json:"emotion_mode" binding:"required,oneof=same_as_reference emotion_prompt emotion_vector emotion_text"`
-	EmotionPromptFileID  string    `json:"emotion_prompt_file_id" binding:"omitempty,len=36"`
-	EmotionVector        []float64 `json:"emotion_vector" binding:"omitempty,len=8"`
-	EmotionAlpha         *float64  `json:"emotion_alpha" binding:"omitempty,min=0,max=1"`
+	Text                 string     `json:"text" binding:"required,min=1,max=5000"`
+	ReferenceAudioFileID string     `json:"reference_audio_file_id" binding:"required,len=36"`
+	EmotionMode          string     `json:"emotion_mode" binding:"required,oneof=same_as_reference emotion_prompt emotion_vector emotion_text emotion_description"`
+	EmotionPromptFileID  string     `json:"emotion_prompt_file_id" binding:"omitempty,len=36"`
+	EmotionVector        []float64  `json:"emotion_vector" binding:"omitempty,len=8"`
+	EmotionAlpha         *float64   `json:"emotion_alpha" binding:"omitempty,min=0,max=1"`
+	EmotionText          string     `json:"emotion_text" binding:"max=200"` // Required when emotion_mode is emotion_description
+	RunAt                *time.Time `json:"run_at"`                         // Optional, a future time schedules the task instead of queueing it now
+
+	Advanced *services.AdvancedParams `json:"advanced"` // Optional sampling controls, limited by the user's plan
+}
+
+// RescheduleTaskRequest represents the request to change when a task runs
+type RescheduleTaskRequest struct {
+	RunAt *time.Time `json:"run_at"` // Null or a past time queues the task now
 }
 
 // CreateTask creates a new TTS task
@@ -40,6 +51,13 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
+	if err := services.CheckRunAt(req.RunAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// Check if user has enough credits
 	hasCredits, err := services.CheckCredits(userID)
 	if err != nil {
@@ -65,51 +83,39 @@ func CreateTask(c *gin.Context) {
 	}
 
 	// Validate emotion mode parameters
-	switch models.EmotionMode(req.EmotionMode) {
-	case models.EmotionModePrompt:
-		if req.EmotionPromptFileID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "emotion_prompt_file_id is required when emotion_mode is emotion_prompt",
-			})
-			return
-		}
-		// Validate emotion prompt file exists and belongs to the user
-		var emotionFile models.File
-		if err := models.DB.First(&emotionFile, "id = ? AND user_id = ?", req.EmotionPromptFileID, userID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Emotion prompt file not found",
-			})
-			return
-		}
-	case models.EmotionModeVector:
-		if len(req.EmotionVector) != 8 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "emotion_vector must have exactly 8 elements when emotion_mode is emotion_vector",
-			})
-			return
-		}
-		// Validate vector values
-		for i, v := range req.EmotionVector {
-			if v < 0 || v > 1 {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "emotion_vector values must be between 0 and 1",
-					"index": i,
-				})
-				return
-			}
-		}
+	emotion := services.EmotionSettings{
+		Mode:         models.EmotionMode(req.EmotionMode),
+		PromptFileID: req.EmotionPromptFileID,
+		Vector:       req.EmotionVector,
+		Alpha:        req.EmotionAlpha,
+		Text:         req.EmotionText,
+	}
+	if err := services.CheckEmotion(userID, &emotion); err != nil {
+		respondEmotionError(c, err)
+		return
+	}
+
+	// Validate advanced generation parameters
+	if err := services.CheckAdvancedParams(userID, req.Advanced); err != nil {
+		respondAdvancedParamsError(c, err)
+		return
 	}
 
 	// Create task
 	task := models.Task{
 		ID:                   uuid.New().String(),
 		UserID:               userID,
-		Status:               models.TaskStatusPending,
+		Status:               services.InitialTaskStatus(req.RunAt),
+		Type:                 models.TaskTypeSingle,
+		Priority:             services.DefaultTaskPriority(userID),
+		RunAt:                req.RunAt,
 		Text:                 req.Text,
 		ReferenceAudioFileID: req.ReferenceAudioFileID,
 		EmotionMode:          models.EmotionMode(req.EmotionMode),
 		EmotionPromptFileID:  req.EmotionPromptFileID,
 		EmotionAlpha:         req.EmotionAlpha,
+		EmotionText:          emotion.Text,
+		AdvancedParams:       services.EncodeAdvancedParams(req.Advanced),
 	}
 
 	// Store emotion vector as JSON string
@@ -136,24 +142,105 @@ func CreateTask(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"id":         task.ID,
 		"status":     task.Status,
+		"run_at":     task.RunAt,
 		"created_at": task.CreatedAt,
 	})
 }
 
+// respondEmotionError maps emotion validation errors to HTTP responses
+func respondEmotionError(c *gin.Context, err error) {
+	var vectorErr *services.EmotionVectorError
+	if errors.As(err, &vectorErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"index": vectorErr.Index,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": err.Error(),
+	})
+}
+
+// respondAdvancedParamsError maps advanced parameter validation errors to HTTP responses
+func respondAdvancedParamsError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, services.ErrAdvancedParamNotAllowed) {
+		status = http.StatusForbidden
+	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
+
 // TaskResponse represents the response for a task
 type TaskResponse struct {
 	ID                   string             `json:"id"`
 	Status               models.TaskStatus  `json:"status"`
+	Type                 models.TaskType    `json:"type"`
+	Priority             int                `json:"priority"`
 	Text                 string             `json:"text"`
 	ReferenceAudioFileID string             `json:"reference_audio_file_id"`
 	EmotionMode          models.EmotionMode `json:"emotion_mode"`
 	EmotionPromptFileID  string             `json:"emotion_prompt_file_id,omitempty"`
 	EmotionVector        string             `json:"emotion_vector,omitempty"`
 	EmotionAlpha         *float64           `json:"emotion_alpha,omitempty"`
+	EmotionText          string             `json:"emotion_text,omitempty"`
+	Advanced             json.RawMessage    `json:"advanced,omitempty"`
 	ResultAudioFileID    string             `json:"result_audio_file_id,omitempty"`
+	ResultExpiredAt      string             `json:"result_expired_at,omitempty"` // The retention policy deleted the result
 	ErrorMessage         string             `json:"error_message,omitempty"`
+	RunAt                string             `json:"run_at,omitempty"`
 	CreatedAt            string             `json:"created_at"`
 	UpdatedAt            string             `json:"updated_at"`
+	Lines                []models.TaskLine  `json:"lines,omitempty"` // Script tasks only, with stems and timing once completed
+	TaskEstimateFields
+}
+
+// TaskEstimateFields holds the queue position and expected timing of a pending or processing task
+type TaskEstimateFields struct {
+	QueuePosition     int64  `json:"queue_position,omitempty"`      // Estimated position of a pending task, 1 runs next
+	EstimatedStartAt  string `json:"estimated_start_at,omitempty"`  // Omitted while the queue is paused
+	EstimatedFinishAt string `json:"estimated_finish_at,omitempty"` // Omitted while the queue is paused
+}
+
+// formatRunAt formats the scheduled time of a task, empty if it has none
+func formatRunAt(task *models.Task) string {
+	if task.RunAt == nil {
+		return ""
+	}
+	return task.RunAt.Format("2006-01-02T15:04:05Z07:00")
+}
+
+func formatResultExpiredAt(task *models.Task) string {
+	if task.ResultExpiredAt == nil {
+		return ""
+	}
+	return task.ResultExpiredAt.Format("2006-01-02T15:04:05Z07:00")
+}
+
+// advancedJSON returns the advanced parameters of a task as a JSON object, nil if it has none
+func advancedJSON(task *models.Task) json.RawMessage {
+	if task.AdvancedParams == "" {
+		return nil
+	}
+	return json.RawMessage(task.AdvancedParams)
+}
+
+// estimateFields converts a queue estimate into response fields
+func estimateFields(est *services.TaskEstimate) TaskEstimateFields {
+	var fields TaskEstimateFields
+	if est == nil {
+		return fields
+	}
+	fields.QueuePosition = est.QueuePosition
+	if est.StartAt != nil {
+		fields.EstimatedStartAt = est.StartAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if est.FinishAt != nil {
+		fields.EstimatedFinishAt = est.FinishAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return fields
 }
 
 // GetTask retrieves a task by ID
@@ -180,18 +267,40 @@ func GetTask(c *gin.Context) {
 	resp := TaskResponse{
 		ID:                   task.ID,
 		Status:               task.Status,
+		Type:                 task.Type,
+		Priority:             task.Priority,
 		Text:                 task.Text,
 		ReferenceAudioFileID: task.ReferenceAudioFileID,
 		EmotionMode:          task.EmotionMode,
 		EmotionPromptFileID:  task.EmotionPromptFileID,
 		EmotionVector:        task.EmotionVector,
 		EmotionAlpha:         task.EmotionAlpha,
+		EmotionText:          task.EmotionText,
+		Advanced:             advancedJSON(&task),
 		ResultAudioFileID:    task.ResultAudioFileID,
+		ResultExpiredAt:      formatResultExpiredAt(&task),
 		ErrorMessage:         task.ErrorMessage,
+		RunAt:                formatRunAt(&task),
 		CreatedAt:            task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:            task.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
 
+	if task.Type == models.TaskTypeScript {
+		lines, err := services.GetTaskLines(task.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to load script lines",
+			})
+			return
+		}
+		resp.Lines = lines
+	}
+
+	// Estimates are best effort, the task is returned without them on failure
+	if estimates, err := services.EstimateTasks([]models.Task{task}); err == nil {
+		resp.TaskEstimateFields = estimateFields(estimates[task.ID])
+	}
+
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -199,14 +308,18 @@ func GetTask(c *gin.Context) {
 type TaskListItem struct {
 	ID                   string             `json:"id"`
 	Status               models.TaskStatus  `json:"status"`
+	Type                 models.TaskType    `json:"type"`
 	Text                 string             `json:"text"`
 	ReferenceAudioFileID string             `json:"reference_audio_file_id"`
 	EmotionMode          models.EmotionMode `json:"emotion_mode"`
 	EmotionPromptFileID  string             `json:"emotion_prompt_file_id,omitempty"`
 	ResultAudioFileID    string             `json:"result_audio_file_id,omitempty"`
+	ResultExpiredAt      string             `json:"result_expired_at,omitempty"`
 	ErrorMessage         string             `json:"error_message,omitempty"`
+	RunAt                string             `json:"run_at,omitempty"`
 	CreatedAt            string             `json:"created_at"`
 	UpdatedAt            string             `json:"updated_at"`
+	TaskEstimateFields
 }
 
 // ListTasks lists tasks with pagination
@@ -258,20 +371,27 @@ func ListTasks(c *gin.Context) {
 
 	query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&tasks)
 
+	// Estimates are best effort, tasks are listed without them on failure
+	estimates, _ := services.EstimateTasks(tasks)
+
 	// Convert to response items (without sensitive OSS keys)
 	items := make([]TaskListItem, len(tasks))
 	for i, task := range tasks {
 		items[i] = TaskListItem{
 			ID:                   task.ID,
 			Status:               task.Status,
+			Type:                 task.Type,
 			Text:                 task.Text,
 			ReferenceAudioFileID: task.ReferenceAudioFileID,
 			EmotionMode:          task.EmotionMode,
 			EmotionPromptFileID:  task.EmotionPromptFileID,
 			ResultAudioFileID:    task.ResultAudioFileID,
+			ResultExpiredAt:      formatResultExpiredAt(&task),
 			ErrorMessage:         task.ErrorMessage,
+			RunAt:                formatRunAt(&task),
 			CreatedAt:            task.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 			UpdatedAt:            task.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			TaskEstimateFields:   estimateFields(estimates[task.ID]),
 		}
 	}
 
@@ -283,6 +403,41 @@ func ListTasks(c *gin.Context) {
 	})
 }
 
+// RescheduleTask changes when a scheduled or pending task runs
+// PUT /api/v1/tasks/:id/schedule
+func RescheduleTask(c *gin.Context) {
+	var req RescheduleTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	task, err := services.RescheduleTask(middleware.GetUserID(c), c.Param("id"), req.RunAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRunAt):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrTaskInvalidState):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Only scheduled or pending tasks can be rescheduled",
+			})
+		default:
+			respondTaskAdminError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":     task.ID,
+		"status": task.Status,
+		"run_at": task.RunAt,
+	})
+}
+
 // helper function to parse json number
 func jsonNumber(s string) int64 {
 	n, _ := json.Number(s).Int64()
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -57,6 +58,29 @@ func UploadAudio(c *gin.Context) {
 		return
 	}
 
+	// Determine file kind (results are only created by the worker)
+	kind := models.FileKind(c.DefaultPostForm("kind", string(models.FileKindReference)))
+	if kind != models.FileKindReference && kind != models.FileKindEmotionPrompt {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid file kind. Allowed: reference, emotion_prompt",
+		})
+		return
+	}
+
+	// Check storage quota
+	if err := services.CheckStorageQuota(userID, file.Size); err != nil {
+		if errors.Is(err, services.ErrStorageQuotaExceeded) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Storage quota exceeded",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check storage quota",
+		})
+		return
+	}
+
 	// Open file
 	src, err := file.Open()
 	if err != nil {
@@ -93,6 +117,7 @@ func UploadAudio(c *gin.Context) {
 	fileRecord := models.File{
 		ID:          uuid.New().String(),
 		UserID:      userID,
+		Kind:        kind,
 		Filename:    file.Filename,
 		OSSKey:      ossKey,
 		ContentType: contentType,
@@ -108,6 +133,7 @@ func UploadAudio(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"id":       fileRecord.ID,
+		"kind":     fileRecord.Kind,
 		"filename": fileRecord.Filename,
 		"size":     fileRecord.Size,
 	})
@@ -37,20 +37,54 @@ func main() {
 		log.Fatalf("Failed to initialize inference service: %v", err)
 	}
 
+	// Initialize audit log
+	if err := services.InitAudit(); err != nil {
+		log.Fatalf("Failed to initialize audit log: %v", err)
+	}
+
+	// Initialize queue estimates
+	if err := services.InitQueueETA(); err != nil {
+		log.Fatalf("Failed to initialize queue estimates: %v", err)
+	}
+
+	// Initialize roles and plans
+	if err := services.InitRBAC(); err != nil {
+		log.Fatalf("Failed to initialize access control: %v", err)
+	}
+
 	// Initialize SMS service
 	if err := services.InitSMS(); err != nil {
 		log.Fatalf("Failed to initialize SMS service: %v", err)
 	}
 
+	// Initialize mailer
+	if err := services.InitMailer(); err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize third-party login providers
+	if err := services.InitOAuth(); err != nil {
+		log.Fatalf("Failed to initialize OAuth providers: %v", err)
+	}
+
+	// Initialize rate limiter
+	if err := services.InitRateLimiter(); err != nil {
+		log.Fatalf("Failed to initialize rate limiter: %v", err)
+	}
+
 	// Initialize Alipay service
 	if err := services.InitAlipay(); err != nil {
 		log.Fatalf("Failed to initialize Alipay service: %v", err)
 	}
 
 	// Start background worker
-	worker := services.NewWorker()
+	worker := services.NewWorker(services.NewInferenceClient(), services.OSSStorage{})
 	worker.Start()
 
+	// Start retention janitor
+	janitor := services.NewJanitor(services.OSSStorage{})
+	janitor.Start()
+
 	// Set Gin mode
 	gin.SetMode(config.Cfg.GinMode)
 
@@ -61,17 +95,14 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "Range", "If-Range", "If-None-Match", "If-Modified-Since", "X-Share-Password"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"},
 		AllowCredentials: true,
 	}))
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status": "ok",
-		})
-	})
+	// Health check and metrics
+	r.GET("/health", handlers.Health)
+	r.GET("/metrics", handlers.Metrics)
 
 	// API routes
 	api := r.Group("/api/v1")
@@ -81,42 +112,142 @@ func main() {
 		{
 			auth.POST("/send-code", handlers.SendCode)
 			auth.POST("/login", handlers.Login)
+			auth.POST("/refresh", handlers.Refresh)
+			auth.POST("/email/register", handlers.RegisterEmail)
+			auth.POST("/email/login", handlers.LoginEmail)
+			auth.POST("/email/magic-link", handlers.SendMagicLink)
+			auth.POST("/email/verify", handlers.VerifyEmail)
+			auth.POST("/password/forgot", handlers.ForgotPassword)
+			auth.POST("/password/reset", handlers.ResetPassword)
+			auth.GET("/oauth/providers", handlers.ListOAuthProviders)
+			auth.GET("/oauth/:provider/start", handlers.StartOAuth)
+			auth.GET("/oauth/:provider/callback", handlers.OAuthCallback)
+			auth.POST("/oauth/exchange", handlers.ExchangeOAuthCode)
 		}
 
-		// Protected routes (require authentication)
+		// Protected routes (require a login session or an API key)
 		protected := api.Group("")
 		protected.Use(middleware.AuthRequired())
 		{
 			// Auth - get current user
 			protected.GET("/auth/me", handlers.GetCurrentUser)
+			protected.GET("/auth/access", handlers.GetAccess)
 
 			// Upload
-			protected.POST("/upload", handlers.UploadAudio)
+			protected.POST("/upload", middleware.RequireScope(services.ScopeFilesWrite), handlers.UploadAudio)
 
 			// Files
-			protected.GET("/files/:id", handlers.GetFile)
-			protected.GET("/files/:id/url", handlers.GetFileURL)
+			protected.GET("/files", middleware.RequireScope(services.ScopeFilesRead), handlers.ListFiles)
+			protected.GET("/files/usage", middleware.RequireScope(services.ScopeFilesRead), handlers.GetStorageUsage)
+			protected.GET("/files/:id", middleware.RequireScope(services.ScopeFilesRead), handlers.GetFile)
+			protected.GET("/files/:id/url", middleware.RequireScope(services.ScopeFilesRead), handlers.GetFileURL)
+			protected.GET("/files/:id/metadata", middleware.RequireScope(services.ScopeFilesRead), handlers.GetFileMetadata)
+			protected.PATCH("/files/:id", middleware.RequireScope(services.ScopeFilesWrite), handlers.RenameFile)
+			protected.DELETE("/files/:id", middleware.RequireScope(services.ScopeFilesWrite), handlers.DeleteFile)
+
+			// Share links
+			protected.POST("/shares", middleware.RequireScope(services.ScopeFilesWrite), handlers.CreateShareLink)
+			protected.GET("/shares", middleware.RequireScope(services.ScopeFilesRead), handlers.ListShareLinks)
+			protected.DELETE("/shares/:id", middleware.RequireScope(services.ScopeFilesWrite), handlers.RevokeShareLink)
 
 			// Tasks
-			protected.POST("/tasks", handlers.CreateTask)
-			protected.GET("/tasks", handlers.ListTasks)
-			protected.GET("/tasks/:id", handlers.GetTask)
+			protected.POST("/tasks", middleware.RequireScope(services.ScopeTasksWrite), handlers.CreateTask)
+			protected.POST("/tasks/script", middleware.RequireScope(services.ScopeTasksWrite), handlers.CreateScriptTask)
+			protected.GET("/tasks", middleware.RequireScope(services.ScopeTasksRead), handlers.ListTasks)
+			protected.GET("/tasks/:id", middleware.RequireScope(services.ScopeTasksRead), handlers.GetTask)
+			protected.PUT("/tasks/:id/schedule", middleware.RequireScope(services.ScopeTasksWrite), handlers.RescheduleTask)
 
 			// Credits
-			protected.GET("/credits", handlers.GetCredits)
-			protected.GET("/credits/logs", handlers.GetCreditLogs)
+			protected.GET("/credits", middleware.RequireScope(services.ScopeCreditsRead), handlers.GetCredits)
+			protected.GET("/credits/logs", middleware.RequireScope(services.ScopeCreditsRead), handlers.GetCreditLogs)
+			protected.GET("/payment/orders", middleware.RequireScope(services.ScopeCreditsRead), handlers.ListOrders)
+			protected.GET("/payment/orders/:id", middleware.RequireScope(services.ScopeCreditsRead), handlers.GetOrder)
+		}
 
+		// Session-only routes (not reachable with an API key)
+		session := api.Group("")
+		session.Use(middleware.AuthRequired(), middleware.SessionRequired())
+		{
 			// Payment
-			protected.POST("/payment/orders", handlers.CreateOrder)
-			protected.POST("/payment/orders/wap", handlers.CreateWapOrder)
-			protected.GET("/payment/orders", handlers.ListOrders)
-			protected.GET("/payment/orders/:id", handlers.GetOrder)
+			session.POST("/payment/orders", handlers.CreateOrder)
+			session.POST("/payment/orders/wap", handlers.CreateWapOrder)
+
+			// Sessions
+			session.POST("/auth/logout", handlers.Logout)
+			session.GET("/auth/sessions", handlers.ListSessions)
+			session.DELETE("/auth/sessions", handlers.RevokeAllSessions)
+			session.DELETE("/auth/sessions/:id", handlers.RevokeSession)
+			session.POST("/auth/email/bind", handlers.BindEmail)
+			session.POST("/auth/phone/bind", handlers.BindPhone)
+			session.GET("/auth/oauth/identities", handlers.ListOAuthIdentities)
+			session.POST("/auth/oauth/:provider/link", handlers.LinkOAuth)
+			session.DELETE("/auth/oauth/:provider", handlers.UnlinkOAuth)
+
+			// API keys
+			session.POST("/api-keys", handlers.CreateAPIKey)
+			session.GET("/api-keys", handlers.ListAPIKeys)
+			session.DELETE("/api-keys/:id", handlers.RevokeAPIKey)
+		}
+
+		// Admin routes (require a login session and a staff role, each route checks its permission)
+		// Every request, including denied ones, is recorded in the audit log
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthRequired(), middleware.AuditAdmin(), middleware.SessionRequired(),
+			middleware.RequireRole(models.UserRoleAdmin, models.UserRoleSupport, models.UserRoleBilling))
+		{
+			// Users
+			admin.GET("/users", middleware.RequirePermission(services.PermUsersRead), handlers.ListUsers)
+			admin.GET("/users/:id", middleware.RequirePermission(services.PermUsersRead), handlers.GetUserDetail)
+			admin.POST("/users/:id/disable", middleware.RequirePermission(services.PermUsersWrite), handlers.DisableUser)
+			admin.POST("/users/:id/enable", middleware.RequirePermission(services.PermUsersWrite), handlers.EnableUser)
+			admin.POST("/users/:id/credits", middleware.RequirePermission(services.PermCreditsAdjust), handlers.AdjustUserCredits)
+
+			// Tasks and queue
+			admin.GET("/tasks", middleware.RequirePermission(services.PermTasksRead), handlers.ListAllTasks)
+			admin.GET("/queue", middleware.RequirePermission(services.PermTasksRead), handlers.GetQueueStats)
+			admin.POST("/tasks/:id/requeue", middleware.RequirePermission(services.PermTasksManage), handlers.RequeueTask)
+			admin.POST("/tasks/:id/fail", middleware.RequirePermission(services.PermTasksManage), handlers.FailTask)
+			admin.POST("/tasks/:id/cancel", middleware.RequirePermission(services.PermTasksManage), handlers.CancelTask)
+			admin.PUT("/tasks/:id/priority", middleware.RequirePermission(services.PermTasksManage), handlers.SetTaskPriority)
+			admin.POST("/worker/pause", middleware.RequirePermission(services.PermTasksManage), handlers.PauseWorker)
+			admin.POST("/worker/resume", middleware.RequirePermission(services.PermTasksManage), handlers.ResumeWorker)
+			admin.GET("/janitor", middleware.RequirePermission(services.PermTasksRead), handlers.GetJanitorStatus)
+
+			// Roles and permissions
+			admin.PUT("/users/:id/role", middleware.RequirePermission(services.PermRolesManage), handlers.SetUserRole)
+			admin.GET("/users/:id/permissions", middleware.RequirePermission(services.PermRolesManage), handlers.ListUserPermissions)
+			admin.POST("/users/:id/permissions", middleware.RequirePermission(services.PermRolesManage), handlers.GrantPermission)
+			admin.DELETE("/users/:id/permissions/:permission", middleware.RequirePermission(services.PermRolesManage), handlers.RevokePermission)
+
+			// Plans
+			admin.GET("/plans", middleware.RequirePermission(services.PermPlansManage), handlers.ListPlans)
+			admin.POST("/plans", middleware.RequirePermission(services.PermPlansManage), handlers.CreatePlan)
+			admin.PUT("/plans/:id", middleware.RequirePermission(services.PermPlansManage), handlers.UpdatePlan)
+			admin.DELETE("/plans/:id", middleware.RequirePermission(services.PermPlansManage), handlers.DeletePlan)
+			admin.PUT("/users/:id/plan", middleware.RequirePermission(services.PermPlansManage), handlers.AssignPlan)
+
+			// Audit log
+			admin.GET("/audit", middleware.RequirePermission(services.PermAuditRead), handlers.ListAuditEvents)
+			admin.GET("/audit/verify", middleware.RequirePermission(services.PermAuditRead), handlers.VerifyAuditChain)
+
+			// Inference backends
+			admin.GET("/inference/backends", middleware.RequirePermission(services.PermTasksRead), handlers.ListInferenceBackends)
+			admin.POST("/inference/backends", middleware.RequirePermission(services.PermInferenceManage), handlers.CreateInferenceBackend)
+			admin.PUT("/inference/backends/:id", middleware.RequirePermission(services.PermInferenceManage), handlers.UpdateInferenceBackend)
+			admin.DELETE("/inference/backends/:id", middleware.RequirePermission(services.PermInferenceManage), handlers.DeleteInferenceBackend)
 		}
 
+		// Public queue status (no auth required)
+		api.GET("/queue/stats", handlers.GetPublicQueueStats)
+
 		// Public payment callback (no auth required)
 		api.POST("/payment/alipay/notify", handlers.AlipayNotify)
 	}
 
+	// Public share links (no auth required)
+	r.GET("/s/:token", handlers.ViewShareLink)
+	r.POST("/s/:token", handlers.UnlockShareLink)
+
 	// Graceful shutdown
 	go func() {
 		sigCh := make(chan os.Signal, 1)
@@ -125,6 +256,7 @@ func main() {
 
 		log.Println("Shutting down...")
 		worker.Stop()
+		janitor.Stop()
 		os.Exit(0)
 	}()
 
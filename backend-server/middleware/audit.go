@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditDetailKey is the context key for extra details handlers attach to the audit event
+const AuditDetailKey = "audit_detail"
+
+// AuditChangeKey is the context key for the state change handlers attach to the audit event
+const AuditChangeKey = "audit_change"
+
+// maxAuditBodyBytes limits how much of a request body is copied into the audit log
+const maxAuditBodyBytes = 4096
+
+// AuditAdmin is a middleware that records every request, including denied ones, in the audit log
+// Place it after AuthRequired so that the actor is known
+func AuditAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		detail := map[string]interface{}{}
+		if c.Request.Method != http.MethodGet && c.Request.Body != nil {
+			body, _ := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodyBytes+1))
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+			var parsed interface{}
+			if len(body) <= maxAuditBodyBytes && json.Unmarshal(body, &parsed) == nil {
+				detail["request"] = parsed
+			}
+		}
+
+		c.Next()
+
+		if q := c.Request.URL.RawQuery; q != "" {
+			detail["query"] = q
+		}
+		detail["status"] = c.Writer.Status()
+		if extra, exists := c.Get(AuditDetailKey); exists {
+			for k, v := range extra.(map[string]interface{}) {
+				detail[k] = v
+			}
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		entry := services.AuditEntry{
+			ActorID:   GetUserID(c),
+			Action:    c.Request.Method + " " + route,
+			TargetID:  c.Param("id"),
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Detail:    detail,
+		}
+		if change, exists := c.Get(AuditChangeKey); exists {
+			change := change.(*auditChange)
+			entry.TargetType = change.targetType
+			entry.Before = change.before
+			entry.After = change.after
+		}
+		services.RecordAudit(entry)
+	}
+}
+
+type auditChange struct {
+	targetType string
+	before     interface{}
+	after      interface{}
+}
+
+// SetAuditChange records the state of the target before and after the request in its audit event
+func SetAuditChange(c *gin.Context, targetType string, before, after interface{}) {
+	c.Set(AuditChangeKey, &auditChange{targetType: targetType, before: before, after: after})
+}
+
+// SetAuditDetail attaches a detail to the audit event of the current request
+func SetAuditDetail(c *gin.Context, key string, value interface{}) {
+	detail, exists := c.Get(AuditDetailKey)
+	if !exists {
+		detail = map[string]interface{}{}
+		c.Set(AuditDetailKey, detail)
+	}
+	detail.(map[string]interface{})[key] = value
+}
@@ -14,11 +14,31 @@ const (
 	UserIDKey = "user_id"
 	// UserPhoneKey is the context key for user phone
 	UserPhoneKey = "user_phone"
+	// AuthMethodKey is the context key for how the request was authenticated
+	AuthMethodKey = "auth_method"
+	// SessionIDKey is the context key for the login session ID (JWT auth only)
+	SessionIDKey = "session_id"
+	// APIKeyIDKey is the context key for the API key ID (API key auth only)
+	APIKeyIDKey = "api_key_id"
+	// ScopesKey is the context key for the API key scopes (API key auth only)
+	ScopesKey = "scopes"
 )
 
-// AuthRequired is a middleware that requires a valid JWT token
+// Authentication methods
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// AuthRequired is a middleware that requires a valid JWT token or API key
+// API keys are accepted via the X-API-Key header or as "Bearer sk-..."
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -40,6 +60,11 @@ func AuthRequired() gin.HandlerFunc {
 
 		tokenString := parts[1]
 
+		if strings.HasPrefix(tokenString, services.APIKeyPrefix) {
+			authenticateAPIKey(c, tokenString)
+			return
+		}
+
 		// Validate token
 		claims, err := services.ValidateUserToken(tokenString)
 		if err != nil {
@@ -53,11 +78,63 @@ func AuthRequired() gin.HandlerFunc {
 		// Set user info in context
 		c.Set(UserIDKey, claims.UserID)
 		c.Set(UserPhoneKey, claims.Phone)
+		c.Set(SessionIDKey, claims.SessionID)
+		c.Set(AuthMethodKey, AuthMethodJWT)
 
 		c.Next()
 	}
 }
 
+// authenticateAPIKey validates an API key and sets the same user context as a JWT
+func authenticateAPIKey(c *gin.Context, rawKey string) {
+	key, user, err := services.ValidateAPIKey(rawKey)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or revoked API key",
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set(UserIDKey, user.ID)
+	c.Set(UserPhoneKey, user.PhoneNumber())
+	c.Set(AuthMethodKey, AuthMethodAPIKey)
+	c.Set(APIKeyIDKey, key.ID)
+	c.Set(ScopesKey, key.ScopeList())
+
+	c.Next()
+}
+
+// RequireScope is a middleware that requires an API key to carry the given scope
+// Requests authenticated with a JWT session are always allowed
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetAuthMethod(c) == AuthMethodAPIKey && !HasScope(c, scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "API key lacks required scope: " + scope,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionRequired is a middleware that rejects requests authenticated with an API key
+// Used for account management routes that API keys must not reach
+func SessionRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetAuthMethod(c) != AuthMethodJWT {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "This endpoint requires a login session",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // GetUserID extracts the user ID from the context
 func GetUserID(c *gin.Context) string {
 	if userID, exists := c.Get(UserIDKey); exists {
@@ -73,3 +150,33 @@ func GetUserPhone(c *gin.Context) string {
 	}
 	return ""
 }
+
+// GetSessionID extracts the login session ID from the context
+func GetSessionID(c *gin.Context) string {
+	if sessionID, exists := c.Get(SessionIDKey); exists {
+		return sessionID.(string)
+	}
+	return ""
+}
+
+// GetAuthMethod extracts the authentication method from the context
+func GetAuthMethod(c *gin.Context) string {
+	if method, exists := c.Get(AuthMethodKey); exists {
+		return method.(string)
+	}
+	return ""
+}
+
+// HasScope checks if the API key used for the request carries the given scope
+func HasScope(c *gin.Context, scope string) bool {
+	scopes, exists := c.Get(ScopesKey)
+	if !exists {
+		return false
+	}
+	for _, s := range scopes.([]string) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
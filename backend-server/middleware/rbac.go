@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend-server/models"
+	"backend-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessKey is the context key for the role and permissions of the user
+const AccessKey = "access"
+
+// loadAccess returns the role and permissions of the authenticated user, loading them once per request
+// Roles are read from the database so that changes apply without waiting for tokens to expire
+func loadAccess(c *gin.Context) (*services.UserAccess, bool) {
+	if access, exists := c.Get(AccessKey); exists {
+		return access.(*services.UserAccess), true
+	}
+
+	access, err := services.GetUserAccess(GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		c.Abort()
+		return nil, false
+	}
+
+	c.Set(AccessKey, access)
+	return access, true
+}
+
+// RequireRole is a middleware that requires the user to have one of the given roles
+// Admins are always allowed
+func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, ok := loadAccess(c)
+		if !ok {
+			return
+		}
+
+		allowed := access.Role == models.UserRoleAdmin
+		for _, role := range roles {
+			if access.Role == role {
+				allowed = true
+			}
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Insufficient role",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission is a middleware that requires the user to hold the given permission
+// through their role or an individual grant
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, ok := loadAccess(c)
+		if !ok {
+			return
+		}
+
+		if !access.Has(perm) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Missing required permission: " + perm,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetAccess returns the role and permissions loaded by RequireRole or RequirePermission
+func GetAccess(c *gin.Context) *services.UserAccess {
+	if access, exists := c.Get(AccessKey); exists {
+		return access.(*services.UserAccess)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AuditChainID is the ID of the single row that tracks the head of the audit hash chain
+const AuditChainID = 1
+
+// AuditChain holds the head of the audit hash chain
+// Appending an event locks this row, so events are chained in a strict order across replicas
+type AuditChain struct {
+	ID        int       `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	LastSeq   int64     `json:"last_seq"`
+	LastHash  string    `gorm:"type:varchar(64)" json:"last_hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for AuditChain
+func (AuditChain) TableName() string {
+	return "audit_chain"
+}
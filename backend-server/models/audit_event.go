@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// AuditEvent records a security- or money-relevant action
+// Events form a hash chain: each hash covers the event and the hash of the previous event,
+// so modifying or deleting an event breaks every later link
+type AuditEvent struct {
+	ID         string    `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Seq        int64     `gorm:"uniqueIndex:idx_audit_event_seq" json:"seq"`       // Position in the hash chain, starting at 1
+	ActorID    string    `gorm:"type:varchar(36);index" json:"actor_id,omitempty"` // Empty for system and anonymous actions
+	Action     string    `gorm:"type:varchar(128);index;not null" json:"action"`
+	TargetType string    `gorm:"type:varchar(32)" json:"target_type,omitempty"` // user, order, task, session, plan
+	TargetID   string    `gorm:"type:varchar(64);index" json:"target_id,omitempty"`
+	IP         string    `gorm:"type:varchar(64)" json:"ip,omitempty"`
+	UserAgent  string    `gorm:"type:varchar(255)" json:"user_agent,omitempty"`
+	Before     string    `gorm:"type:text" json:"before,omitempty"` // JSON state before the action
+	After      string    `gorm:"type:text" json:"after,omitempty"`  // JSON state after the action
+	Detail     string    `gorm:"type:text" json:"detail,omitempty"` // JSON object
+	PrevHash   string    `gorm:"type:varchar(64)" json:"prev_hash"`
+	Hash       string    `gorm:"type:varchar(64);index" json:"hash"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for AuditEvent
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
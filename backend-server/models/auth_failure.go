@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// AuthFailure tracks failed login attempts for a phone number or client IP
+type AuthFailure struct {
+	Key          string     `gorm:"type:varchar(100);primaryKey" json:"key"` // "phone:<phone>" or "ip:<ip>"
+	Failures     int        `gorm:"default:0" json:"failures"`               // Failures in the current window
+	WindowStart  time.Time  `json:"window_start"`
+	LockoutLevel int        `gorm:"default:0" json:"lockout_level"` // Number of consecutive lockouts, grows the lockout duration
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for AuthFailure
+func (AuthFailure) TableName() string {
+	return "auth_failures"
+}
+
+// IsLocked checks if the key is currently locked out
+func (a *AuthFailure) IsLocked() bool {
+	return a.LockedUntil != nil && time.Now().Before(*a.LockedUntil)
+}
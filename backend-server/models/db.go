@@ -33,10 +33,62 @@ func InitDB() error {
 	}
 
 	// Auto migrate
-	if err := DB.AutoMigrate(&Task{}, &File{}, &User{}, &VerificationCode{}, &Order{}, &CreditLog{}); err != nil {
+	if err := Migrate(DB); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := backfillFileKinds(); err != nil {
+		return fmt.Errorf("failed to backfill file kinds: %w", err)
+	}
+
+	if err := migratePhonesToE164(); err != nil {
+		return fmt.Errorf("failed to migrate phone numbers: %w", err)
+	}
+
 	log.Println("Database connected and migrated successfully")
 	return nil
 }
+
+// Migrate creates or updates the tables of all models
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Task{}, &File{}, &User{}, &VerificationCode{}, &Order{}, &CreditLog{}, &ShareLink{}, &APIKey{}, &Session{}, &AuthFailure{}, &RateLimitCounter{}, &EmailToken{}, &OAuthIdentity{}, &OAuthState{}, &Plan{}, &UserPermission{}, &AuditEvent{}, &AuditChain{}, &InferenceBackend{}, &TaskLine{})
+}
+
+// backfillFileKinds assigns a kind to files created before the kind column existed
+func backfillFileKinds() error {
+	unset := "kind IS NULL OR kind = ''"
+
+	if err := DB.Model(&File{}).Unscoped().
+		Where(unset).
+		Where("id IN (?)", DB.Model(&Task{}).Unscoped().Select("result_audio_file_id")).
+		Update("kind", FileKindResult).Error; err != nil {
+		return err
+	}
+
+	if err := DB.Model(&File{}).Unscoped().
+		Where(unset).
+		Where("id IN (?)", DB.Model(&Task{}).Unscoped().Select("emotion_prompt_file_id")).
+		Update("kind", FileKindEmotionPrompt).Error; err != nil {
+		return err
+	}
+
+	return DB.Model(&File{}).Unscoped().
+		Where(unset).
+		Update("kind", FileKindReference).Error
+}
+
+// migratePhonesToE164 rewrites mainland China numbers stored as 11 digits to E.164 (+86...)
+func migratePhonesToE164() error {
+	legacy := "phone REGEXP '^1[3-9][0-9]{9}$'"
+	e164 := gorm.Expr("CONCAT('+86', phone)")
+
+	result := DB.Model(&User{}).Unscoped().Where(legacy).Update("phone", e164)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Migrated %d user phone numbers to E.164", result.RowsAffected)
+	}
+
+	return DB.Model(&VerificationCode{}).Where(legacy).Update("phone", e164).Error
+}
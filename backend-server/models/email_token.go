@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailTokenPurpose represents what an emailed link is for
+type EmailTokenPurpose string
+
+const (
+	EmailTokenRegister      EmailTokenPurpose = "register"       // Confirms a new email + password account
+	EmailTokenBind          EmailTokenPurpose = "bind"           // Adds an email to an existing user
+	EmailTokenMagicLink     EmailTokenPurpose = "magic_link"     // Passwordless login
+	EmailTokenPasswordReset EmailTokenPurpose = "password_reset" // Sets a new password
+)
+
+// EmailToken is a single-use token sent by email
+// Only the SHA-256 hash of the token is stored
+type EmailToken struct {
+	ID           string            `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Email        string            `gorm:"type:varchar(255);index;not null" json:"email"`
+	UserID       string            `gorm:"type:varchar(36);index" json:"user_id,omitempty"` // Empty for registrations and sign-ups
+	Purpose      EmailTokenPurpose `gorm:"type:varchar(20);not null" json:"purpose"`
+	TokenHash    string            `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	PasswordHash string            `gorm:"type:varchar(100)" json:"-"` // Password chosen at registration or bind
+	ExpiresAt    time.Time         `gorm:"not null" json:"expires_at"`
+	UsedAt       *time.Time        `json:"used_at,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// TableName specifies the table name for EmailToken
+func (EmailToken) TableName() string {
+	return "email_tokens"
+}
+
+// IsExpired checks if the token has expired
+func (t *EmailToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// IsValid checks if the token is unused and not expired
+func (t *EmailToken) IsValid() bool {
+	return t.UsedAt == nil && !t.IsExpired()
+}
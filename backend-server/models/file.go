@@ -6,10 +6,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// FileKind represents what a file is used for
+type FileKind string
+
+const (
+	FileKindReference     FileKind = "reference"      // Reference audio for voice cloning
+	FileKindEmotionPrompt FileKind = "emotion_prompt" // Emotion reference audio
+	FileKindResult        FileKind = "result"         // Generated audio
+)
+
 // File represents an uploaded file stored in OSS
 type File struct {
 	ID          string         `gorm:"type:varchar(36);primaryKey" json:"id"`
 	UserID      string         `gorm:"type:varchar(36);index;not null" json:"user_id"`
+	Kind        FileKind       `gorm:"type:varchar(20);index" json:"kind"`
 	Filename    string         `gorm:"type:varchar(255);not null" json:"filename"`
 	OSSKey      string         `gorm:"type:varchar(512);not null;uniqueIndex" json:"oss_key"`
 	ContentType string         `gorm:"type:varchar(100);not null" json:"content_type"`
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// InferenceBackendSource tells how an inference backend was registered
+type InferenceBackendSource string
+
+const (
+	InferenceBackendSourceConfig InferenceBackendSource = "config" // INFERENCE_URL or INFERENCE_URLS, registered at startup
+	InferenceBackendSourceAdmin  InferenceBackendSource = "admin"  // Added through the admin API
+)
+
+// InferenceBackend is an inference service instance the worker can send tasks to
+type InferenceBackend struct {
+	ID        string                 `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Name      string                 `gorm:"type:varchar(64);not null" json:"name"`
+	URL       string                 `gorm:"type:varchar(255);uniqueIndex;not null" json:"url"`
+	Enabled   bool                   `gorm:"not null" json:"enabled"` // Disabled backends receive no new requests
+	Source    InferenceBackendSource `gorm:"type:varchar(16);not null" json:"source"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// TableName specifies the table name for InferenceBackend
+func (InferenceBackend) TableName() string {
+	return "inference_backends"
+}
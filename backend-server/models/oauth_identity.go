@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// OAuthIdentity links an account at a third-party login provider to a user
+type OAuthIdentity struct {
+	ID          string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	UserID      string     `gorm:"type:varchar(36);index;not null" json:"user_id"`
+	Provider    string     `gorm:"type:varchar(32);uniqueIndex:idx_oauth_provider_subject;not null" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);uniqueIndex:idx_oauth_provider_subject;not null" json:"subject"` // Stable user ID at the provider
+	Email       string     `gorm:"type:varchar(255)" json:"email,omitempty"`
+	DisplayName string     `gorm:"type:varchar(100)" json:"display_name,omitempty"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for OAuthIdentity
+func (OAuthIdentity) TableName() string {
+	return "oauth_identities"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// OAuthState tracks one OAuth authorization attempt from redirect to token exchange
+// The state parameter and the exchange code are stored as SHA-256 hashes
+type OAuthState struct {
+	ID           string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	StateHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Provider     string     `gorm:"type:varchar(32);not null" json:"provider"`
+	Nonce        string     `gorm:"type:varchar(64)" json:"-"`                      // Checked against the ID token (OIDC)
+	CodeVerifier string     `gorm:"type:varchar(128)" json:"-"`                     // PKCE verifier (OIDC)
+	LinkUserID   string     `gorm:"type:varchar(36)" json:"link_user_id,omitempty"` // Set when a logged-in user links a provider
+	BrowserHash  string     `gorm:"type:varchar(64)" json:"-"`                      // Hash of the cookie binding the attempt to the browser that started it
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"` // Provider callback handled
+
+	// One-time code handed to the frontend after a successful login callback
+	ExchangeHash      string     `gorm:"type:varchar(64);index" json:"-"`
+	ResultUserID      string     `gorm:"type:varchar(36)" json:"result_user_id,omitempty"`
+	ExchangeExpiresAt *time.Time `json:"exchange_expires_at,omitempty"`
+	ExchangedAt       *time.Time `json:"exchanged_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for OAuthState
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
+
+// IsExpired checks if the authorization attempt has expired
+func (s *OAuthState) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
@@ -44,7 +44,7 @@ type CreditLog struct {
 	UserID    string    `gorm:"type:varchar(36);index;not null" json:"user_id"`
 	Amount    int       `gorm:"not null" json:"amount"`        // Positive for add, negative for deduct
 	Balance   int       `gorm:"not null" json:"balance"`       // Balance after this transaction
-	Type      string    `gorm:"type:varchar(20)" json:"type"`  // register, recharge, consume, refund
+	Type      string    `gorm:"type:varchar(20)" json:"type"`  // register, recharge, consume, refund, admin_adjust
 	RefID     string    `gorm:"type:varchar(36)" json:"ref_id"` // Reference ID (order_id or task_id)
 	Remark    string    `gorm:"type:varchar(256)" json:"remark,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
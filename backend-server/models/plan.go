@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Plan grants benefits such as unlimited credits to the users assigned to it
+// Plans are managed by admins at runtime
+type Plan struct {
+	ID               string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Name             string `gorm:"type:varchar(64);uniqueIndex;not null" json:"name"`
+	Description      string `gorm:"type:varchar(255)" json:"description,omitempty"`
+	UnlimitedCredits bool   `gorm:"default:false" json:"unlimited_credits"` // Tasks do not consume credits
+	UnlimitedStorage bool   `gorm:"default:false" json:"unlimited_storage"` // No storage quota and no retention expiry
+
+	// Scheduling
+	TaskPriority       int `gorm:"default:0" json:"task_priority"`        // Priority of new tasks created by assigned users
+	QueueWeight        int `gorm:"default:1" json:"queue_weight"`         // Share of the worker relative to users without a plan, who have weight 1
+	MaxProcessingTasks int `gorm:"default:0" json:"max_processing_tasks"` // Tasks per user processed at the same time, 0 uses USER_MAX_PROCESSING_TASKS
+
+	// Generation
+	AdvancedParams *string `gorm:"type:varchar(255)" json:"advanced_params"` // Comma-separated advanced parameters users may set, "*" for all; null uses ADVANCED_PARAMS_ALLOWED
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Plan
+func (Plan) TableName() string {
+	return "plans"
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// RateLimitCounter is a fixed-window counter used by the database-backed rate limiter
+type RateLimitCounter struct {
+	Key         string    `gorm:"type:varchar(150);primaryKey" json:"key"`
+	WindowStart time.Time `gorm:"not null" json:"window_start"`
+	Count       int       `gorm:"default:0" json:"count"`
+	UpdatedAt   time.Time `gorm:"index" json:"updated_at"`
+}
+
+// TableName specifies the table name for RateLimitCounter
+func (RateLimitCounter) TableName() string {
+	return "rate_limit_counters"
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// Session represents a login session on one device, backed by a rotating refresh token
+type Session struct {
+	ID                string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	UserID            string     `gorm:"type:varchar(36);index;not null" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"type:varchar(64);index" json:"-"` // Last rotated token, used to detect reuse
+	UserAgent         string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IP                string     `gorm:"type:varchar(64)" json:"ip"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Session
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// IsExpired checks if the refresh token of the session has expired
+func (s *Session) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// IsActive checks if the session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && !s.IsExpired()
+}
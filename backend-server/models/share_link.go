@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// ShareLink represents a public link to a generated or uploaded audio file
+type ShareLink struct {
+	ID             string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Token          string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"token"`
+	UserID         string     `gorm:"type:varchar(36);index;not null" json:"user_id"`
+	FileID         string     `gorm:"type:varchar(36);index;not null" json:"file_id"`
+	TaskID         string     `gorm:"type:varchar(36);index" json:"task_id,omitempty"` // Set when the link was created from a task
+	PasswordHash   string     `gorm:"type:varchar(100)" json:"-"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	MaxDownloads   int        `gorm:"default:0" json:"max_downloads"` // 0 means unlimited
+	DownloadCount  int        `gorm:"default:0" json:"download_count"`
+	ViewCount      int        `gorm:"default:0" json:"view_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for ShareLink
+func (ShareLink) TableName() string {
+	return "share_links"
+}
+
+// IsExpired checks if the share link has expired
+func (s *ShareLink) IsExpired() bool {
+	return s.ExpiresAt != nil && time.Now().After(*s.ExpiresAt)
+}
+
+// IsRevoked checks if the share link has been revoked by its owner
+func (s *ShareLink) IsRevoked() bool {
+	return s.RevokedAt != nil
+}
+
+// HasPassword checks if the share link is password protected
+func (s *ShareLink) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// DownloadsExhausted checks if the download limit has been reached
+func (s *ShareLink) DownloadsExhausted() bool {
+	return s.MaxDownloads > 0 && s.DownloadCount >= s.MaxDownloads
+}
@@ -10,30 +10,43 @@ import (
 type TaskStatus string
 
 const (
+	TaskStatusScheduled  TaskStatus = "scheduled" // Waiting for run_at before joining the queue
 	TaskStatusPending    TaskStatus = "pending"
 	TaskStatusProcessing TaskStatus = "processing"
 	TaskStatusCompleted  TaskStatus = "completed"
 	TaskStatusFailed     TaskStatus = "failed"
+	TaskStatusCancelled  TaskStatus = "cancelled"
+)
+
+// TaskType distinguishes single-voice tasks from multi-speaker scripts
+type TaskType string
+
+const (
+	TaskTypeSingle TaskType = "single" // One text spoken with one reference audio
+	TaskTypeScript TaskType = "script" // Lines spoken by several speakers, see TaskLine
 )
 
 // EmotionMode represents how emotion is controlled
 type EmotionMode string
 
 const (
-	EmotionModeSameAsReference EmotionMode = "same_as_reference" // Use same emotion as reference audio
-	EmotionModePrompt          EmotionMode = "emotion_prompt"    // Use emotion reference audio
-	EmotionModeVector          EmotionMode = "emotion_vector"    // Use emotion vector
-	EmotionModeText            EmotionMode = "emotion_text"      // Auto-detect from text
+	EmotionModeSameAsReference EmotionMode = "same_as_reference"   // Use same emotion as reference audio
+	EmotionModePrompt          EmotionMode = "emotion_prompt"      // Use emotion reference audio
+	EmotionModeVector          EmotionMode = "emotion_vector"      // Use emotion vector
+	EmotionModeText            EmotionMode = "emotion_text"        // Auto-detect from text
+	EmotionModeDescription     EmotionMode = "emotion_description" // Use a separate emotion description, e.g. "whispering, scared"
 )
 
 // Task represents a TTS synthesis task
 type Task struct {
-	ID        string         `gorm:"type:varchar(36);primaryKey" json:"id"`
-	UserID    string         `gorm:"type:varchar(36);index;not null" json:"user_id"`
-	Status    TaskStatus     `gorm:"type:varchar(20);index;default:pending" json:"status"`
-	Text      string         `gorm:"type:text;not null" json:"text"`
+	ID       string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	UserID   string     `gorm:"type:varchar(36);index;not null" json:"user_id"`
+	Status   TaskStatus `gorm:"type:varchar(20);index;default:pending" json:"status"`
+	Type     TaskType   `gorm:"type:varchar(20);default:single" json:"type"`
+	Priority int        `gorm:"default:0" json:"priority"`      // Higher priority tasks are processed first
+	Text     string     `gorm:"type:text;not null" json:"text"` // For script tasks, all lines prefixed with their speaker
 
-	// Reference audio for voice cloning (required) - stores file ID
+	// Reference audio for voice cloning (required) - stores file ID, the first speaker's for script tasks
 	ReferenceAudioFileID string `gorm:"type:varchar(36);not null" json:"reference_audio_file_id"`
 
 	// Emotion control
@@ -41,10 +54,28 @@ type Task struct {
 	EmotionPromptFileID string      `gorm:"type:varchar(36)" json:"emotion_prompt_file_id,omitempty"` // File ID when emotion_mode is emotion_prompt
 	EmotionVector       string      `gorm:"type:varchar(256)" json:"emotion_vector,omitempty"`        // JSON array string [8]float
 	EmotionAlpha        *float64    `gorm:"type:decimal(3,2)" json:"emotion_alpha,omitempty"`
+	EmotionText         string      `gorm:"type:varchar(800)" json:"emotion_text,omitempty"` // Emotion description when emotion_mode is emotion_description
+
+	// Advanced generation parameters
+	AdvancedParams string `gorm:"type:varchar(512)" json:"advanced_params,omitempty"` // JSON object of sampling controls, empty uses the engine defaults
 
 	// Result - stores file ID (reference to files table)
 	ResultAudioFileID string `gorm:"type:varchar(36)" json:"result_audio_file_id,omitempty"`
 	ErrorMessage      string `gorm:"type:text" json:"error_message,omitempty"`
+	FailedAttempts    int    `gorm:"default:0" json:"failed_attempts,omitempty"` // Times the inference service failed on the task and it was re-queued
+
+	// Set when the retention policy deleted the result audio, ResultAudioFileID is cleared then
+	ResultExpiredAt *time.Time `json:"result_expired_at,omitempty"`
+
+	// Scheduling
+	RunAt *time.Time `gorm:"index" json:"run_at,omitempty"` // Scheduled tasks join the queue once this time has passed
+
+	// Processing times
+	StartedAt  *time.Time `json:"started_at,omitempty"`  // When the worker picked up the task
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // When the task completed, failed or was cancelled
+
+	// Set by each claim, the worker only updates the task while it holds the claim
+	ClaimID string `gorm:"type:varchar(36)" json:"-"`
 
 	// Timestamps
 	CreatedAt time.Time      `json:"created_at"`
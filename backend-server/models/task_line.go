@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// TaskLine is one line of a script task, spoken by one speaker
+type TaskLine struct {
+	ID       string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	TaskID   string `gorm:"type:varchar(36);uniqueIndex:idx_task_line_position;not null" json:"task_id"`
+	Position int    `gorm:"uniqueIndex:idx_task_line_position;not null" json:"position"` // Order in the script, starting at 0
+	Speaker  string `gorm:"type:varchar(64);not null" json:"speaker"`
+	Text     string `gorm:"type:text;not null" json:"text"`
+
+	// Voice and emotion, with the same meaning as on Task
+	ReferenceAudioFileID string      `gorm:"type:varchar(36);not null" json:"reference_audio_file_id"`
+	EmotionMode          EmotionMode `gorm:"type:varchar(20);default:same_as_reference" json:"emotion_mode"`
+	EmotionPromptFileID  string      `gorm:"type:varchar(36)" json:"emotion_prompt_file_id,omitempty"`
+	EmotionVector        string      `gorm:"type:varchar(256)" json:"emotion_vector,omitempty"`
+	EmotionAlpha         *float64    `gorm:"type:decimal(3,2)" json:"emotion_alpha,omitempty"`
+	EmotionText          string      `gorm:"type:varchar(800)" json:"emotion_text,omitempty"`
+
+	PauseAfterMs int `gorm:"default:0" json:"pause_after_ms"` // Silence between this line and the next
+
+	// Result, set when the task completes
+	StemAudioFileID string `gorm:"type:varchar(36)" json:"stem_audio_file_id,omitempty"` // This line alone
+	StartMs         *int64 `json:"start_ms,omitempty"`                                   // Position of the line in the combined audio
+	EndMs           *int64 `json:"end_ms,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for TaskLine
+func (TaskLine) TableName() string {
+	return "task_lines"
+}
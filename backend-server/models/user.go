@@ -14,21 +14,58 @@ const (
 	UserStatusDisabled UserStatus = "disabled"
 )
 
+// UserRole determines which administrative features a user can reach
+type UserRole string
+
+const (
+	UserRoleUser    UserRole = "user"
+	UserRoleAdmin   UserRole = "admin"
+	UserRoleSupport UserRole = "support"
+	UserRoleBilling UserRole = "billing"
+)
+
 // User represents a registered user
 type User struct {
-	ID          string         `gorm:"type:varchar(36);primaryKey" json:"id"`
-	Phone       string         `gorm:"type:varchar(20);uniqueIndex;not null" json:"phone"`
-	Nickname    string         `gorm:"type:varchar(100)" json:"nickname,omitempty"`
-	Avatar      string         `gorm:"type:varchar(512)" json:"avatar,omitempty"`
-	Credits     int            `gorm:"default:0" json:"credits"`
-	Status      UserStatus     `gorm:"type:varchar(20);default:active" json:"status"`
-	LastLoginAt *time.Time     `json:"last_login_at,omitempty"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              string         `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Phone           *string        `gorm:"type:varchar(20);uniqueIndex" json:"phone,omitempty"`  // E.164, nil for email-only accounts
+	Email           *string        `gorm:"type:varchar(255);uniqueIndex" json:"email,omitempty"` // Lowercased, set once verified
+	PasswordHash    string         `gorm:"type:varchar(100)" json:"-"`
+	Nickname        string         `gorm:"type:varchar(100)" json:"nickname,omitempty"`
+	Avatar          string         `gorm:"type:varchar(512)" json:"avatar,omitempty"`
+	Credits         int            `gorm:"default:0" json:"credits"`
+	Status          UserStatus     `gorm:"type:varchar(20);default:active" json:"status"`
+	Role            UserRole       `gorm:"type:varchar(20);default:user;index" json:"role"`
+	PlanID          *string        `gorm:"type:varchar(36);index" json:"plan_id,omitempty"`
+	TokenVersion    int            `gorm:"default:0" json:"-"` // Incremented to invalidate all access tokens
+	LastLoginAt     *time.Time     `json:"last_login_at,omitempty"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName specifies the table name for User
 func (User) TableName() string {
 	return "users"
 }
+
+// PhoneNumber returns the phone number, or an empty string if none is bound
+func (u *User) PhoneNumber() string {
+	if u.Phone == nil {
+		return ""
+	}
+	return *u.Phone
+}
+
+// EmailAddress returns the email address, or an empty string if none is bound
+func (u *User) EmailAddress() string {
+	if u.Email == nil {
+		return ""
+	}
+	return *u.Email
+}
+
+// HasPassword checks if the user can log in with a password
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
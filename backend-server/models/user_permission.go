@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UserPermission grants a single permission to a user on top of the defaults of their role
+type UserPermission struct {
+	ID         string    `gorm:"type:varchar(36);primaryKey" json:"id"`
+	UserID     string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_user_permission" json:"user_id"`
+	Permission string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_user_permission" json:"permission"`
+	GrantedBy  string    `gorm:"type:varchar(36)" json:"granted_by,omitempty"` // Admin user ID
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for UserPermission
+func (UserPermission) TableName() string {
+	return "user_permissions"
+}
@@ -20,6 +20,9 @@ type VerificationCode struct {
 	Used      bool        `gorm:"default:false" json:"used"`
 	ExpiresAt time.Time   `gorm:"not null" json:"expires_at"`
 	CreatedAt time.Time   `json:"created_at"`
+
+	FailedAttempts int    `gorm:"default:0" json:"failed_attempts"` // Wrong guesses against this code
+	Provider       string `gorm:"type:varchar(20)" json:"provider"` // SMS provider that delivered the code
 }
 
 // TableName specifies the table name for VerificationCode
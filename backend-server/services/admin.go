@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// adminDetailLimit is the number of recent tasks, orders and credit logs shown in a user detail
+const adminDetailLimit = 20
+
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrCannotModifySelf    = errors.New("admins cannot change the status of their own account")
+	ErrInvalidCreditAmount = errors.New("credit adjustment must not be zero")
+	ErrInsufficientCredits = errors.New("adjustment would make the balance negative")
+)
+
+// UserFilter selects users in the admin user list
+type UserFilter struct {
+	Query       string // Matches the user ID exactly, or part of the phone number or email
+	Phone       string // Part of the phone number
+	Status      models.UserStatus
+	Role        models.UserRole
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time // Exclusive
+	Page        int
+	PageSize    int
+}
+
+// UserDetail is a user with the records support staff usually need to look at
+type UserDetail struct {
+	User           *models.User           `json:"user"`
+	Plan           *models.Plan           `json:"plan,omitempty"`
+	Access         *UserAccess            `json:"access"`
+	TaskCounts     map[string]int64       `json:"task_counts"`
+	RecentTasks    []models.Task          `json:"recent_tasks"`
+	RecentOrders   []models.Order         `json:"recent_orders"`
+	RecentCredits  []models.CreditLog     `json:"recent_credit_logs"`
+	ActiveSessions int64                  `json:"active_sessions"`
+	OAuthAccounts  []models.OAuthIdentity `json:"oauth_identities"`
+}
+
+// ListUsers searches users, newest first
+func ListUsers(filter UserFilter) ([]models.User, int64, error) {
+	query := models.DB.Model(&models.User{})
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("id = ? OR phone LIKE ? OR email LIKE ?", q, like, strings.ToLower(like))
+	}
+	if filter.Phone != "" {
+		query = query.Where("phone LIKE ?", "%"+filter.Phone+"%")
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []models.User
+	offset := (filter.Page - 1) * filter.PageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(filter.PageSize).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// GetUserDetail loads a user with credits, plan, task statistics, recent tasks, orders and credit logs
+func GetUserDetail(userID string) (*UserDetail, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	access, err := GetUserAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &UserDetail{
+		User:       user,
+		Plan:       getUserPlan(user),
+		Access:     access,
+		TaskCounts: make(map[string]int64),
+	}
+
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	if err := models.DB.Model(&models.Task{}).
+		Select("status, COUNT(*) AS count").
+		Where("user_id = ?", userID).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count tasks: %w", err)
+	}
+	for _, c := range counts {
+		detail.TaskCounts[c.Status] = c.Count
+	}
+
+	if err := models.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(adminDetailLimit).
+		Find(&detail.RecentTasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+	if err := models.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(adminDetailLimit).
+		Find(&detail.RecentOrders).Error; err != nil {
+		return nil, fmt.Errorf("failed to load orders: %w", err)
+	}
+	if err := models.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(adminDetailLimit).
+		Find(&detail.RecentCredits).Error; err != nil {
+		return nil, fmt.Errorf("failed to load credit logs: %w", err)
+	}
+	if err := models.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Count(&detail.ActiveSessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	if detail.OAuthAccounts, err = ListOAuthIdentities(userID); err != nil {
+		return nil, err
+	}
+
+	return detail, nil
+}
+
+// SetUserStatus enables or disables a user account and returns it with its previous status
+// Disabling revokes all sessions; API keys stop working while the account is disabled
+func SetUserStatus(actorID, userID string, status models.UserStatus) (*models.User, models.UserStatus, error) {
+	if actorID == userID {
+		return nil, "", ErrCannotModifySelf
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, "", ErrUserNotFound
+	}
+	previous := user.Status
+	if previous == status {
+		return user, previous, nil
+	}
+
+	if err := models.DB.Model(user).Update("status", status).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to update user status: %w", err)
+	}
+	user.Status = status
+
+	if status == models.UserStatusDisabled {
+		if err := RevokeAllSessions(userID); err != nil {
+			return nil, "", fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	return user, previous, nil
+}
+
+// AdjustCredits adds or removes credits on behalf of an admin and records the reason
+// The balance can not become negative
+func AdjustCredits(actorID, userID string, amount int, reason string) (*models.CreditLog, error) {
+	if amount == 0 {
+		return nil, ErrInvalidCreditAmount
+	}
+
+	var creditLog models.CreditLog
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if user.Credits+amount < 0 {
+			return ErrInsufficientCredits
+		}
+
+		balance := user.Credits + amount
+		if err := tx.Model(&user).Update("credits", balance).Error; err != nil {
+			return err
+		}
+
+		creditLog = models.CreditLog{
+			ID:      uuid.New().String(),
+			UserID:  userID,
+			Amount:  amount,
+			Balance: balance,
+			Type:    "admin_adjust",
+			RefID:   actorID,
+			Remark:  truncate(reason, 256),
+		}
+		if err := tx.Create(&creditLog).Error; err != nil {
+			return err
+		}
+		return auditCreditLog(tx, &creditLog, actorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &creditLog, nil
+}
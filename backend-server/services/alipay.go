@@ -69,6 +69,15 @@ func CreateRechargeOrder(userID string, amountYuan int) (*models.Order, error) {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
 
+	RecordAudit(AuditEntry{
+		ActorID:    userID,
+		Action:     AuditOrderCreated,
+		TargetType: AuditTargetOrder,
+		TargetID:   order.ID,
+		After:      map[string]interface{}{"status": order.Status, "amount": order.Amount, "credits": order.Credits},
+		Detail:     map[string]interface{}{"out_trade_no": order.OutTradeNo, "pay_method": order.PayMethod},
+	})
+
 	return order, nil
 }
 
@@ -152,6 +161,7 @@ func HandleAlipayNotify(notifyData gopay.BodyMap) error {
 		if err := models.DB.Save(&order).Error; err != nil {
 			return fmt.Errorf("failed to update order: %w", err)
 		}
+		auditOrderStatus(&order, models.OrderStatusPending, tradeStatus)
 
 		// Add credits to user
 		if err := AddCredits(order.UserID, order.Credits, order.ID, fmt.Sprintf("Recharge %d yuan", order.Amount/100)); err != nil {
@@ -163,12 +173,33 @@ func HandleAlipayNotify(notifyData gopay.BodyMap) error {
 	} else if tradeStatus == "TRADE_CLOSED" {
 		order.Status = models.OrderStatusFailed
 		order.ErrorMessage = "Trade closed"
-		models.DB.Save(&order)
+		if err := models.DB.Save(&order).Error; err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		auditOrderStatus(&order, models.OrderStatusPending, tradeStatus)
 	}
 
 	return nil
 }
 
+// auditOrderStatus records a payment status change reported by Alipay
+func auditOrderStatus(order *models.Order, before models.OrderStatus, tradeStatus string) {
+	RecordAudit(AuditEntry{
+		ActorID:    order.UserID,
+		Action:     AuditOrderStatus,
+		TargetType: AuditTargetOrder,
+		TargetID:   order.ID,
+		Before:     map[string]interface{}{"status": before},
+		After:      map[string]interface{}{"status": order.Status},
+		Detail: map[string]interface{}{
+			"trade_no":     order.TradeNo,
+			"trade_status": tradeStatus,
+			"amount":       order.Amount,
+			"credits":      order.Credits,
+		},
+	})
+}
+
 // GetOrderByID retrieves an order by ID
 func GetOrderByID(orderID, userID string) (*models.Order, error) {
 	var order models.Order
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key instead of a JWT
+const APIKeyPrefix = "sk-"
+
+// maxAPIKeysPerUser limits how many active keys a user can hold
+const maxAPIKeysPerUser = 20
+
+// API key scopes
+const (
+	ScopeTasksRead   = "tasks:read"
+	ScopeTasksWrite  = "tasks:write"
+	ScopeFilesRead   = "files:read"
+	ScopeFilesWrite  = "files:write"
+	ScopeCreditsRead = "credits:read"
+)
+
+// AllAPIKeyScopes lists the scopes that can be granted to an API key
+var AllAPIKeyScopes = []string{
+	ScopeTasksRead,
+	ScopeTasksWrite,
+	ScopeFilesRead,
+	ScopeFilesWrite,
+	ScopeCreditsRead,
+}
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyInvalid  = errors.New("invalid or revoked api key")
+)
+
+// IsValidAPIKeyScope checks if a scope can be granted to an API key
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range AllAPIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKey creates a new API key for the user
+// The raw key is returned only once and is never stored
+func CreateAPIKey(userID, name string, scopes []string, expiresIn time.Duration) (*models.APIKey, string, error) {
+	for _, scope := range scopes {
+		if !IsValidAPIKeyScope(scope) {
+			return nil, "", fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+
+	var count int64
+	if err := models.DB.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to count api keys: %w", err)
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", fmt.Errorf("at most %d active api keys are allowed", maxAPIKeysPerUser)
+	}
+
+	// Keys carry 256 bits of entropy, so a fast hash is sufficient for storage
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	rawKey := APIKeyPrefix + secret
+
+	key := &models.APIKey{
+		ID:      uuid.New().String(),
+		UserID:  userID,
+		Name:    name,
+		Prefix:  rawKey[:len(APIKeyPrefix)+8],
+		KeyHash: hashToken(rawKey),
+		Scopes:  strings.Join(scopes, ","),
+	}
+	if expiresIn > 0 {
+		expiresAt := time.Now().Add(expiresIn)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := models.DB.Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return key, rawKey, nil
+}
+
+// ValidateAPIKey checks a raw key and returns it together with its owner
+func ValidateAPIKey(rawKey string) (*models.APIKey, *models.User, error) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	var key models.APIKey
+	if err := models.DB.First(&key, "key_hash = ?", hashToken(rawKey)).Error; err != nil {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	if !key.IsValid() {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, "id = ?", key.UserID).Error; err != nil {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	if user.Status == models.UserStatusDisabled {
+		return nil, nil, errors.New("user account is disabled")
+	}
+
+	// Record usage at most once a minute to avoid a write per request
+	now := time.Now()
+	models.DB.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-time.Minute)).
+		Update("last_used_at", now)
+
+	return &key, &user, nil
+}
+
+// ListAPIKeys lists the user's API keys, newest first
+func ListAPIKeys(userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := models.DB.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key owned by the user
+func RevokeAPIKey(userID, keyID string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := models.DB.First(&key, "id = ? AND user_id = ?", keyID, userID).Error; err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt != nil {
+		return &key, nil
+	}
+
+	now := time.Now()
+	if err := models.DB.Model(&key).Update("revoked_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	key.RevokedAt = &now
+
+	return &key, nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit actions recorded by services; admin requests use "<METHOD> <route>"
+const (
+	AuditLogin             = "auth.login"
+	AuditLoginFailed       = "auth.login_failed"
+	AuditTokenIssued       = "auth.token_issued"
+	AuditTokenRefreshed    = "auth.token_refreshed"
+	AuditRefreshTokenReuse = "auth.refresh_token_reused"
+	AuditCreditsPrefix     = "credits." // Followed by the credit log type, e.g. credits.consume
+	AuditOrderCreated      = "order.created"
+	AuditOrderStatus       = "order.status_changed"
+)
+
+// Audit target types
+const (
+	AuditTargetUser    = "user"
+	AuditTargetSession = "session"
+	AuditTargetOrder   = "order"
+	AuditTargetTask    = "task"
+	AuditTargetPlan    = "plan"
+	AuditTargetBackend = "inference_backend"
+)
+
+// auditVerifyBatchSize is the number of events loaded at a time when verifying the chain
+const auditVerifyBatchSize = 1000
+
+// AuditEntry describes an action to record in the audit log
+type AuditEntry struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Before     interface{} // State before the action, stored as JSON
+	After      interface{} // State after the action, stored as JSON
+	Detail     map[string]interface{}
+}
+
+// AuditFilter selects events in the admin audit log query
+type AuditFilter struct {
+	ActorID    string
+	Action     string // Exact action, or a prefix ending in "." such as "auth."
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time // Exclusive
+	Page       int
+	PageSize   int
+}
+
+// AuditVerification is the result of checking the audit hash chain
+type AuditVerification struct {
+	Valid       bool   `json:"valid"`
+	Checked     int64  `json:"checked"`
+	HeadSeq     int64  `json:"head_seq"`
+	HeadHash    string `json:"head_hash"` // Record this value externally to detect truncation of the chain
+	BrokenAtSeq int64  `json:"broken_at_seq,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// InitAudit chains audit events that were recorded before the hash chain existed
+func InitAudit() error {
+	var legacy []models.AuditEvent
+	if err := models.DB.Where("hash = '' OR hash IS NULL").Order("created_at ASC").Find(&legacy).Error; err != nil {
+		return fmt.Errorf("failed to load unchained audit events: %w", err)
+	}
+
+	for i := range legacy {
+		event := &legacy[i]
+		if err := chainAuditEvent(models.DB, event, func(tx *gorm.DB) error {
+			return tx.Model(event).Updates(map[string]interface{}{
+				"seq":        event.Seq,
+				"prev_hash":  event.PrevHash,
+				"hash":       event.Hash,
+				"created_at": event.CreatedAt,
+			}).Error
+		}); err != nil {
+			return fmt.Errorf("failed to chain audit event %s: %w", event.ID, err)
+		}
+	}
+	if len(legacy) > 0 {
+		log.Printf("Added %d existing audit events to the hash chain", len(legacy))
+	}
+	return nil
+}
+
+// RecordAudit appends an event to the audit log
+// Failures are logged and never block the audited action
+func RecordAudit(entry AuditEntry) {
+	event := newAuditEvent(entry)
+	if err := chainAuditEvent(models.DB, event, func(tx *gorm.DB) error {
+		return tx.Create(event).Error
+	}); err != nil {
+		log.Printf("[AUDIT] Failed to record %s by %s on %s: %v", entry.Action, entry.ActorID, entry.TargetID, err)
+	}
+}
+
+// recordAuditTx appends an event to the audit log within the transaction of the audited change,
+// so that the change and its event are committed together or not at all
+func recordAuditTx(tx *gorm.DB, entry AuditEntry) error {
+	event := newAuditEvent(entry)
+	if err := chainAuditEvent(tx, event, func(tx *gorm.DB) error {
+		return tx.Create(event).Error
+	}); err != nil {
+		return fmt.Errorf("failed to record audit event %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// newAuditEvent builds the event for an audit entry, without its place in the chain
+func newAuditEvent(entry AuditEntry) *models.AuditEvent {
+	event := &models.AuditEvent{
+		ID:         uuid.New().String(),
+		ActorID:    entry.ActorID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   truncate(entry.TargetID, 64),
+		IP:         truncate(entry.IP, 64),
+		UserAgent:  truncate(entry.UserAgent, 255),
+		Before:     auditJSON(entry.Before),
+		After:      auditJSON(entry.After),
+		CreatedAt:  time.Now(),
+	}
+	if len(entry.Detail) > 0 {
+		event.Detail = auditJSON(entry.Detail)
+	}
+	return event
+}
+
+// auditClient returns the IP and user agent of a client for audit entries
+func auditClient(entry AuditEntry, client ClientInfo) AuditEntry {
+	entry.IP = client.IP
+	entry.UserAgent = client.UserAgent
+	return entry
+}
+
+// auditLoginFailure records a failed login attempt against an account subject such as "phone:+86..."
+func auditLoginFailure(subject, method, reason string, client ClientInfo) {
+	RecordAudit(auditClient(AuditEntry{
+		Action:   AuditLoginFailed,
+		TargetID: subject,
+		Detail:   map[string]interface{}{"method": method, "reason": reason},
+	}, client))
+}
+
+// auditCreditLog records a credit balance change within its transaction; actorID is empty
+// for changes made by the system. An audit failure rolls the change back.
+func auditCreditLog(tx *gorm.DB, creditLog *models.CreditLog, actorID string) error {
+	return recordAuditTx(tx, AuditEntry{
+		ActorID:    actorID,
+		Action:     AuditCreditsPrefix + creditLog.Type,
+		TargetType: AuditTargetUser,
+		TargetID:   creditLog.UserID,
+		Before:     map[string]interface{}{"credits": creditLog.Balance - creditLog.Amount},
+		After:      map[string]interface{}{"credits": creditLog.Balance},
+		Detail: map[string]interface{}{
+			"amount":        creditLog.Amount,
+			"ref_id":        creditLog.RefID,
+			"remark":        creditLog.Remark,
+			"credit_log_id": creditLog.ID,
+		},
+	})
+}
+
+func auditJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// chainAuditEvent links the event to the head of the chain and saves it with write
+// The chain head is locked until db's transaction ends, so that concurrent writers are serialized.
+// db is models.DB or the transaction of the audited change, in which case a savepoint is used.
+func chainAuditEvent(db *gorm.DB, event *models.AuditEvent, write func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.AuditChain{ID: models.AuditChainID}).Error; err != nil {
+			return err
+		}
+
+		var head models.AuditChain
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, models.AuditChainID).Error; err != nil {
+			return err
+		}
+
+		// The database keeps milliseconds, hash the value that will be read back
+		event.CreatedAt = event.CreatedAt.Truncate(time.Millisecond)
+		event.Seq = head.LastSeq + 1
+		event.PrevHash = head.LastHash
+		event.Hash = auditHash(event)
+
+		if err := write(tx); err != nil {
+			return err
+		}
+
+		return tx.Model(&head).Updates(map[string]interface{}{
+			"last_seq":  event.Seq,
+			"last_hash": event.Hash,
+		}).Error
+	})
+}
+
+// auditHash computes the chain hash of an event from its contents and the previous hash
+func auditHash(event *models.AuditEvent) string {
+	fields, _ := json.Marshal([]string{
+		event.PrevHash,
+		strconv.FormatInt(event.Seq, 10),
+		event.ID,
+		event.ActorID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.IP,
+		event.UserAgent,
+		event.Before,
+		event.After,
+		event.Detail,
+		strconv.FormatInt(event.CreatedAt.UnixMilli(), 10),
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// ListAuditEvents queries the audit log, newest first
+func ListAuditEvents(filter AuditFilter) ([]models.AuditEvent, int64, error) {
+	query := models.DB.Model(&models.AuditEvent{})
+
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		if filter.Action[len(filter.Action)-1] == '.' {
+			query = query.Where("action LIKE ?", filter.Action+"%")
+		} else {
+			query = query.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	var events []models.AuditEvent
+	offset := (filter.Page - 1) * filter.PageSize
+	if err := query.Order("seq DESC").Offset(offset).Limit(filter.PageSize).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// VerifyAuditChain walks the whole chain and checks sequence numbers, links and hashes
+func VerifyAuditChain() (*AuditVerification, error) {
+	var head models.AuditChain
+	if err := models.DB.Limit(1).Find(&head, models.AuditChainID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load audit chain head: %w", err)
+	}
+
+	result := &AuditVerification{Valid: true, HeadSeq: head.LastSeq, HeadHash: head.LastHash}
+	broken := func(seq int64, reason string) (*AuditVerification, error) {
+		result.Valid = false
+		result.BrokenAtSeq = seq
+		result.Reason = reason
+		return result, nil
+	}
+
+	var lastSeq int64
+	lastHash := ""
+	for {
+		var events []models.AuditEvent
+		if err := models.DB.Where("seq > ?", lastSeq).Order("seq ASC").
+			Limit(auditVerifyBatchSize).Find(&events).Error; err != nil {
+			return nil, fmt.Errorf("failed to load audit events: %w", err)
+		}
+
+		for i := range events {
+			event := &events[i]
+			switch {
+			case event.Seq != lastSeq+1:
+				return broken(lastSeq+1, "event missing from the chain")
+			case event.PrevHash != lastHash:
+				return broken(event.Seq, "previous hash does not match")
+			case auditHash(event) != event.Hash:
+				return broken(event.Seq, "event content does not match its hash")
+			}
+			lastSeq = event.Seq
+			lastHash = event.Hash
+			result.Checked++
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			break
+		}
+	}
+
+	if lastSeq != head.LastSeq || lastHash != head.LastHash {
+		return broken(lastSeq+1, "chain does not end at the recorded head")
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+func TestAuditEventSeqIsUnique(t *testing.T) {
+	setupTestDB(t)
+
+	RecordAudit(AuditEntry{Action: "test.first"})
+	var head models.AuditEvent
+	if err := models.DB.Order("seq DESC").First(&head).Error; err != nil {
+		t.Fatalf("load event: %v", err)
+	}
+
+	// A writer that skipped the head lock would reuse the position of the last event
+	duplicate := newAuditEvent(AuditEntry{Action: "test.second"})
+	duplicate.ID = uuid.New().String()
+	duplicate.Seq = head.Seq
+	if err := models.DB.Create(duplicate).Error; err == nil {
+		t.Fatal("event with a duplicate seq was saved")
+	}
+
+	result, err := VerifyAuditChain()
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !result.Valid {
+		t.Errorf("chain broken: %s", result.Reason)
+	}
+}
@@ -10,17 +10,20 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // UserClaims represents the JWT claims for user authentication
 type UserClaims struct {
-	UserID string `json:"user_id"`
-	Phone  string `json:"phone"`
+	UserID       string `json:"user_id"`
+	Phone        string `json:"phone"`
+	SessionID    string `json:"sid"`
+	TokenVersion int    `json:"ver"`
 	jwt.RegisteredClaims
 }
 
-// GenerateUserToken generates a JWT token for the user
-func GenerateUserToken(user *models.User) (string, error) {
+// GenerateUserToken generates a short-lived access token bound to a session
+func GenerateUserToken(user *models.User, sessionID string) (string, error) {
 	cfg := config.Cfg
 
 	if cfg.AuthJWTSecret == "" {
@@ -28,11 +31,13 @@ func GenerateUserToken(user *models.User) (string, error) {
 	}
 
 	now := time.Now()
-	expiresAt := now.Add(time.Duration(cfg.AuthJWTExpireHours) * time.Hour)
+	expiresAt := now.Add(time.Duration(cfg.AuthAccessTokenMinutes) * time.Minute)
 
 	claims := UserClaims{
-		UserID: user.ID,
-		Phone:  user.Phone,
+		UserID:       user.ID,
+		Phone:        user.PhoneNumber(),
+		SessionID:    sessionID,
+		TokenVersion: user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -52,6 +57,8 @@ func GenerateUserToken(user *models.User) (string, error) {
 }
 
 // ValidateUserToken validates a JWT token and returns the claims
+// The token is rejected if its session was revoked, the user's token version changed
+// or the user has been disabled
 func ValidateUserToken(tokenString string) (*UserClaims, error) {
 	cfg := config.Cfg
 
@@ -70,20 +77,33 @@ func ValidateUserToken(tokenString string) (*UserClaims, error) {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*UserClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	return nil, errors.New("invalid token")
+	if err := checkSessionState(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
 }
 
 // LoginWithPhone logs in a user with phone verification code
 // Creates a new user if the phone number is not registered
-func LoginWithPhone(phone, code string) (*models.User, string, error) {
-	// Verify the code
-	_, err := VerifyCode(phone, code, models.CodePurposeLogin)
+func LoginWithPhone(phone, code string, client ClientInfo) (*models.User, *TokenPair, error) {
+	// Verify the code, unless the phone or IP is locked out
+	subject := phoneLoginSubject(phone)
+	err := guardLogin(subject, client.IP, func() error {
+		_, err := VerifyCode(phone, code, models.CodePurposeLogin)
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			auditLoginFailure(subject, "phone", authErr.Code, client)
+		}
+		return err
+	})
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// Find or create user
@@ -92,15 +112,29 @@ func LoginWithPhone(phone, code string) (*models.User, string, error) {
 
 	if result.Error != nil {
 		// User doesn't exist, create new user with initial credits
-		initialCredits := config.Cfg.CreditsInitial
 		user = models.User{
-			ID:      uuid.New().String(),
-			Phone:   phone,
-			Credits: initialCredits,
-			Status:  models.UserStatusActive,
+			ID:     uuid.New().String(),
+			Phone:  &phone,
+			Status: models.UserStatusActive,
 		}
-		if err := models.DB.Create(&user).Error; err != nil {
-			return nil, "", fmt.Errorf("failed to create user: %w", err)
+		if err := createUser(&user); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return completeLogin(&user, client, "phone")
+}
+
+// createUser registers a new user and grants the registration bonus
+func createUser(user *models.User) error {
+	applyInitialAccess(user)
+
+	initialCredits := config.Cfg.CreditsInitial
+	user.Credits = initialCredits
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
 		}
 
 		// Record credit log for registration bonus
@@ -112,28 +146,48 @@ func LoginWithPhone(phone, code string) (*models.User, string, error) {
 			Type:    "register",
 			Remark:  "Registration bonus",
 		}
-		models.DB.Create(&creditLog)
+		if err := tx.Create(&creditLog).Error; err != nil {
+			return err
+		}
+		return auditCreditLog(tx, &creditLog, "")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
 	}
 
+	return nil
+}
+
+// completeLogin checks that the user may log in, records the login and starts a session
+// method describes how the user authenticated and is recorded in the audit log
+func completeLogin(user *models.User, client ClientInfo, method string) (*models.User, *TokenPair, error) {
 	// Check if user is disabled
 	if user.Status == models.UserStatusDisabled {
-		return nil, "", errors.New("user account is disabled")
+		return nil, nil, errors.New("user account is disabled")
 	}
 
 	// Update last login time
 	now := time.Now()
-	if err := models.DB.Model(&user).Update("last_login_at", now).Error; err != nil {
-		return nil, "", fmt.Errorf("failed to update last login time: %w", err)
+	if err := models.DB.Model(user).Update("last_login_at", now).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to update last login time: %w", err)
 	}
 	user.LastLoginAt = &now
 
-	// Generate token
-	token, err := GenerateUserToken(&user)
+	RecordAudit(auditClient(AuditEntry{
+		ActorID:    user.ID,
+		Action:     AuditLogin,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+		Detail:     map[string]interface{}{"method": method},
+	}, client))
+
+	// Start a session and issue tokens
+	tokens, err := CreateSession(user, client)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return &user, token, nil
+	return user, tokens, nil
 }
 
 // GetUserByID retrieves a user by ID
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"backend-server/config"
+)
+
+var ErrCircuitOpen = errors.New("inference service is unavailable, circuit is open")
+
+// CircuitState is the state of the inference circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // Requests flow normally
+	CircuitOpen     CircuitState = "open"      // Requests are rejected and the worker stops taking tasks
+	CircuitHalfOpen CircuitState = "half_open" // One trial request decides whether to close or reopen
+)
+
+// maxOpenBackoff caps how many times the open period is doubled after failed trials
+const maxOpenBackoff = 10
+
+// CircuitStatus describes the inference circuit breaker
+type CircuitStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"` // When an open circuit lets a trial request through
+	LastError           string       `json:"last_error,omitempty"`
+	Opens               int64        `json:"opens"` // Times the circuit opened since startup
+	Successes           int64        `json:"successes"`
+	Failures            int64        `json:"failures"`
+	Rejected            int64        `json:"rejected"` // Requests refused while open
+}
+
+// circuitBreaker stops sending requests to the inference service after consecutive failures,
+// so tasks wait in the queue instead of each running into the request timeout
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int // Consecutive failures
+	openedAt  time.Time
+	openFor   time.Duration
+	trial     bool      // A half-open trial request is in progress
+	grantedAt time.Time // When a half-open circuit last let the worker take a trial task
+	lastError string
+
+	opens     int64
+	successes int64
+	total     int64 // Failures since startup
+	rejected  int64
+}
+
+var breaker = &circuitBreaker{state: CircuitClosed}
+
+func breakerOpenDuration() time.Duration {
+	return time.Duration(max(config.Cfg.InferenceBreakerOpenSeconds, 1)) * time.Second
+}
+
+// isBackendFailure reports whether err means the inference service, not the request, is at fault
+func isBackendFailure(err error) bool {
+	var failure *backendError
+	return errors.As(err, &failure) || errors.Is(err, ErrNoInferenceBackend)
+}
+
+// halfOpenIfDue moves an open circuit to half-open once its open period has passed
+// Callers must hold b.mu
+func (b *circuitBreaker) halfOpenIfDue() {
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.openFor {
+		b.state = CircuitHalfOpen
+		b.trial = false
+		b.grantedAt = time.Time{}
+		log.Println("Inference circuit half-open, sending a trial request")
+	}
+}
+
+// ready reports whether the worker should take a task; an open circuit lets one trial task through
+// once its open period has passed. If the trial task never reaches the inference service, e.g.
+// because its files are missing, another one is let through after the next open period.
+func (b *circuitBreaker) ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.halfOpenIfDue()
+	switch b.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if b.trial || (!b.grantedAt.IsZero() && time.Since(b.grantedAt) < breakerOpenDuration()) {
+			return false
+		}
+		b.grantedAt = time.Now()
+	}
+	return true
+}
+
+// allow reserves the right to send a request, returning ErrCircuitOpen if the circuit refuses it
+// Every allowed request must be followed by record
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.halfOpenIfDue()
+	switch b.state {
+	case CircuitOpen:
+		b.rejected++
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.trial {
+			b.rejected++
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// record updates the circuit with the outcome of a request
+// Errors caused by the request itself count as success since the service did respond. A trial
+// request is only decided by a success or by a failure of a request that never failed before:
+// otherwise a task the service can not handle would keep the circuit open for everyone.
+func (b *circuitBreaker) record(err error, retried bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasTrial := b.state == CircuitHalfOpen && b.trial
+	if wasTrial {
+		b.trial = false
+	}
+
+	failed := isBackendFailure(err)
+	if wasTrial && err != nil && (!failed || retried) {
+		// Inconclusive, let the next task try right away
+		b.grantedAt = time.Time{}
+		if failed {
+			b.total++
+			b.lastError = err.Error()
+		}
+		log.Printf("Inference circuit trial inconclusive, the request may be at fault: %v", err)
+		return
+	}
+
+	if !failed {
+		b.successes++
+		b.failures = 0
+		if b.state != CircuitClosed {
+			b.state = CircuitClosed
+			b.openFor = 0
+			log.Println("Inference circuit closed")
+		}
+		return
+	}
+
+	b.total++
+	b.failures++
+	b.lastError = err.Error()
+
+	threshold := config.Cfg.InferenceBreakerThreshold
+	switch {
+	case wasTrial:
+		b.open(min(b.openFor*2, breakerOpenDuration()*maxOpenBackoff))
+	case b.state == CircuitClosed && threshold > 0 && b.failures >= threshold:
+		b.open(breakerOpenDuration())
+	}
+}
+
+// open rejects requests for the given period
+// Callers must hold b.mu
+func (b *circuitBreaker) open(period time.Duration) {
+	b.state = CircuitOpen
+	b.openedAt = time.Now()
+	b.openFor = period
+	b.opens++
+	log.Printf("Inference circuit open for %s after %d consecutive failures: %s", period, b.failures, b.lastError)
+}
+
+// isClosed reports whether requests flow normally
+func (b *circuitBreaker) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == CircuitClosed
+}
+
+// GetCircuitStatus returns the state and counters of the inference circuit breaker
+func GetCircuitStatus() CircuitStatus {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.halfOpenIfDue()
+	status := CircuitStatus{
+		State:               breaker.state,
+		ConsecutiveFailures: breaker.failures,
+		LastError:           breaker.lastError,
+		Opens:               breaker.opens,
+		Successes:           breaker.successes,
+		Failures:            breaker.total,
+		Rejected:            breaker.rejected,
+	}
+	if breaker.state != CircuitClosed {
+		openedAt := breaker.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if breaker.state == CircuitOpen {
+		retryAt := breaker.openedAt.Add(breaker.openFor)
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// requeueOnFailure reports whether a task whose inference failed with err should go back to the
+// queue rather than fail: the service is unavailable, or the failure opened the circuit
+func requeueOnFailure(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrNoInferenceBackend) {
+		return true
+	}
+	return isBackendFailure(err) && !breaker.isClosed()
+}
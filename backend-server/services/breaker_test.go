@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setTestBreaker replaces the circuit breaker with one in the given state
+func setTestBreaker(t *testing.T, state CircuitState) *circuitBreaker {
+	t.Helper()
+
+	previous := breaker
+	breaker = &circuitBreaker{state: state, openFor: breakerOpenDuration()}
+	t.Cleanup(func() { breaker = previous })
+	return breaker
+}
+
+func TestBreakerTrialDecidedOnlyByFreshFailures(t *testing.T) {
+	setupTestDB(t)
+	b := setTestBreaker(t, CircuitHalfOpen)
+	unavailable := &backendError{errors.New("inference API returned status 503")}
+
+	// A task that failed before, or a failure caused by the request, leaves the trial open
+	for _, outcome := range []struct {
+		err     error
+		retried bool
+	}{
+		{unavailable, true},
+		{errors.New("inference API returned status 422"), false},
+	} {
+		if !b.ready() || b.allow() != nil {
+			t.Fatal("half-open circuit did not let a trial through")
+		}
+		b.record(outcome.err, outcome.retried)
+		if b.state != CircuitHalfOpen || b.openFor != breakerOpenDuration() {
+			t.Fatalf("after %v (retried %v): state %s, open for %s, want an undecided trial",
+				outcome.err, outcome.retried, b.state, b.openFor)
+		}
+	}
+
+	// A new task failing reopens the circuit with a longer backoff
+	if !b.ready() || b.allow() != nil {
+		t.Fatal("half-open circuit did not let a trial through")
+	}
+	b.record(unavailable, false)
+	if b.state != CircuitOpen || b.openFor != 2*breakerOpenDuration() {
+		t.Fatalf("state %s, open for %s, want reopened for twice as long", b.state, b.openFor)
+	}
+
+	b.openedAt = time.Now().Add(-b.openFor)
+	if !b.ready() || b.allow() != nil {
+		t.Fatal("circuit did not half-open after its open period")
+	}
+	b.record(nil, true)
+	if b.state != CircuitClosed {
+		t.Fatalf("state %s, want closed after a successful trial", b.state)
+	}
+}
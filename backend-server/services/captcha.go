@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"backend-server/config"
+)
+
+var captchaHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// CaptchaEnabled reports whether a captcha provider is configured
+func CaptchaEnabled() bool {
+	return config.Cfg.CaptchaVerifyURL != "" && config.Cfg.CaptchaSecret != ""
+}
+
+// VerifyCaptcha verifies a captcha response token with the provider's siteverify endpoint
+// The protocol is shared by Cloudflare Turnstile, hCaptcha and reCAPTCHA
+func VerifyCaptcha(token, remoteIP string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	form := url.Values{
+		"secret":   {config.Cfg.CaptchaSecret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	resp, err := captchaHTTPClient.PostForm(config.Cfg.CaptchaVerifyURL, form)
+	if err != nil {
+		return false, fmt.Errorf("failed to call captcha provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode captcha response: %w", err)
+	}
+
+	return result.Success, nil
+}
@@ -9,35 +9,58 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// IsPhoneWhitelisted checks if a phone number is in the whitelist
-func IsPhoneWhitelisted(phone string) bool {
-	for _, p := range config.Cfg.PhoneWhitelist {
-		if p == phone {
-			return true
-		}
-	}
-	return false
-}
+// errInsufficientCredits is returned by deductCreditsTx when the user can not afford a deduction
+var errInsufficientCredits = errors.New("insufficient credits")
 
 // DeductCredits deducts credits from user for a task
 // Returns error if insufficient credits
-// Skips deduction for whitelisted users
+// Skips deduction for users whose plan has unlimited credits
 func DeductCredits(userID, taskID string) error {
+	return deductCredits(userID, taskID, config.Cfg.CreditsPerTask)
+}
+
+// ScriptCredits returns the credits a script task with the given number of lines costs
+func ScriptCredits(lines int) int {
+	return config.Cfg.CreditsPerTask * lines
+}
+
+// deductScriptCreditsTx deducts the credits of a script task, one task's worth per line, within
+// a transaction. Returns ErrScriptCreditsNeeded if the user can not afford it.
+func deductScriptCreditsTx(tx *gorm.DB, userID, taskID string, lines int) error {
+	var user models.User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if HasUnlimitedCredits(&user) {
+		return nil
+	}
+
+	creditLog, err := deductCreditsTx(tx, userID, ScriptCredits(lines), taskID, "TTS script consumption")
+	if errors.Is(err, errInsufficientCredits) {
+		return ErrScriptCreditsNeeded
+	}
+	if err != nil {
+		return err
+	}
+	return auditCreditLog(tx, creditLog, userID)
+}
+
+// deductCredits deducts the given amount from a user for a task
+func deductCredits(userID, taskID string, creditsToDeduct int) error {
 	// Get user
 	var user models.User
 	if err := models.DB.First(&user, "id = ?", userID).Error; err != nil {
 		return fmt.Errorf("user not found: %w", err)
 	}
 
-	// Check if user is whitelisted
-	if IsPhoneWhitelisted(user.Phone) {
+	// Check if the user's plan has unlimited credits
+	if HasUnlimitedCredits(&user) {
 		return nil
 	}
 
-	creditsToDeduct := config.Cfg.CreditsPerTask
-
 	// Check if user has enough credits
 	if user.Credits < creditsToDeduct {
 		return errors.New("insufficient credits")
@@ -45,73 +68,140 @@ func DeductCredits(userID, taskID string) error {
 
 	// Deduct credits using transaction
 	return models.DB.Transaction(func(tx *gorm.DB) error {
-		// Lock the user row and deduct credits
-		result := tx.Model(&models.User{}).
-			Where("id = ? AND credits >= ?", userID, creditsToDeduct).
-			Update("credits", gorm.Expr("credits - ?", creditsToDeduct))
-
-		if result.Error != nil {
-			return result.Error
-		}
-		if result.RowsAffected == 0 {
-			return errors.New("insufficient credits")
-		}
-
-		// Get updated balance
-		var updatedUser models.User
-		if err := tx.First(&updatedUser, "id = ?", userID).Error; err != nil {
+		creditLog, err := deductCreditsTx(tx, userID, creditsToDeduct, taskID, "TTS task consumption")
+		if err != nil {
 			return err
 		}
-
-		// Record credit log
-		creditLog := models.CreditLog{
-			ID:      uuid.New().String(),
-			UserID:  userID,
-			Amount:  -creditsToDeduct,
-			Balance: updatedUser.Credits,
-			Type:    "consume",
-			RefID:   taskID,
-			Remark:  "TTS task consumption",
-		}
-		return tx.Create(&creditLog).Error
+		return auditCreditLog(tx, creditLog, userID)
 	})
 }
 
+// deductCreditsTx deducts credits for a task within a transaction and returns the credit log it recorded
+func deductCreditsTx(tx *gorm.DB, userID string, creditsToDeduct int, taskID, remark string) (*models.CreditLog, error) {
+	// Lock the user row and deduct credits
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND credits >= ?", userID, creditsToDeduct).
+		Update("credits", gorm.Expr("credits - ?", creditsToDeduct))
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInsufficientCredits
+	}
+
+	// Get updated balance
+	var updatedUser models.User
+	if err := tx.First(&updatedUser, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	// Record credit log
+	creditLog := &models.CreditLog{
+		ID:      uuid.New().String(),
+		UserID:  userID,
+		Amount:  -creditsToDeduct,
+		Balance: updatedUser.Credits,
+		Type:    "consume",
+		RefID:   taskID,
+		Remark:  remark,
+	}
+	if err := tx.Create(creditLog).Error; err != nil {
+		return nil, err
+	}
+	return creditLog, nil
+}
+
 // AddCredits adds credits to user (for recharge)
 func AddCredits(userID string, amount int, orderID, remark string) error {
+	return addCredits(userID, amount, "recharge", orderID, remark)
+}
+
+// RefundTaskCredits returns the credits a task consumed and has not been refunded yet
+// The balance of the task is computed and refunded in one transaction holding the user row,
+// so concurrent refunds of the same task can not both pay out
+func RefundTaskCredits(task *models.Task, remark string) error {
 	return models.DB.Transaction(func(tx *gorm.DB) error {
-		// Add credits
-		result := tx.Model(&models.User{}).
-			Where("id = ?", userID).
-			Update("credits", gorm.Expr("credits + ?", amount))
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", task.UserID).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
 
-		if result.Error != nil {
-			return result.Error
+		outstanding, err := taskCreditBalance(tx, task.ID)
+		if err != nil {
+			return err
 		}
-		if result.RowsAffected == 0 {
-			return errors.New("user not found")
+		if outstanding <= 0 {
+			return nil
 		}
 
-		// Get updated balance
-		var user models.User
-		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		creditLog, err := addCreditsTx(tx, task.UserID, outstanding, "refund", task.ID, remark)
+		if err != nil {
 			return err
 		}
+		return auditCreditLog(tx, creditLog, "")
+	})
+}
+
+// taskCreditBalance returns the credits a task consumed minus those refunded for it
+func taskCreditBalance(tx *gorm.DB, taskID string) (int, error) {
+	var balance int64
+	if err := tx.Model(&models.CreditLog{}).
+		Where("ref_id = ? AND type IN ?", taskID, []string{"consume", "refund"}).
+		Select("COALESCE(SUM(-amount), 0)").
+		Scan(&balance).Error; err != nil {
+		return 0, fmt.Errorf("failed to look up task credits: %w", err)
+	}
+	return int(balance), nil
+}
 
-		// Record credit log
-		creditLog := models.CreditLog{
-			ID:      uuid.New().String(),
-			UserID:  userID,
-			Amount:  amount,
-			Balance: user.Credits,
-			Type:    "recharge",
-			RefID:   orderID,
-			Remark:  remark,
+// addCredits adds credits to a user and records a credit log of the given type
+func addCredits(userID string, amount int, logType, refID, remark string) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		creditLog, err := addCreditsTx(tx, userID, amount, logType, refID, remark)
+		if err != nil {
+			return err
 		}
-		return tx.Create(&creditLog).Error
+		return auditCreditLog(tx, creditLog, "")
 	})
 }
 
+// addCreditsTx adds credits to a user within a transaction and returns the credit log it recorded
+func addCreditsTx(tx *gorm.DB, userID string, amount int, logType, refID, remark string) (*models.CreditLog, error) {
+	// Add credits
+	result := tx.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("credits", gorm.Expr("credits + ?", amount))
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("user not found")
+	}
+
+	// Get updated balance
+	var user models.User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	// Record credit log
+	creditLog := &models.CreditLog{
+		ID:      uuid.New().String(),
+		UserID:  userID,
+		Amount:  amount,
+		Balance: user.Credits,
+		Type:    logType,
+		RefID:   refID,
+		Remark:  remark,
+	}
+	if err := tx.Create(creditLog).Error; err != nil {
+		return nil, err
+	}
+	return creditLog, nil
+}
+
 // GetUserCredits returns user's current credits
 func GetUserCredits(userID string) (int, error) {
 	var user models.User
@@ -122,17 +212,22 @@ func GetUserCredits(userID string) (int, error) {
 }
 
 // CheckCredits checks if user has enough credits for a task
-// Returns true if user is whitelisted or has enough credits
+// Returns true if the user's plan has unlimited credits or the user has enough credits
 func CheckCredits(userID string) (bool, error) {
+	return CheckCreditsFor(userID, config.Cfg.CreditsPerTask)
+}
+
+// CheckCreditsFor checks if user has the given amount of credits, or a plan with unlimited credits
+func CheckCreditsFor(userID string, amount int) (bool, error) {
 	var user models.User
 	if err := models.DB.First(&user, "id = ?", userID).Error; err != nil {
 		return false, err
 	}
 
-	// Whitelisted users always have "enough" credits
-	if IsPhoneWhitelisted(user.Phone) {
+	// Users with unlimited credits always have "enough" credits
+	if HasUnlimitedCredits(&user) {
 		return true, nil
 	}
 
-	return user.Credits >= config.Cfg.CreditsPerTask, nil
+	return user.Credits >= amount, nil
 }
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"sync"
+	"testing"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// refundLogs counts the refunds recorded for a task
+func refundLogs(t *testing.T, taskID string) int64 {
+	t.Helper()
+
+	var count int64
+	if err := models.DB.Model(&models.CreditLog{}).
+		Where("ref_id = ? AND type = ?", taskID, "refund").
+		Count(&count).Error; err != nil {
+		t.Fatalf("count refunds: %v", err)
+	}
+	return count
+}
+
+func TestRefundTaskCreditsOnce(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 100)
+	task := &models.Task{ID: uuid.New().String(), UserID: user.ID}
+
+	if err := DeductCredits(user.ID, task.ID); err != nil {
+		t.Fatalf("deduct: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := RefundTaskCredits(task, "test"); err != nil {
+			t.Fatalf("refund %d: %v", i+1, err)
+		}
+	}
+
+	if credits, _ := GetUserCredits(user.ID); credits != 100 {
+		t.Errorf("credits = %d, want 100", credits)
+	}
+	if n := refundLogs(t, task.ID); n != 1 {
+		t.Errorf("%d refunds recorded, want 1", n)
+	}
+
+	// Credits charged again, e.g. when the task is re-queued, can be refunded again
+	if err := DeductCredits(user.ID, task.ID); err != nil {
+		t.Fatalf("deduct again: %v", err)
+	}
+	if err := RefundTaskCredits(task, "test"); err != nil {
+		t.Fatalf("refund after second charge: %v", err)
+	}
+	if credits, _ := GetUserCredits(user.ID); credits != 100 {
+		t.Errorf("credits = %d after second refund, want 100", credits)
+	}
+}
+
+func TestRefundTaskCreditsConcurrent(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 100)
+	task := &models.Task{ID: uuid.New().String(), UserID: user.ID}
+	if err := DeductCredits(user.ID, task.ID); err != nil {
+		t.Fatalf("deduct: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Losing writers may fail with a busy database, they must not pay out
+			RefundTaskCredits(task, "test")
+		}()
+	}
+	wg.Wait()
+
+	if credits, _ := GetUserCredits(user.ID); credits != 100 {
+		t.Errorf("credits = %d, want 100 (%d per task refunded once)", credits, config.Cfg.CreditsPerTask)
+	}
+	if n := refundLogs(t, task.ID); n != 1 {
+		t.Errorf("%d refunds recorded, want 1", n)
+	}
+}
+
+func TestCreditChangeAuditedInSameTransaction(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 100)
+
+	if err := DeductCredits(user.ID, "task-1"); err != nil {
+		t.Fatalf("deduct: %v", err)
+	}
+	var events int64
+	models.DB.Model(&models.AuditEvent{}).
+		Where("action = ? AND target_id = ?", AuditCreditsPrefix+"consume", user.ID).
+		Count(&events)
+	if events != 1 {
+		t.Fatalf("%d audit events for the deduction, want 1", events)
+	}
+
+	// Without an audit log the change is rolled back instead of going unrecorded
+	if err := models.DB.Migrator().DropTable(&models.AuditEvent{}); err != nil {
+		t.Fatalf("drop audit table: %v", err)
+	}
+	if err := DeductCredits(user.ID, "task-2"); err == nil {
+		t.Fatal("deduction succeeded without an audit event")
+	}
+	if credits, _ := GetUserCredits(user.ID); credits != 100-config.Cfg.CreditsPerTask {
+		t.Errorf("credits = %d, want the failed deduction rolled back", credits)
+	}
+}
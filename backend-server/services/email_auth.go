@@ -0,0 +1,416 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidEmail      = errors.New("invalid email address")
+	ErrEmailTaken        = errors.New("email is already bound to another account")
+	ErrPhoneTaken        = errors.New("phone number is already bound to another account")
+	ErrInvalidEmailToken = errors.New("invalid or expired link")
+)
+
+// dummyPasswordHash is compared against when the account does not exist or has no password
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("indextts-dummy-password"), bcrypt.DefaultCost)
+
+// NormalizeEmail validates an email address and returns it lowercased
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" || len(email) > 255 {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(email), nil
+}
+
+// ValidatePassword checks the password length policy
+// bcrypt only uses the first 72 bytes, longer passwords are rejected rather than silently truncated
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < config.Cfg.PasswordMinLength {
+		return fmt.Errorf("password must be at least %d characters", config.Cfg.PasswordMinLength)
+	}
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// checkEmailRateLimit limits how many emails can be requested per address and per client IP
+func checkEmailRateLimit(email, ip string) error {
+	cfg := config.Cfg
+
+	limits := []RateLimit{{Key: "email:addr:" + email, Limit: cfg.EmailLimitPerAddressHour, Window: time.Hour}}
+	if ip != "" {
+		limits = append([]RateLimit{{Key: "email:ip:" + ip, Limit: cfg.EmailLimitPerIPHour, Window: time.Hour}}, limits...)
+	}
+
+	denied, retryAfter, err := rateLimiter.AllowAll(limits)
+	if err != nil {
+		return err
+	}
+	if denied != nil {
+		log.Printf("[SECURITY] Email rate limit hit for %s", denied.Key)
+		authErr := newAuthError(AuthErrRateLimited,
+			fmt.Sprintf("too many emails requested, please try again in %d seconds", int(retryAfter.Seconds())+1))
+		authErr.RetryAfter = retryAfter
+		return authErr
+	}
+	return nil
+}
+
+// findUserByEmail looks up the user bound to an email address
+func findUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := models.DB.First(&user, "email = ?", email).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// sendEmailToken creates a single-use token and mails a link containing it
+func sendEmailToken(token *models.EmailToken, subject, intro string) error {
+	raw, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	token.ID = uuid.New().String()
+	token.TokenHash = hashToken(raw)
+	token.ExpiresAt = time.Now().Add(time.Duration(config.Cfg.EmailTokenExpireMinutes) * time.Minute)
+
+	if err := models.DB.Create(token).Error; err != nil {
+		return fmt.Errorf("failed to save email token: %w", err)
+	}
+
+	link := fmt.Sprintf("%s/?email_token=%s&purpose=%s",
+		config.Cfg.EmailLinkBaseURL, url.QueryEscape(raw), token.Purpose)
+	body := fmt.Sprintf("%s\n\n%s\n\n该链接 %d 分钟内有效，且只能使用一次。如果这不是你本人的操作，请忽略此邮件。\n",
+		intro, link, config.Cfg.EmailTokenExpireMinutes)
+
+	if err := SendMail(MailMessage{To: token.Email, Subject: subject, Body: body}); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// RegisterWithEmail starts an email + password registration by mailing a verification link
+// The account is only created once the link is opened. If the email is already registered,
+// a notice is mailed instead so that the response does not reveal which emails have accounts
+func RegisterWithEmail(email, password, ip string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	if err := checkEmailRateLimit(email, ip); err != nil {
+		return err
+	}
+
+	if _, err := findUserByEmail(email); err == nil {
+		return SendMail(MailMessage{
+			To:      email,
+			Subject: "IndexTTS 账号已存在",
+			Body:    "有人尝试使用此邮箱注册 IndexTTS，但该邮箱已绑定账号。你可以直接登录，或使用\"忘记密码\"重置密码。\n",
+		})
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return sendEmailToken(&models.EmailToken{
+		Email:        email,
+		Purpose:      models.EmailTokenRegister,
+		PasswordHash: passwordHash,
+	}, "验证你的 IndexTTS 邮箱", "请点击下面的链接完成注册：")
+}
+
+// RequestMagicLink mails a passwordless login link
+// Opening the link creates an account if the email is not registered yet
+func RequestMagicLink(email, ip string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := checkEmailRateLimit(email, ip); err != nil {
+		return err
+	}
+
+	token := &models.EmailToken{Email: email, Purpose: models.EmailTokenMagicLink}
+	if user, err := findUserByEmail(email); err == nil {
+		token.UserID = user.ID
+	}
+
+	return sendEmailToken(token, "登录 IndexTTS", "请点击下面的链接登录：")
+}
+
+// RequestPasswordReset mails a password reset link if the email is registered
+// Unknown emails are silently ignored
+func RequestPasswordReset(email, ip string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := checkEmailRateLimit(email, ip); err != nil {
+		return err
+	}
+
+	user, err := findUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	return sendEmailToken(&models.EmailToken{
+		Email:   email,
+		UserID:  user.ID,
+		Purpose: models.EmailTokenPasswordReset,
+	}, "重置 IndexTTS 密码", "请点击下面的链接设置新密码：")
+}
+
+// RequestEmailBind mails a verification link that binds an email to the user
+// An optional password is set together with the email if given
+func RequestEmailBind(userID, email, password, ip string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	passwordHash := ""
+	if password != "" {
+		if err := ValidatePassword(password); err != nil {
+			return err
+		}
+		if passwordHash, err = hashPassword(password); err != nil {
+			return err
+		}
+	}
+	if err := checkEmailRateLimit(email, ip); err != nil {
+		return err
+	}
+
+	if existing, err := findUserByEmail(email); err == nil && existing.ID != userID {
+		return ErrEmailTaken
+	}
+
+	return sendEmailToken(&models.EmailToken{
+		Email:        email,
+		UserID:       userID,
+		Purpose:      models.EmailTokenBind,
+		PasswordHash: passwordHash,
+	}, "绑定 IndexTTS 邮箱", "请点击下面的链接将此邮箱绑定到你的账号：")
+}
+
+// consumeEmailToken marks a token as used and returns it
+// The update is conditional so that a token can only be consumed once
+func consumeEmailToken(raw string, purposes ...models.EmailTokenPurpose) (*models.EmailToken, error) {
+	var token models.EmailToken
+	if err := models.DB.First(&token, "token_hash = ? AND purpose IN ?", hashToken(raw), purposes).Error; err != nil {
+		return nil, ErrInvalidEmailToken
+	}
+	if !token.IsValid() {
+		return nil, ErrInvalidEmailToken
+	}
+
+	now := time.Now()
+	result := models.DB.Model(&models.EmailToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidEmailToken
+	}
+	token.UsedAt = &now
+
+	return &token, nil
+}
+
+// VerifyEmailToken consumes a registration, bind or magic link token and logs the user in
+func VerifyEmailToken(raw string, client ClientInfo) (*models.User, *TokenPair, error) {
+	token, err := consumeEmailToken(raw, models.EmailTokenRegister, models.EmailTokenBind, models.EmailTokenMagicLink)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user *models.User
+	switch token.Purpose {
+	case models.EmailTokenRegister, models.EmailTokenMagicLink:
+		user, err = findUserByEmail(token.Email)
+		if err == nil {
+			// Registration links are only issued for new emails; if the email was bound in the meantime,
+			// the link must not log into that account with a password its owner never chose
+			if token.Purpose == models.EmailTokenRegister {
+				return nil, nil, ErrEmailTaken
+			}
+			break
+		}
+
+		user, err = createEmailUser(token)
+		if err != nil {
+			return nil, nil, err
+		}
+
+	case models.EmailTokenBind:
+		user, err = bindEmail(token)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ResetLoginFailures(emailLoginSubject(token.Email))
+	return completeLogin(user, client, "email_link")
+}
+
+// createEmailUser registers a user from a verified email
+func createEmailUser(token *models.EmailToken) (*models.User, error) {
+	now := time.Now()
+	email := token.Email
+	user := &models.User{
+		ID:              uuid.New().String(),
+		Email:           &email,
+		PasswordHash:    token.PasswordHash,
+		EmailVerifiedAt: &now,
+		Status:          models.UserStatusActive,
+	}
+	if err := createUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// bindEmail sets the verified email (and password, if chosen) on the user that requested it
+func bindEmail(token *models.EmailToken) (*models.User, error) {
+	user, err := GetUserByID(token.UserID)
+	if err != nil {
+		return nil, ErrInvalidEmailToken
+	}
+
+	if existing, err := findUserByEmail(token.Email); err == nil && existing.ID != user.ID {
+		return nil, ErrEmailTaken
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"email":             token.Email,
+		"email_verified_at": now,
+	}
+	if token.PasswordHash != "" {
+		updates["password_hash"] = token.PasswordHash
+	}
+	if err := models.DB.Model(user).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to bind email: %w", err)
+	}
+
+	return GetUserByID(user.ID)
+}
+
+// LoginWithEmail logs in a user with email and password
+func LoginWithEmail(email, password string, client ClientInfo) (*models.User, *TokenPair, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := emailLoginSubject(email)
+	var user *models.User
+	err = guardLogin(subject, client.IP, func() error {
+		// Always run bcrypt, even for unknown emails, so that timing does not reveal registered accounts
+		found, err := findUserByEmail(email)
+		hasPassword := err == nil && found.HasPassword()
+		hash := dummyPasswordHash
+		if hasPassword {
+			hash = []byte(found.PasswordHash)
+		}
+
+		if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !hasPassword {
+			auditLoginFailure(subject, "password", AuthErrInvalidLogin, client)
+			return newAuthError(AuthErrInvalidLogin, "invalid email or password")
+		}
+		user = found
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return completeLogin(user, client, "password")
+}
+
+// ResetPassword sets a new password from a reset link and signs out all sessions
+func ResetPassword(raw, password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+
+	token, err := consumeEmailToken(raw, models.EmailTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := models.DB.Model(&models.User{}).
+		Where("id = ?", token.UserID).
+		Update("password_hash", passwordHash).Error; err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	ResetLoginFailures(emailLoginSubject(token.Email))
+	return RevokeAllSessions(token.UserID)
+}
+
+// BindPhone binds a phone number to the user after verifying an SMS code
+func BindPhone(userID, phone, code, ip string) (*models.User, error) {
+	subject := phoneLoginSubject(phone)
+	err := guardLogin(subject, ip, func() error {
+		_, err := VerifyCode(phone, code, models.CodePurposeLogin)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.User
+	if err := models.DB.First(&existing, "phone = ?", phone).Error; err == nil && existing.ID != userID {
+		return nil, ErrPhoneTaken
+	}
+
+	if err := models.DB.Model(&models.User{}).Where("id = ?", userID).Update("phone", phone).Error; err != nil {
+		return nil, fmt.Errorf("failed to bind phone: %w", err)
+	}
+
+	return GetUserByID(userID)
+}
+
+// PurgeEmailTokens removes email tokens that expired before the given time
+func PurgeEmailTokens(before time.Time) (int64, error) {
+	result := models.DB.Where("expires_at < ?", before).Delete(&models.EmailToken{})
+	return result.RowsAffected, result.Error
+}
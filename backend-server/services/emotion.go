@@ -0,0 +1,76 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"backend-server/models"
+)
+
+// EmotionSettings are the emotion controls of a task or script line
+type EmotionSettings struct {
+	Mode         models.EmotionMode
+	PromptFileID string
+	Vector       []float64
+	Alpha        *float64
+	Text         string
+}
+
+// EmotionVectorError reports an emotion vector value outside [0, 1]
+type EmotionVectorError struct {
+	Index int
+}
+
+func (e *EmotionVectorError) Error() string {
+	return "emotion_vector values must be between 0 and 1"
+}
+
+// CheckEmotion validates emotion settings for a new task of the user
+// The description text is trimmed, and cleared for modes that do not use it
+func CheckEmotion(userID string, e *EmotionSettings) error {
+	switch e.Mode {
+	case models.EmotionModePrompt:
+		if e.PromptFileID == "" {
+			return errors.New("emotion_prompt_file_id is required when emotion_mode is emotion_prompt")
+		}
+		// Validate emotion prompt file exists and belongs to the user
+		var emotionFile models.File
+		if err := models.DB.First(&emotionFile, "id = ? AND user_id = ?", e.PromptFileID, userID).Error; err != nil {
+			return errors.New("Emotion prompt file not found")
+		}
+	case models.EmotionModeVector:
+		if len(e.Vector) != 8 {
+			return errors.New("emotion_vector must have exactly 8 elements when emotion_mode is emotion_vector")
+		}
+		for i, v := range e.Vector {
+			if v < 0 || v > 1 {
+				return &EmotionVectorError{Index: i}
+			}
+		}
+	case models.EmotionModeDescription:
+		e.Text = strings.TrimSpace(e.Text)
+		if e.Text == "" {
+			return errors.New("emotion_text is required when emotion_mode is emotion_description")
+		}
+		if strings.ContainsFunc(e.Text, unicode.IsControl) {
+			return errors.New("emotion_text must not contain control characters")
+		}
+	case models.EmotionModeSameAsReference, models.EmotionModeText:
+		e.Text = ""
+	default:
+		return fmt.Errorf("unknown emotion_mode %q", e.Mode)
+	}
+	return nil
+}
+
+// encodeEmotionVector returns an emotion vector as stored on a task, empty if there is none
+func encodeEmotionVector(vector []float64) string {
+	if len(vector) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(vector)
+	return string(data)
+}
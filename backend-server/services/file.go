@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"backend-server/config"
+	"backend-server/models"
+)
+
+// ErrStorageQuotaExceeded is returned when a file would exceed the user's storage quota
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// StorageUsage summarizes a user's storage consumption
+type StorageUsage struct {
+	UsedBytes  int64                     `json:"used_bytes"`
+	QuotaBytes int64                     `json:"quota_bytes"` // 0 means unlimited
+	FileCount  int64                     `json:"file_count"`
+	ByKind     map[models.FileKind]int64 `json:"by_kind"`
+}
+
+// GetStorageQuota returns the storage quota in bytes for a user, 0 means unlimited
+// Users whose plan has unlimited storage have no quota
+func GetStorageQuota(userID string) (int64, error) {
+	var user models.User
+	if err := models.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return 0, fmt.Errorf("user not found: %w", err)
+	}
+
+	if HasUnlimitedStorage(&user) {
+		return 0, nil
+	}
+
+	return int64(config.Cfg.StorageQuotaMB) * 1024 * 1024, nil
+}
+
+// GetStorageUsage returns the storage usage summary for a user
+func GetStorageUsage(userID string) (*StorageUsage, error) {
+	quota, err := GetStorageQuota(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Kind  models.FileKind
+		Count int64
+		Size  int64
+	}
+	if err := models.DB.Model(&models.File{}).
+		Select("kind, COUNT(*) AS count, COALESCE(SUM(size), 0) AS size").
+		Where("user_id = ?", userID).
+		Group("kind").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query storage usage: %w", err)
+	}
+
+	usage := &StorageUsage{
+		QuotaBytes: quota,
+		ByKind:     make(map[models.FileKind]int64),
+	}
+	for _, row := range rows {
+		usage.UsedBytes += row.Size
+		usage.FileCount += row.Count
+		usage.ByKind[row.Kind] = row.Size
+	}
+
+	return usage, nil
+}
+
+// CheckStorageQuota checks whether the user can store additional bytes
+// Returns ErrStorageQuotaExceeded if the quota would be exceeded
+func CheckStorageQuota(userID string, additionalBytes int64) error {
+	quota, err := GetStorageQuota(userID)
+	if err != nil {
+		return err
+	}
+	if quota == 0 {
+		return nil
+	}
+
+	var used int64
+	if err := models.DB.Model(&models.File{}).
+		Select("COALESCE(SUM(size), 0)").
+		Where("user_id = ?", userID).
+		Scan(&used).Error; err != nil {
+		return fmt.Errorf("failed to query storage usage: %w", err)
+	}
+
+	if used+additionalBytes > quota {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// IsFileInUse checks if a file is referenced by a task, or a line of a script task, that has not finished yet
+func IsFileInUse(fileID string) (bool, error) {
+	var count int64
+	err := models.DB.Model(&models.Task{}).
+		Where("status IN ?", []models.TaskStatus{
+			models.TaskStatusScheduled, models.TaskStatusPending, models.TaskStatusProcessing,
+		}).
+		Where("reference_audio_file_id = ? OR emotion_prompt_file_id = ? OR id IN (?)", fileID, fileID,
+			models.DB.Model(&models.TaskLine{}).Select("task_id").
+				Where("reference_audio_file_id = ? OR emotion_prompt_file_id = ?", fileID, fileID)).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
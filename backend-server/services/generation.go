@@ -0,0 +1,174 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"backend-server/config"
+	"backend-server/models"
+)
+
+var (
+	ErrAdvancedParamOutOfRange = errors.New("advanced parameter out of range")
+	ErrAdvancedParamNotAllowed = errors.New("advanced parameter not allowed for your plan")
+	ErrUnknownAdvancedParam    = errors.New("unknown advanced parameter")
+)
+
+// allAdvancedParams allows every advanced parameter in a plan's advanced_params
+const allAdvancedParams = "*"
+
+// AdvancedParams are optional IndexTTS2 generation controls, unset fields use the engine defaults
+type AdvancedParams struct {
+	Temperature             *float64 `json:"temperature,omitempty"`
+	TopP                    *float64 `json:"top_p,omitempty"`
+	TopK                    *int     `json:"top_k,omitempty"`
+	NumBeams                *int     `json:"num_beams,omitempty"`
+	RepetitionPenalty       *float64 `json:"repetition_penalty,omitempty"`
+	LengthPenalty           *float64 `json:"length_penalty,omitempty"`
+	MaxMelTokens            *int     `json:"max_mel_tokens,omitempty"`
+	MaxTextTokensPerSegment *int     `json:"max_text_tokens_per_segment,omitempty"`
+}
+
+// paramRange is the inclusive range accepted for an advanced parameter
+type paramRange struct {
+	min, max float64
+}
+
+// advancedParamRanges are the accepted values, keyed by JSON name
+// The token limits follow the model configuration (max_mel_tokens 1815, max_text_tokens 600)
+var advancedParamRanges = map[string]paramRange{
+	"temperature":                 {0.1, 2},
+	"top_p":                       {0, 1},
+	"top_k":                       {0, 100},
+	"num_beams":                   {1, 10},
+	"repetition_penalty":          {0.1, 20},
+	"length_penalty":              {-2, 2},
+	"max_mel_tokens":              {50, 1815},
+	"max_text_tokens_per_segment": {20, 600},
+}
+
+// values returns the parameters that are set, keyed by JSON name
+func (p *AdvancedParams) values() map[string]float64 {
+	values := map[string]float64{}
+	if p == nil {
+		return values
+	}
+	data, _ := json.Marshal(p)
+	json.Unmarshal(data, &values)
+	return values
+}
+
+// IsEmpty reports whether no parameter is set
+func (p *AdvancedParams) IsEmpty() bool {
+	return len(p.values()) == 0
+}
+
+// allowedAdvancedParams returns the parameters a user may set, nil meaning all of them
+// A plan's advanced_params overrides ADVANCED_PARAMS_ALLOWED
+func allowedAdvancedParams(userID string) map[string]bool {
+	names := config.Cfg.AdvancedParamsAllowed
+	if user, err := GetUserByID(userID); err == nil {
+		if plan := getUserPlan(user); plan != nil && plan.AdvancedParams != nil {
+			names = splitAdvancedParamList(*plan.AdvancedParams)
+		}
+	}
+
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == allAdvancedParams {
+			return nil
+		}
+		allowed[name] = true
+	}
+	return allowed
+}
+
+// CheckAdvancedParams validates the advanced parameters of a new task against the accepted
+// ranges and the parameters the user's plan allows
+func CheckAdvancedParams(userID string, params *AdvancedParams) error {
+	values := params.values()
+	if len(values) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		r := advancedParamRanges[name]
+		if v := values[name]; v < r.min || v > r.max {
+			return fmt.Errorf("%w: %s must be between %g and %g", ErrAdvancedParamOutOfRange, name, r.min, r.max)
+		}
+	}
+
+	allowed := allowedAdvancedParams(userID)
+	if allowed == nil {
+		return nil
+	}
+	for _, name := range names {
+		if !allowed[name] {
+			return fmt.Errorf("%w: %s", ErrAdvancedParamNotAllowed, name)
+		}
+	}
+	return nil
+}
+
+// EncodeAdvancedParams returns the parameters as stored on a task, empty if none is set
+func EncodeAdvancedParams(params *AdvancedParams) string {
+	if params.IsEmpty() {
+		return ""
+	}
+	data, _ := json.Marshal(params)
+	return string(data)
+}
+
+// decodeAdvancedParams returns the parameters stored on a task, nil if there are none
+func decodeAdvancedParams(task *models.Task) *AdvancedParams {
+	if task.AdvancedParams == "" {
+		return nil
+	}
+	var params AdvancedParams
+	if err := json.Unmarshal([]byte(task.AdvancedParams), &params); err != nil {
+		return nil
+	}
+	return &params
+}
+
+func splitAdvancedParamList(list string) []string {
+	names := []string{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// normalizeAdvancedParamList checks the parameter names of a plan's advanced_params
+// nil keeps the ADVANCED_PARAMS_ALLOWED default, "*" allows all parameters and "" none
+func normalizeAdvancedParamList(list *string) (*string, error) {
+	if list == nil {
+		return nil, nil
+	}
+
+	names := splitAdvancedParamList(*list)
+	for _, name := range names {
+		if name == allAdvancedParams {
+			all := allAdvancedParams
+			return &all, nil
+		}
+		if _, ok := advancedParamRanges[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAdvancedParam, name)
+		}
+	}
+	sort.Strings(names)
+
+	normalized := strings.Join(names, ",")
+	return &normalized, nil
+}
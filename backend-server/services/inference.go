@@ -6,8 +6,10 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"time"
 
@@ -22,9 +24,27 @@ var privateKey *rsa.PrivateKey
 func InitInference() error {
 	cfg := config.Cfg
 
+	if cfg.InferenceFake {
+		// The fake client needs no backends or keys
+		return nil
+	}
+
+	// Fail fast when a backend is unreachable, the overall timeout only bounds slow generations
+	inferenceClient = &http.Client{
+		Timeout: 5 * time.Minute, // TTS can take a while
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout: time.Duration(max(cfg.InferenceConnectTimeoutSeconds, 1)) * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
 	if cfg.JWTPrivateKey == "" {
 		// JWT auth disabled
-		return nil
+		return initInferencePool()
 	}
 
 	block, _ := pem.Decode([]byte(cfg.JWTPrivateKey))
@@ -47,7 +67,7 @@ func InitInference() error {
 		return fmt.Errorf("private key is not RSA")
 	}
 
-	return nil
+	return initInferencePool()
 }
 
 // generateJWT generates a JWT token for inference API
@@ -68,32 +88,99 @@ func generateJWT() (string, error) {
 
 // TTSRequest represents the request to inference API
 type TTSRequest struct {
-	Text           string    `json:"text"`
-	ReferenceAudio string    `json:"reference_audio,omitempty"`
-	EmotionPrompt  string    `json:"emotion_prompt,omitempty"`
-	EmotionVector  []float64 `json:"emotion_vector,omitempty"`
-	EmotionAlpha   *float64  `json:"emotion_alpha,omitempty"`
-	UseEmotionText *bool     `json:"use_emotion_text,omitempty"`
+	Text           string          `json:"text"`
+	ReferenceAudio string          `json:"reference_audio,omitempty"`
+	EmotionPrompt  string          `json:"emotion_prompt,omitempty"`
+	EmotionVector  []float64       `json:"emotion_vector,omitempty"`
+	EmotionAlpha   *float64        `json:"emotion_alpha,omitempty"`
+	UseEmotionText *bool           `json:"use_emotion_text,omitempty"`
+	EmotionText    string          `json:"emotion_text,omitempty"` // Emotion description, used instead of the text when UseEmotionText is set
+	Advanced       *AdvancedParams `json:"advanced,omitempty"`
+
+	// Retried is set when the inference service already failed on the task, so its failure
+	// may be caused by the task and does not decide a half-open circuit's trial
+	Retried bool `json:"-"`
 }
 
-// CallInference calls the inference API and returns the audio data
-func CallInference(req *TTSRequest) ([]byte, error) {
-	cfg := config.Cfg
+// inferenceClient is shared by all inference requests, set up by InitInference
+var inferenceClient *http.Client
 
-	// Generate JWT token
-	token, err := generateJWT()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate JWT: %w", err)
-	}
+// backendError is an inference failure caused by the backend itself rather than the request
+// The request is retried on another backend
+type backendError struct {
+	err error
+}
+
+func (e *backendError) Error() string { return e.err.Error() }
+func (e *backendError) Unwrap() error { return e.err }
+
+// HTTPInferenceClient sends requests to the registered inference backends over HTTP
+type HTTPInferenceClient struct{}
+
+// NewHTTPInferenceClient creates a client for the backends set up by InitInference
+func NewHTTPInferenceClient() *HTTPInferenceClient {
+	return &HTTPInferenceClient{}
+}
+
+// Ready reports whether a backend is healthy and the circuit breaker lets requests through
+func (c *HTTPInferenceClient) Ready() bool {
+	return InferenceAvailable() && breaker.ready()
+}
 
+// Synthesize sends the request to the least loaded healthy backend and returns the audio data
+// If the backend is unreachable or unavailable, it is taken out of rotation and the next one is tried.
+// A request rejected or failed for any other reason is not retried.
+// Returns ErrCircuitOpen without sending anything while the circuit breaker is open.
+func (c *HTTPInferenceClient) Synthesize(req *TTSRequest) ([]byte, error) {
 	// Prepare request body
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	if err := breaker.allow(); err != nil {
+		return nil, err
+	}
+	audio, err := callPool(body)
+	breaker.record(err, req.Retried)
+	return audio, err
+}
+
+// callPool sends a request body to the backends in turn until one succeeds or fails because of the request
+func callPool(body []byte) ([]byte, error) {
+	tried := make(map[string]bool)
+	var lastErr error
+	for {
+		backend, err := pool.acquire(tried)
+		if err != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, err
+		}
+		tried[backend.ID] = true
+
+		audio, err := callBackend(backend.URL, body)
+		var failure *backendError
+		if !errors.As(err, &failure) {
+			pool.release(backend, nil)
+			return audio, err
+		}
+		pool.release(backend, err)
+		lastErr = err
+	}
+}
+
+// callBackend posts a TTS request to one inference backend
+func callBackend(baseURL string, body []byte) ([]byte, error) {
+	// Generate JWT token
+	token, err := generateJWT()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
 	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", cfg.InferenceURL+"/api/v1/tts", bytes.NewReader(body))
+	httpReq, err := http.NewRequest("POST", baseURL+"/api/v1/tts", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -104,23 +191,25 @@ func CallInference(req *TTSRequest) ([]byte, error) {
 	}
 
 	// Send request
-	client := &http.Client{
-		Timeout: 5 * time.Minute, // TTS can take a while
-	}
-	resp, err := client.Do(httpReq)
+	resp, err := inferenceClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call inference API: %w", err)
+		return nil, &backendError{fmt.Errorf("failed to call inference API: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	// Read response
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, &backendError{fmt.Errorf("failed to read response: %w", err)}
 	}
 
-	// Check status
-	if resp.StatusCode != http.StatusOK {
+	// Check status, only an unavailable backend is its own fault. A 500 is also returned when
+	// synthesis fails on bad input, so it fails the request instead of taking the backend out
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, &backendError{fmt.Errorf("inference API returned status %d: %s", resp.StatusCode, string(respBody))}
+	default:
 		return nil, fmt.Errorf("inference API returned status %d: %s", resp.StatusCode, string(respBody))
 	}
 
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"log"
+
+	"backend-server/config"
+)
+
+// InferenceClient generates speech for the worker
+// The worker only depends on this interface, so it can run without an inference service
+type InferenceClient interface {
+	// Ready reports whether a request can be sent now; the worker calls it before taking each task
+	// and does not take tasks otherwise. It may reserve the request, e.g. a circuit breaker trial.
+	Ready() bool
+	// Synthesize returns the WAV audio for a request
+	Synthesize(req *TTSRequest) ([]byte, error)
+}
+
+// NewInferenceClient returns the client selected by the configuration: the fake client when
+// INFERENCE_FAKE is set, the HTTP client otherwise
+func NewInferenceClient() InferenceClient {
+	if config.Cfg.InferenceFake {
+		log.Println("Using the fake inference client, results are synthetic tones")
+		return NewFakeInferenceClient()
+	}
+	return NewHTTPInferenceClient()
+}
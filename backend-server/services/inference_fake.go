@@ -0,0 +1,103 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"backend-server/config"
+)
+
+// Tone produced by the fake inference client
+const (
+	fakeToneHz        = 440
+	fakeToneAmplitude = 8000
+	fakeMsPerChar     = 100
+)
+
+// FakeInferenceClient synthesizes a sine tone instead of speech, for development and tests
+// The audio only depends on the text: its length is proportional to the number of characters.
+// Latency, errors and unavailability can be injected at any time.
+type FakeInferenceClient struct {
+	mu        sync.Mutex
+	latency   time.Duration
+	err       error
+	failEvery int // Only every n-th request fails with err, 0 or 1 fails all of them
+	ready     bool
+	calls     int
+	requests  []TTSRequest
+}
+
+// NewFakeInferenceClient creates a fake client that is ready and waits INFERENCE_FAKE_LATENCY_MS per request
+func NewFakeInferenceClient() *FakeInferenceClient {
+	return &FakeInferenceClient{
+		latency: time.Duration(config.Cfg.InferenceFakeLatencyMs) * time.Millisecond,
+		ready:   true,
+	}
+}
+
+// SetLatency sets how long each request takes
+func (f *FakeInferenceClient) SetLatency(latency time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = latency
+}
+
+// SetError makes every n-th request fail with err after the latency has passed; a nil err clears it
+func (f *FakeInferenceClient) SetError(err error, every int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+	f.failEvery = every
+}
+
+// SetReady sets what Ready reports, simulating an unavailable inference service
+func (f *FakeInferenceClient) SetReady(ready bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ready = ready
+}
+
+// Requests returns the requests received so far
+func (f *FakeInferenceClient) Requests() []TTSRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]TTSRequest(nil), f.requests...)
+}
+
+// Ready reports whether the fake is available
+func (f *FakeInferenceClient) Ready() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ready
+}
+
+// Synthesize returns a tone of 100 ms per character of the text
+func (f *FakeInferenceClient) Synthesize(req *TTSRequest) ([]byte, error) {
+	f.mu.Lock()
+	f.calls++
+	f.requests = append(f.requests, *req)
+	latency := f.latency
+	var err error
+	if f.err != nil && (f.failEvery <= 1 || f.calls%f.failEvery == 0) {
+		err = f.err
+	}
+	f.mu.Unlock()
+
+	time.Sleep(latency)
+	if err != nil {
+		return nil, err
+	}
+	return fakeTone(utf8.RuneCountInString(req.Text)), nil
+}
+
+// fakeTone returns a WAV file with a sine tone lasting fakeMsPerChar per character
+func fakeTone(chars int) []byte {
+	n := max(chars, 1) * fakeMsPerChar * wavSampleRate / 1000
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(fakeToneAmplitude * math.Sin(2*math.Pi*fakeToneHz*float64(i)/wavSampleRate))
+	}
+	return encodeWAV(samples, wavSampleRate)
+}
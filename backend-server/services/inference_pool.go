@@ -0,0 +1,467 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoInferenceBackend       = errors.New("no healthy inference backend available")
+	ErrInferenceBackendNotFound = errors.New("inference backend not found")
+	ErrInferenceBackendURLTaken = errors.New("an inference backend with this URL already exists")
+	ErrInvalidInferenceURL      = errors.New("inference backend URL must be an absolute http or https URL")
+)
+
+// inferenceHealthTimeout limits how long a /health probe may take
+const inferenceHealthTimeout = 5 * time.Second
+
+// inferenceHealth is the response of the inference service /health endpoint
+type inferenceHealth struct {
+	Status       string `json:"status"`
+	ModelLoaded  bool   `json:"model_loaded"`
+	GPUAvailable bool   `json:"gpu_available"`
+}
+
+// inferenceBackend is the runtime state of a registered backend
+type inferenceBackend struct {
+	models.InferenceBackend
+
+	healthy       bool
+	health        inferenceHealth
+	lastCheckedAt *time.Time
+	lastError     string
+	inflight      int   // Requests in progress
+	served        int64 // Requests sent since startup, used to break ties
+	failures      int64 // Failed requests since startup
+}
+
+// InferenceBackendStatus is a registered backend with its health and load
+type InferenceBackendStatus struct {
+	models.InferenceBackend
+	Healthy       bool       `json:"healthy"`
+	ModelLoaded   bool       `json:"model_loaded"`
+	GPUAvailable  bool       `json:"gpu_available"`
+	Inflight      int        `json:"inflight"`
+	Served        int64      `json:"served"`
+	Failures      int64      `json:"failures"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// InferenceBackendInput holds the editable fields of a backend
+type InferenceBackendInput struct {
+	Name    string
+	URL     string
+	Enabled bool
+}
+
+// inferencePool routes requests to the least loaded healthy backend
+type inferencePool struct {
+	mu       sync.Mutex
+	backends map[string]*inferenceBackend
+	client   *http.Client
+}
+
+var pool = &inferencePool{
+	backends: make(map[string]*inferenceBackend),
+	client:   &http.Client{Timeout: inferenceHealthTimeout},
+}
+
+// initInferencePool registers the configured backends, loads the registry and starts health probing
+func initInferencePool() error {
+	urls := config.Cfg.InferenceURLs
+	if len(urls) == 0 && config.Cfg.InferenceURL != "" {
+		urls = []string{config.Cfg.InferenceURL}
+	}
+	for _, u := range urls {
+		if err := registerConfiguredBackend(u); err != nil {
+			return err
+		}
+	}
+
+	if err := pool.reload(); err != nil {
+		return err
+	}
+	go pool.probeLoop()
+	return nil
+}
+
+// registerConfiguredBackend adds a backend from the configuration unless its URL is already registered
+func registerConfiguredBackend(rawURL string) error {
+	u, err := normalizeInferenceURL(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid inference URL %q: %w", rawURL, err)
+	}
+
+	var existing models.InferenceBackend
+	err = models.DB.Where("url = ?", u).First(&existing).Error
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to look up inference backend: %w", err)
+	}
+
+	backend := models.InferenceBackend{
+		ID:      uuid.New().String(),
+		Name:    inferenceNameFromURL(u),
+		URL:     u,
+		Enabled: true,
+		Source:  models.InferenceBackendSourceConfig,
+	}
+	if err := models.DB.Create(&backend).Error; err != nil {
+		return fmt.Errorf("failed to register inference backend: %w", err)
+	}
+	log.Printf("Registered inference backend %s", u)
+	return nil
+}
+
+func normalizeInferenceURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", ErrInvalidInferenceURL
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+func inferenceNameFromURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		return truncate(parsed.Host, 64)
+	}
+	return truncate(u, 64)
+}
+
+// reload reads the registry from the database, keeping the runtime state of known backends
+// Backends added or removed by other server instances are picked up on the next probe
+func (p *inferencePool) reload() error {
+	var rows []models.InferenceBackend
+	if err := models.DB.Order("created_at ASC").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to load inference backends: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	backends := make(map[string]*inferenceBackend, len(rows))
+	for _, row := range rows {
+		b, ok := p.backends[row.ID]
+		if !ok {
+			b = &inferenceBackend{}
+		}
+		if ok && b.URL != row.URL {
+			// A new address needs a fresh health check before it receives requests
+			b.healthy = false
+			b.lastCheckedAt = nil
+		}
+		b.InferenceBackend = row
+		backends[row.ID] = b
+	}
+	p.backends = backends
+	return nil
+}
+
+func (p *inferencePool) probeLoop() {
+	interval := time.Duration(max(config.Cfg.InferenceHealthIntervalSeconds, 1)) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.probeAll()
+		<-ticker.C
+		if err := p.reload(); err != nil {
+			log.Printf("Failed to reload inference backends: %v", err)
+		}
+	}
+}
+
+// probeAll checks the health of every enabled backend concurrently
+func (p *inferencePool) probeAll() {
+	p.mu.Lock()
+	targets := make([]models.InferenceBackend, 0, len(p.backends))
+	for _, b := range p.backends {
+		if b.Enabled {
+			targets = append(targets, b.InferenceBackend)
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.probe(t.ID, t.URL)
+		}()
+	}
+	wg.Wait()
+}
+
+// probe calls /health on a backend and records the result
+func (p *inferencePool) probe(id, baseURL string) {
+	health, err := p.fetchHealth(baseURL)
+	if err == nil && (health.Status != "healthy" || !health.ModelLoaded) {
+		err = fmt.Errorf("status %q, model loaded: %v", health.Status, health.ModelLoaded)
+	}
+	if err == nil && config.Cfg.InferenceRequireGPU && !health.GPUAvailable {
+		err = errors.New("no GPU available")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.backends[id]
+	if !ok || b.URL != baseURL {
+		return
+	}
+	now := time.Now()
+	b.lastCheckedAt = &now
+	b.health = health
+	if err != nil {
+		if b.healthy {
+			log.Printf("Inference backend %s is unhealthy: %v", b.Name, err)
+		}
+		b.healthy = false
+		b.lastError = err.Error()
+		return
+	}
+	if !b.healthy {
+		log.Printf("Inference backend %s is healthy", b.Name)
+	}
+	b.healthy = true
+	b.lastError = ""
+}
+
+func (p *inferencePool) fetchHealth(baseURL string) (inferenceHealth, error) {
+	var health inferenceHealth
+	resp, err := p.client.Get(baseURL + "/health")
+	if err != nil {
+		return health, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return health, fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return health, fmt.Errorf("invalid health response: %w", err)
+	}
+	return health, nil
+}
+
+// acquire picks the healthy backend with the fewest requests in progress and reserves a slot on it
+// Backends in exclude, e.g. ones that just failed, are skipped
+func (p *inferencePool) acquire(exclude map[string]bool) (*inferenceBackend, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *inferenceBackend
+	for _, b := range p.backends {
+		if !b.Enabled || !b.healthy || exclude[b.ID] {
+			continue
+		}
+		if best == nil || b.inflight < best.inflight || (b.inflight == best.inflight && b.served < best.served) {
+			best = b
+		}
+	}
+	if best == nil {
+		return nil, ErrNoInferenceBackend
+	}
+
+	best.inflight++
+	best.served++
+	return best, nil
+}
+
+// release returns the slot reserved by acquire; a failed request takes the backend out of
+// rotation until the next successful health check
+func (p *inferencePool) release(b *inferenceBackend, failure error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b.inflight--
+	if failure != nil {
+		b.failures++
+		b.healthy = false
+		b.lastError = failure.Error()
+		log.Printf("Inference backend %s taken out of rotation: %v", b.Name, failure)
+	}
+}
+
+// InferenceAvailable reports whether at least one backend can take requests
+func InferenceAvailable() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for _, b := range pool.backends {
+		if b.Enabled && b.healthy {
+			return true
+		}
+	}
+	return false
+}
+
+// ListInferenceBackends returns all registered backends with their health and load
+func ListInferenceBackends() []InferenceBackendStatus {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	list := make([]InferenceBackendStatus, 0, len(pool.backends))
+	for _, b := range pool.backends {
+		list = append(list, b.status())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+func (b *inferenceBackend) status() InferenceBackendStatus {
+	return InferenceBackendStatus{
+		InferenceBackend: b.InferenceBackend,
+		Healthy:          b.healthy,
+		ModelLoaded:      b.health.ModelLoaded,
+		GPUAvailable:     b.health.GPUAvailable,
+		Inflight:         b.inflight,
+		Served:           b.served,
+		Failures:         b.failures,
+		LastCheckedAt:    b.lastCheckedAt,
+		LastError:        b.lastError,
+	}
+}
+
+// GetInferenceBackend returns a registered backend
+func GetInferenceBackend(id string) (*models.InferenceBackend, error) {
+	var backend models.InferenceBackend
+	if err := models.DB.First(&backend, "id = ?", id).Error; err != nil {
+		return nil, ErrInferenceBackendNotFound
+	}
+	return &backend, nil
+}
+
+// getInferenceBackendStatus returns the status of one backend
+func getInferenceBackendStatus(id string) (*InferenceBackendStatus, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	b, ok := pool.backends[id]
+	if !ok {
+		return nil, ErrInferenceBackendNotFound
+	}
+	status := b.status()
+	return &status, nil
+}
+
+// CreateInferenceBackend registers a backend and checks its health right away
+func CreateInferenceBackend(input InferenceBackendInput) (*InferenceBackendStatus, error) {
+	u, err := normalizeInferenceURL(input.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInferenceURL(u, ""); err != nil {
+		return nil, err
+	}
+
+	name := input.Name
+	if name == "" {
+		name = inferenceNameFromURL(u)
+	}
+	backend := models.InferenceBackend{
+		ID:      uuid.New().String(),
+		Name:    name,
+		URL:     u,
+		Enabled: input.Enabled,
+		Source:  models.InferenceBackendSourceAdmin,
+	}
+	if err := models.DB.Create(&backend).Error; err != nil {
+		return nil, fmt.Errorf("failed to create inference backend: %w", err)
+	}
+
+	return refreshInferenceBackend(backend.ID)
+}
+
+// UpdateInferenceBackend changes the name, URL or enabled state of a backend
+func UpdateInferenceBackend(id string, input InferenceBackendInput) (*InferenceBackendStatus, error) {
+	backend, err := GetInferenceBackend(id)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := normalizeInferenceURL(input.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInferenceURL(u, id); err != nil {
+		return nil, err
+	}
+
+	name := input.Name
+	if name == "" {
+		name = inferenceNameFromURL(u)
+	}
+	if err := models.DB.Model(backend).Updates(map[string]interface{}{
+		"name":    name,
+		"url":     u,
+		"enabled": input.Enabled,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update inference backend: %w", err)
+	}
+
+	return refreshInferenceBackend(id)
+}
+
+// DeleteInferenceBackend removes a backend; requests in progress on it are finished
+// Backends from the configuration are registered again at the next startup
+func DeleteInferenceBackend(id string) error {
+	result := models.DB.Where("id = ?", id).Delete(&models.InferenceBackend{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete inference backend: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInferenceBackendNotFound
+	}
+	return pool.reload()
+}
+
+// refreshInferenceBackend reloads the registry and probes a changed backend
+func refreshInferenceBackend(id string) (*InferenceBackendStatus, error) {
+	if err := pool.reload(); err != nil {
+		return nil, err
+	}
+
+	status, err := getInferenceBackendStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	if status.Enabled {
+		pool.probe(id, status.URL)
+	}
+	return getInferenceBackendStatus(id)
+}
+
+// checkInferenceURL makes sure no other backend uses the URL
+func checkInferenceURL(u, exceptID string) error {
+	var count int64
+	query := models.DB.Model(&models.InferenceBackend{}).Where("url = ?", u)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check inference backend URL: %w", err)
+	}
+	if count > 0 {
+		return ErrInferenceBackendURLTaken
+	}
+	return nil
+}
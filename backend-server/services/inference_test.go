@@ -0,0 +1,84 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"backend-server/models"
+)
+
+// testBackend is an inference backend answering every request with a fixed status
+type testBackend struct {
+	*httptest.Server
+	calls atomic.Int32
+}
+
+func newTestBackend(t *testing.T, status int) *testBackend {
+	t.Helper()
+
+	b := &testBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.calls.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte("audio"))
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// setupTestPool replaces the inference pool with healthy backends at the given servers
+func setupTestPool(t *testing.T, servers ...*testBackend) []*inferenceBackend {
+	t.Helper()
+
+	previousBackends, previousClient := pool.backends, inferenceClient
+	t.Cleanup(func() {
+		pool.backends = previousBackends
+		inferenceClient = previousClient
+	})
+
+	inferenceClient = &http.Client{}
+	pool.backends = make(map[string]*inferenceBackend)
+	backends := make([]*inferenceBackend, len(servers))
+	for i, s := range servers {
+		b := &inferenceBackend{healthy: true, served: int64(i)}
+		b.InferenceBackend = models.InferenceBackend{ID: s.URL, Name: s.URL, URL: s.URL, Enabled: true}
+		pool.backends[b.ID] = b
+		backends[i] = b
+	}
+	return backends
+}
+
+func TestCallPoolDoesNotRetryRequestErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusInternalServerError} {
+		first, second := newTestBackend(t, status), newTestBackend(t, status)
+		backends := setupTestPool(t, first, second)
+
+		_, err := callPool([]byte("{}"))
+		if err == nil || isBackendFailure(err) {
+			t.Fatalf("status %d: err = %v, want a request failure", status, err)
+		}
+		if calls := first.calls.Load() + second.calls.Load(); calls != 1 {
+			t.Errorf("status %d: request sent %d times, want once", status, calls)
+		}
+		for _, b := range backends {
+			if !b.healthy {
+				t.Errorf("status %d: backend taken out of rotation by a request failure", status)
+			}
+		}
+	}
+}
+
+func TestCallPoolRetriesUnavailableBackend(t *testing.T) {
+	down, up := newTestBackend(t, http.StatusServiceUnavailable), newTestBackend(t, http.StatusOK)
+	backends := setupTestPool(t, down, up)
+
+	audio, err := callPool([]byte("{}"))
+	if err != nil || string(audio) != "audio" {
+		t.Fatalf("audio %q, err %v, want the second backend to answer", audio, err)
+	}
+	if backends[0].healthy || !backends[1].healthy {
+		t.Errorf("healthy = %v, %v, want only the unavailable backend out of rotation", backends[0].healthy, backends[1].healthy)
+	}
+}
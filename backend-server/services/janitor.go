@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"gorm.io/gorm"
+)
+
+// JanitorReport summarizes what a cleanup run removed
+type JanitorReport struct {
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	ExpiredFiles   int       `json:"expired_files"` // Files removed by the retention policy
+	PurgedFiles    int       `json:"purged_files"`  // Soft-deleted files hard-deleted after the grace period
+	FreedBytes     int64     `json:"freed_bytes"`   // Total size of removed objects
+	RemovedFileIDs []string  `json:"removed_file_ids"`
+	Errors         []string  `json:"errors,omitempty"`
+}
+
+// Janitor periodically applies the data retention policy
+type Janitor struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	storage ObjectStorage
+
+	mu         sync.Mutex
+	lastReport *JanitorReport
+}
+
+// defaultJanitor is the janitor started by main, whose reports the admin API shows
+var defaultJanitor *Janitor
+
+// NewJanitor creates a new janitor that removes objects from the given storage
+func NewJanitor(storage ObjectStorage) *Janitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Janitor{
+		ctx:     ctx,
+		cancel:  cancel,
+		storage: storage,
+	}
+	defaultJanitor = j
+	return j
+}
+
+// GetJanitor returns the janitor created by NewJanitor, or nil if there is none
+func GetJanitor() *Janitor {
+	return defaultJanitor
+}
+
+// Start runs cleanup once right away and then on the configured interval
+func (j *Janitor) Start() {
+	if config.Cfg.JanitorIntervalMinutes <= 0 {
+		log.Println("Janitor disabled")
+		return
+	}
+	go j.run()
+}
+
+// Stop stops the janitor
+func (j *Janitor) Stop() {
+	j.cancel()
+}
+
+// LastReport returns the report of the most recent run, or nil if none has completed
+func (j *Janitor) LastReport() *JanitorReport {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.lastReport
+}
+
+func (j *Janitor) run() {
+	log.Println("Janitor started")
+	j.RunOnce()
+
+	ticker := time.NewTicker(time.Duration(config.Cfg.JanitorIntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.ctx.Done():
+			log.Println("Janitor stopped")
+			return
+		case <-ticker.C:
+			j.RunOnce()
+		}
+	}
+}
+
+// RunOnce applies the retention policy and purges soft-deleted files
+func (j *Janitor) RunOnce() *JanitorReport {
+	cfg := config.Cfg
+	report := &JanitorReport{
+		StartedAt:      time.Now(),
+		RemovedFileIDs: []string{},
+	}
+
+	if cfg.RetentionResultDays > 0 {
+		j.expireFiles(report, []models.FileKind{models.FileKindResult}, cfg.RetentionResultDays)
+	}
+	if cfg.RetentionUploadDays > 0 {
+		j.expireFiles(report, []models.FileKind{models.FileKindReference, models.FileKindEmotionPrompt}, cfg.RetentionUploadDays)
+	}
+	j.purgeDeletedFiles(report, cfg.RetentionGraceDays)
+
+	// Expired rate limit windows carry no state
+	if _, err := PurgeRateLimitCounters(time.Now().AddDate(0, 0, -2)); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to purge rate limit counters: %v", err))
+	}
+	if _, err := PurgeEmailTokens(time.Now().AddDate(0, 0, -1)); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to purge email tokens: %v", err))
+	}
+	if _, err := PurgeOAuthStates(time.Now().AddDate(0, 0, -1)); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to purge OAuth states: %v", err))
+	}
+
+	report.FinishedAt = time.Now()
+
+	log.Printf("Janitor run finished: expired=%d purged=%d freed=%d bytes errors=%d",
+		report.ExpiredFiles, report.PurgedFiles, report.FreedBytes, len(report.Errors))
+	for _, e := range report.Errors {
+		log.Printf("Janitor error: %s", e)
+	}
+
+	j.mu.Lock()
+	j.lastReport = report
+	j.mu.Unlock()
+
+	return report
+}
+
+// freeUsers selects the users the retention policy applies to: no plan and no paid order
+func freeUsers() *gorm.DB {
+	return models.DB.Model(&models.User{}).Select("id").
+		Where("plan_id IS NULL").
+		Where("id NOT IN (?)", models.DB.Model(&models.Order{}).Select("user_id").Where("status = ?", models.OrderStatusPaid))
+}
+
+// expireFiles deletes objects of the given kinds that are older than the retention period
+// Only files of free users expire, and files used by unfinished tasks are kept. Tasks, script
+// lines and share links pointing to an expired file are updated in the same transaction as the
+// file row, so nothing refers to a missing file.
+func (j *Janitor) expireFiles(report *JanitorReport, kinds []models.FileKind, days int) {
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	query := models.DB.Where("kind IN ? AND created_at < ?", kinds, cutoff).
+		Where("user_id IN (?)", freeUsers())
+
+	var files []models.File
+	if err := query.Order("created_at ASC").Limit(config.Cfg.JanitorBatchSize).Find(&files).Error; err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to query expired files: %v", err))
+		return
+	}
+
+	for _, file := range files {
+		if file.Kind != models.FileKindResult {
+			inUse, err := IsFileInUse(file.ID)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("file %s: %v", file.ID, err))
+				continue
+			}
+			if inUse {
+				continue
+			}
+		}
+
+		if err := j.storage.Delete(file.OSSKey); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("file %s: %v", file.ID, err))
+			continue
+		}
+
+		// The object is gone, remove the row for good
+		if err := models.DB.Transaction(func(tx *gorm.DB) error {
+			return expireFileRecord(tx, &file)
+		}); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("file %s: failed to delete record: %v", file.ID, err))
+			continue
+		}
+
+		report.ExpiredFiles++
+		report.FreedBytes += file.Size
+		report.RemovedFileIDs = append(report.RemovedFileIDs, file.ID)
+	}
+}
+
+// expireFileRecord deletes the row of an expired file and the references to it: the task it is
+// the result of is marked as expired, script lines lose their stem and share links are revoked
+func expireFileRecord(tx *gorm.DB, file *models.File) error {
+	now := time.Now()
+	if err := tx.Model(&models.Task{}).
+		Where("result_audio_file_id = ?", file.ID).
+		Updates(map[string]interface{}{
+			"result_audio_file_id": "",
+			"result_expired_at":    now,
+		}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.TaskLine{}).
+		Where("stem_audio_file_id = ?", file.ID).
+		Update("stem_audio_file_id", "").Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.ShareLink{}).
+		Where("file_id = ? AND revoked_at IS NULL", file.ID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(file).Error
+}
+
+// purgeDeletedFiles removes objects and rows of files soft-deleted longer than the grace period
+func (j *Janitor) purgeDeletedFiles(report *JanitorReport, graceDays int) {
+	cutoff := time.Now().AddDate(0, 0, -graceDays)
+
+	var files []models.File
+	if err := models.DB.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("deleted_at ASC").
+		Limit(config.Cfg.JanitorBatchSize).
+		Find(&files).Error; err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to query deleted files: %v", err))
+		return
+	}
+
+	for _, file := range files {
+		if err := j.storage.Delete(file.OSSKey); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("file %s: %v", file.ID, err))
+			continue
+		}
+
+		if err := models.DB.Unscoped().Delete(&file).Error; err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("file %s: failed to delete record: %v", file.ID, err))
+			continue
+		}
+
+		report.PurgedFiles++
+		report.FreedBytes += file.Size
+		report.RemovedFileIDs = append(report.RemovedFileIDs, file.ID)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// createOldResult stores a result file of a user created the given number of days ago
+func createOldResult(t *testing.T, storage *memoryStorage, userID string, days int) *models.File {
+	t.Helper()
+
+	key, _ := storage.Upload(fakeTone(1), "result.wav", "audio/wav")
+	file := &models.File{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Kind:        models.FileKindResult,
+		Filename:    "result.wav",
+		OSSKey:      key,
+		ContentType: "audio/wav",
+		CreatedAt:   time.Now().AddDate(0, 0, -days),
+	}
+	if err := models.DB.Create(file).Error; err != nil {
+		t.Fatalf("create result file: %v", err)
+	}
+	return file
+}
+
+func TestJanitorRetentionIsOptIn(t *testing.T) {
+	setupTestDB(t)
+	storage := newMemoryStorage()
+	user := createTestUser(t, 0)
+	file := createOldResult(t, storage, user.ID, 365)
+
+	if report := NewJanitor(storage).RunOnce(); report.ExpiredFiles != 0 {
+		t.Fatalf("expired %d files with the default configuration, want none", report.ExpiredFiles)
+	}
+	if _, ok := storage.get(file.OSSKey); !ok {
+		t.Fatal("result deleted with the default configuration")
+	}
+}
+
+func TestJanitorExpiresOnlyFreeUsers(t *testing.T) {
+	setupTestDB(t)
+	config.Cfg.RetentionResultDays = 30
+	storage := newMemoryStorage()
+
+	free := createTestUser(t, 0)
+	paying := createTestUser(t, 0)
+	order := &models.Order{ID: uuid.New().String(), UserID: paying.ID, OutTradeNo: uuid.New().String(),
+		Amount: 100, Credits: 10, Status: models.OrderStatusPaid}
+	if err := models.DB.Create(order).Error; err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	planned := createTestUser(t, 0)
+	plan := &models.Plan{ID: uuid.New().String(), Name: "pro"}
+	models.DB.Create(plan)
+	models.DB.Model(planned).Update("plan_id", plan.ID)
+
+	expired := createOldResult(t, storage, free.ID, 31)
+	recent := createOldResult(t, storage, free.ID, 1)
+	kept := []*models.File{recent, createOldResult(t, storage, paying.ID, 31), createOldResult(t, storage, planned.ID, 31)}
+
+	task := createTaskWithStatus(t, free.ID, models.TaskStatusCompleted, nil)
+	models.DB.Model(task).Update("result_audio_file_id", expired.ID)
+	link, err := CreateShareLink(free.ID, CreateShareLinkParams{TaskID: task.ID})
+	if err != nil {
+		t.Fatalf("create share link: %v", err)
+	}
+
+	report := NewJanitor(storage).RunOnce()
+	if report.ExpiredFiles != 1 || len(report.RemovedFileIDs) != 1 || report.RemovedFileIDs[0] != expired.ID {
+		t.Fatalf("removed %v, want only the free user's old result", report.RemovedFileIDs)
+	}
+	if _, ok := storage.get(expired.OSSKey); ok {
+		t.Error("expired object still stored")
+	}
+	for _, file := range kept {
+		if _, ok := storage.get(file.OSSKey); !ok {
+			t.Errorf("file %s of user %s deleted", file.ID, file.UserID)
+		}
+	}
+
+	reloaded, _ := GetTaskByID(task.ID)
+	if reloaded.ResultAudioFileID != "" || reloaded.ResultExpiredAt == nil {
+		t.Errorf("task result %q, expired at %v, want it marked as expired", reloaded.ResultAudioFileID, reloaded.ResultExpiredAt)
+	}
+	var reloadedLink models.ShareLink
+	models.DB.First(&reloadedLink, "id = ?", link.ID)
+	if !reloadedLink.IsRevoked() {
+		t.Error("share link of the expired file was not revoked")
+	}
+}
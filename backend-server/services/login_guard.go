@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Authentication error codes
+const (
+	AuthErrCodeNotFound     = "code_not_found"
+	AuthErrCodeInvalid      = "invalid_code"
+	AuthErrCodeExpired      = "code_expired"
+	AuthErrCodeTooManyTries = "code_attempts_exceeded"
+	AuthErrLocked           = "login_locked"
+	AuthErrRateLimited      = "rate_limited"
+	AuthErrCaptchaRequired  = "captcha_required"
+	AuthErrInvalidLogin     = "invalid_credentials"
+)
+
+// AuthError is a structured authentication error returned to clients
+type AuthError struct {
+	Code              string
+	Message           string
+	RetryAfter        time.Duration // Set when the client must wait before retrying
+	AttemptsRemaining int           // Remaining guesses for the current code, -1 if not applicable
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+func newAuthError(code, message string) *AuthError {
+	return &AuthError{Code: code, Message: message, AttemptsRemaining: -1}
+}
+
+// Login subjects identify the account a login attempt targets in failure tracking
+func phoneLoginSubject(phone string) string { return "phone:" + phone }
+func emailLoginSubject(email string) string { return "email:" + email }
+
+// lockoutKeys returns the failure tracking keys for a login attempt
+func lockoutKeys(subject, ip string) []string {
+	keys := []string{subject}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+func lockoutError(retryAfter time.Duration) *AuthError {
+	err := newAuthError(AuthErrLocked,
+		fmt.Sprintf("too many failed login attempts, please try again in %d seconds", int(retryAfter.Seconds())+1))
+	err.RetryAfter = retryAfter
+	return err
+}
+
+// guardLogin runs a login attempt for an account subject from an IP, rejecting it while either is locked out
+// The failure rows of both stay locked during the attempt, so concurrent guesses are checked and
+// counted one after another instead of all passing the lockout check before any failure is recorded.
+// attempt returns an *AuthError when the credentials are wrong: the failure is counted, and the error
+// is replaced by a lockout error if it triggered a lockout. Accounts use the LOGIN_MAX_FAILURES_PER_PHONE
+// threshold whether they log in by phone or email. A successful attempt clears the account's failures;
+// the IP counter is kept so that one valid account cannot be used to reset an attacker's IP.
+func guardLogin(subject, ip string, attempt func() error) error {
+	cfg := config.Cfg
+	keys := lockoutKeys(subject, ip)
+
+	// Make sure the rows exist, outside the transaction that locks them
+	for _, key := range keys {
+		if err := models.DB.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.AuthFailure{Key: key, WindowStart: time.Now()}).Error; err != nil {
+			return fmt.Errorf("failed to check login lockout: %w", err)
+		}
+	}
+
+	var attemptErr error
+	var lockedFor time.Duration
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var failures []models.AuthFailure
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("`key` IN ?", keys).
+			Order("`key`").
+			Find(&failures).Error; err != nil {
+			return err
+		}
+
+		var retryAfter time.Duration
+		for _, f := range failures {
+			if f.IsLocked() {
+				retryAfter = max(retryAfter, time.Until(*f.LockedUntil))
+			}
+		}
+		if retryAfter > 0 {
+			attemptErr = lockoutError(retryAfter)
+			return nil
+		}
+
+		attemptErr = attempt()
+
+		var authErr *AuthError
+		switch {
+		case attemptErr == nil:
+			for _, f := range failures {
+				// Drop the account's row, and IP rows that only exist because of this attempt
+				if f.Key == subject || (f.Failures == 0 && f.LockoutLevel == 0) {
+					if err := tx.Delete(&f).Error; err != nil {
+						return err
+					}
+				}
+			}
+		case errors.As(attemptErr, &authErr):
+			for i := range failures {
+				f := &failures[i]
+				threshold := cfg.LoginMaxFailuresPerPhone
+				if strings.HasPrefix(f.Key, "ip:") {
+					threshold = cfg.LoginMaxFailuresPerIP
+				}
+				if d := countFailure(f, threshold); d > 0 {
+					log.Printf("[SECURITY] Login locked for %s after repeated failures, lockout %s", f.Key, d)
+					lockedFor = max(lockedFor, d)
+				}
+				if err := tx.Save(f).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	if lockedFor > 0 {
+		return lockoutError(lockedFor)
+	}
+	return attemptErr
+}
+
+// countFailure increments the failure counter of a locked row and applies a progressive lockout
+// Returns the lockout duration if the key became locked
+func countFailure(f *models.AuthFailure, threshold int) time.Duration {
+	window := time.Duration(config.Cfg.LoginFailureWindowMinutes) * time.Minute
+	now := time.Now()
+
+	// Start a new window once the previous one has elapsed
+	if now.Sub(f.WindowStart) > window {
+		f.Failures = 0
+		f.WindowStart = now
+	}
+	// Forget earlier lockouts after a quiet day
+	if f.LockedUntil != nil && now.Sub(*f.LockedUntil) > 24*time.Hour {
+		f.LockoutLevel = 0
+	}
+
+	f.Failures++
+	if threshold <= 0 || f.Failures < threshold {
+		return 0
+	}
+
+	lockedFor := lockoutDuration(f.LockoutLevel)
+	lockedUntil := now.Add(lockedFor)
+	f.LockedUntil = &lockedUntil
+	f.LockoutLevel++
+	f.Failures = 0
+	f.WindowStart = now
+	return lockedFor
+}
+
+// lockoutDuration doubles the base lockout for every previous lockout, up to the configured maximum
+func lockoutDuration(level int) time.Duration {
+	cfg := config.Cfg
+	base := time.Duration(cfg.LoginLockoutMinutes) * time.Minute
+	max := time.Duration(cfg.LoginLockoutMaxMinutes) * time.Minute
+
+	d := base
+	for i := 0; i < level && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// ResetLoginFailures clears the failure counter and lockout history of an account after a successful login
+// The IP counter is kept so that one valid account cannot be used to reset an attacker's IP
+func ResetLoginFailures(subject string) {
+	models.DB.Where("`key` = ?", subject).Delete(&models.AuthFailure{})
+}
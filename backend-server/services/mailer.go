@@ -0,0 +1,213 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-server/config"
+
+	"github.com/google/uuid"
+)
+
+// MailMessage is a plain-text email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+var mailer Mailer
+
+// InitMailer initializes the mail backend
+func InitMailer() error {
+	cfg := config.Cfg
+
+	switch cfg.MailBackend {
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.MailFrom == "" {
+			return errors.New("smtp mail backend requires SMTP_HOST and MAIL_FROM")
+		}
+		mailer = NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "", "capture":
+		// Captured mail holds working sign-in and password reset links, never use it in production
+		if cfg.GinMode != "debug" && cfg.GinMode != "test" {
+			return fmt.Errorf("the capture mail backend is only allowed with GIN_MODE=debug or test, set MAIL_BACKEND=smtp (GIN_MODE is %s)", cfg.GinMode)
+		}
+		mailer = NewCaptureMailer(cfg.MailCaptureDir)
+	default:
+		return fmt.Errorf("unknown mail backend: %s", cfg.MailBackend)
+	}
+
+	log.Printf("Mailer initialized (backend: %s)", cfg.MailBackend)
+	return nil
+}
+
+// SendMail sends an email through the configured backend
+func SendMail(msg MailMessage) error {
+	if mailer == nil {
+		return errors.New("mailer not initialized")
+	}
+	return mailer.Send(msg)
+}
+
+// formatMail renders a message in RFC 5322 format
+func formatMail(from string, msg MailMessage) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Message-ID: <" + uuid.New().String() + "@indextts-server>\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SMTPMailer sends mail through an SMTP server
+// Port 465 uses implicit TLS, other ports use STARTTLS when the server offers it
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates an SMTP mailer
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send delivers the message
+func (m *SMTPMailer) Send(msg MailMessage) error {
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if m.port != 465 {
+		if err := smtp.SendMail(addr, auth, m.envelopeFrom(), []string{msg.To}, formatMail(m.from, msg)); err != nil {
+			return fmt.Errorf("failed to send mail: %w", err)
+		}
+		return nil
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{ServerName: m.host})
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(m.envelopeFrom()); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if _, err := w.Write(formatMail(m.from, msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// envelopeFrom extracts the bare address from a "Name <addr>" sender
+func (m *SMTPMailer) envelopeFrom() string {
+	if i := strings.LastIndex(m.from, "<"); i >= 0 {
+		return strings.TrimSuffix(m.from[i+1:], ">")
+	}
+	return m.from
+}
+
+// CaptureMailer keeps mail locally instead of sending it, for development and tests
+// Messages are written as .eml files to dir when set, and always kept in memory
+type CaptureMailer struct {
+	dir      string
+	mu       sync.Mutex
+	messages []MailMessage
+}
+
+// captureMailerMaxMessages bounds the in-memory history
+const captureMailerMaxMessages = 100
+
+// NewCaptureMailer creates a capture mailer writing to dir (may be empty)
+func NewCaptureMailer(dir string) *CaptureMailer {
+	return &CaptureMailer{dir: dir}
+}
+
+// Send records the message
+func (m *CaptureMailer) Send(msg MailMessage) error {
+	m.mu.Lock()
+	m.messages = append(m.messages, msg)
+	if len(m.messages) > captureMailerMaxMessages {
+		m.messages = m.messages[len(m.messages)-captureMailerMaxMessages:]
+	}
+	m.mu.Unlock()
+
+	if m.dir == "" {
+		log.Printf("[DEV MODE] Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create mail capture dir: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.New().String()[:8])
+	if err := os.WriteFile(filepath.Join(m.dir, name), formatMail("capture@localhost", msg), 0600); err != nil {
+		return fmt.Errorf("failed to write captured mail: %w", err)
+	}
+	return nil
+}
+
+// Messages returns the captured messages, oldest first
+func (m *CaptureMailer) Messages() []MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MailMessage(nil), m.messages...)
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+
+	"backend-server/config"
+)
+
+func TestInitMailerRejectsCaptureInRelease(t *testing.T) {
+	setupTestDB(t)
+	saved := config.Cfg
+	t.Cleanup(func() { config.Cfg = saved })
+	cfg := *saved
+	config.Cfg = &cfg
+
+	cfg.GinMode = "release"
+	for _, backend := range []string{"", "capture"} {
+		cfg.MailBackend = backend
+		if err := InitMailer(); err == nil {
+			t.Errorf("backend %q: InitMailer succeeded in release mode", backend)
+		}
+	}
+
+	cfg.GinMode = "debug"
+	cfg.MailBackend = "capture"
+	if err := InitMailer(); err != nil {
+		t.Errorf("capture in debug mode: %v", err)
+	}
+}
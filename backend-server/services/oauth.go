@@ -0,0 +1,406 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// OAuthStateTTL bounds how long the user may take at the provider
+const OAuthStateTTL = 10 * time.Minute
+
+// oauthExchangeTTL bounds how long the frontend may take to redeem the exchange code
+const oauthExchangeTTL = 2 * time.Minute
+
+var (
+	ErrOAuthProviderNotFound = errors.New("login provider not found")
+	ErrOAuthInvalidState     = errors.New("invalid or expired login attempt, please try again")
+	ErrOAuthBrowserMismatch  = errors.New("login was started in another browser, please try again")
+	ErrOAuthInvalidCode      = errors.New("invalid or expired login code")
+	ErrOAuthIdentityTaken    = errors.New("this account is already linked to another user")
+	ErrOAuthLastLoginMethod  = errors.New("cannot unlink the only login method of the account")
+)
+
+// OAuthUserInfo is the identity returned by a provider after a successful authorization
+type OAuthUserInfo struct {
+	Subject       string // Stable user ID at the provider
+	Email         string
+	EmailVerified bool
+	Name          string
+	AvatarURL     string
+}
+
+// OAuthProvider is a third-party login provider
+type OAuthProvider interface {
+	// Name identifies the provider in URLs and on linked identities
+	Name() string
+	// DisplayName is shown on the login button
+	DisplayName() string
+	// AuthorizeURL returns the provider URL the browser is sent to
+	AuthorizeURL(ctx context.Context, redirectURI string, state *models.OAuthState, rawState string) (string, error)
+	// Exchange redeems the authorization code and returns the user's identity
+	Exchange(ctx context.Context, redirectURI, code string, state *models.OAuthState) (*OAuthUserInfo, error)
+}
+
+var oauthProviders = map[string]OAuthProvider{}
+
+// oauthHTTPClient is shared by all providers
+var oauthHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// InitOAuth registers the configured third-party login providers
+func InitOAuth() error {
+	cfg := config.Cfg
+	providers := map[string]OAuthProvider{}
+
+	if cfg.WeChatAppID != "" && cfg.WeChatAppSecret != "" {
+		p := NewWeChatProvider(cfg.WeChatAppID, cfg.WeChatAppSecret)
+		providers[p.Name()] = p
+	}
+
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID != "" {
+		p := NewOIDCProvider(cfg.OIDCName, cfg.OIDCDisplayName, cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCScopes)
+		if _, exists := providers[p.Name()]; exists {
+			return fmt.Errorf("duplicate OAuth provider name: %s", p.Name())
+		}
+		providers[p.Name()] = p
+	}
+
+	oauthProviders = providers
+	if len(providers) == 0 {
+		log.Println("No third-party login providers configured")
+		return nil
+	}
+
+	log.Printf("Third-party login initialized (providers: %v)", OAuthProviderNames())
+	return nil
+}
+
+// OAuthProviderNames returns the names of the configured providers, sorted
+func OAuthProviderNames() []string {
+	names := make([]string, 0, len(oauthProviders))
+	for name := range oauthProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetOAuthProvider returns a configured provider by name
+func GetOAuthProvider(name string) (OAuthProvider, error) {
+	p, ok := oauthProviders[name]
+	if !ok {
+		return nil, ErrOAuthProviderNotFound
+	}
+	return p, nil
+}
+
+// oauthRedirectURI is the callback URL registered at the provider
+func oauthRedirectURI(provider string) string {
+	return config.Cfg.OAuthCallbackBaseURL + "/api/v1/auth/oauth/" + provider + "/callback"
+}
+
+// pkceChallenge derives the S256 code challenge of a PKCE verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewOAuthBrowserKey generates the secret a browser keeps in a cookie to prove it started an authorization attempt
+func NewOAuthBrowserKey() (string, error) {
+	return randomToken(32)
+}
+
+// StartOAuth begins an authorization attempt and returns the provider URL to redirect to
+// linkUserID is set when a logged-in user links the provider to their account; browserKey is
+// the cookie of the browser starting the attempt, the callback is only accepted with the same cookie
+func StartOAuth(ctx context.Context, providerName, linkUserID, browserKey string) (string, error) {
+	provider, err := GetOAuthProvider(providerName)
+	if err != nil {
+		return "", err
+	}
+	if browserKey == "" {
+		return "", ErrOAuthBrowserMismatch
+	}
+
+	rawState, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken(48)
+	if err != nil {
+		return "", err
+	}
+
+	state := &models.OAuthState{
+		ID:           uuid.New().String(),
+		StateHash:    hashToken(rawState),
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		BrowserHash:  hashToken(browserKey),
+		ExpiresAt:    time.Now().Add(OAuthStateTTL),
+	}
+	if err := models.DB.Create(state).Error; err != nil {
+		return "", fmt.Errorf("failed to save login state: %w", err)
+	}
+
+	return provider.AuthorizeURL(ctx, oauthRedirectURI(provider.Name()), state, rawState)
+}
+
+// OAuthCallbackResult describes what happened in a provider callback
+type OAuthCallbackResult struct {
+	Linked       bool   // A provider was linked to a logged-in user
+	ExchangeCode string // Set after a login, redeemed by the frontend for tokens
+}
+
+// HandleOAuthCallback validates the state, redeems the authorization code and resolves the user
+// The callback must come from the browser that started the attempt, otherwise an attacker could
+// log a victim into the attacker's account or link the victim's provider account to their own
+func HandleOAuthCallback(ctx context.Context, providerName, code, rawState, browserKey string) (*OAuthCallbackResult, error) {
+	provider, err := GetOAuthProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := consumeOAuthState(provider.Name(), rawState, browserKey)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := provider.Exchange(ctx, oauthRedirectURI(provider.Name()), code, state)
+	if err != nil {
+		log.Printf("OAuth exchange with %s failed: %v", provider.Name(), err)
+		return nil, fmt.Errorf("login with %s failed", provider.DisplayName())
+	}
+	if info.Subject == "" {
+		return nil, fmt.Errorf("login with %s failed: missing user ID", provider.DisplayName())
+	}
+
+	if state.LinkUserID != "" {
+		if err := linkOAuthIdentity(state.LinkUserID, provider.Name(), info); err != nil {
+			return nil, err
+		}
+		return &OAuthCallbackResult{Linked: true}, nil
+	}
+
+	user, err := resolveOAuthUser(provider.Name(), info)
+	if err != nil {
+		return nil, err
+	}
+
+	exchangeCode, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	exchangeExpiresAt := time.Now().Add(oauthExchangeTTL)
+	if err := models.DB.Model(state).Updates(map[string]interface{}{
+		"exchange_hash":       hashToken(exchangeCode),
+		"result_user_id":      user.ID,
+		"exchange_expires_at": exchangeExpiresAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save login result: %w", err)
+	}
+
+	return &OAuthCallbackResult{ExchangeCode: exchangeCode}, nil
+}
+
+// consumeOAuthState marks an authorization attempt as completed, it can only be used once
+// Attempts started in another browser are rejected without being consumed
+func consumeOAuthState(provider, rawState, browserKey string) (*models.OAuthState, error) {
+	if rawState == "" {
+		return nil, ErrOAuthInvalidState
+	}
+
+	var state models.OAuthState
+	if err := models.DB.First(&state, "state_hash = ? AND provider = ?", hashToken(rawState), provider).Error; err != nil {
+		return nil, ErrOAuthInvalidState
+	}
+	if state.IsExpired() || state.CompletedAt != nil {
+		return nil, ErrOAuthInvalidState
+	}
+	if browserKey == "" || state.BrowserHash == "" ||
+		subtle.ConstantTimeCompare([]byte(state.BrowserHash), []byte(hashToken(browserKey))) != 1 {
+		return nil, ErrOAuthBrowserMismatch
+	}
+
+	now := time.Now()
+	result := models.DB.Model(&models.OAuthState{}).
+		Where("id = ? AND completed_at IS NULL", state.ID).
+		Update("completed_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrOAuthInvalidState
+	}
+	state.CompletedAt = &now
+
+	return &state, nil
+}
+
+// resolveOAuthUser finds the user of a provider identity, linking or creating one on first login
+func resolveOAuthUser(provider string, info *OAuthUserInfo) (*models.User, error) {
+	var identity models.OAuthIdentity
+	if err := models.DB.First(&identity, "provider = ? AND subject = ?", provider, info.Subject).Error; err == nil {
+		now := time.Now()
+		models.DB.Model(&identity).Update("last_login_at", now)
+		return GetUserByID(identity.UserID)
+	}
+
+	email := ""
+	if info.EmailVerified {
+		if normalized, err := NormalizeEmail(info.Email); err == nil {
+			email = normalized
+		}
+	}
+
+	// First login: link to the user owning the same verified email, if allowed
+	if email != "" && config.Cfg.OAuthLinkByEmail {
+		if user, err := findUserByEmail(email); err == nil {
+			if err := createOAuthIdentity(user.ID, provider, info); err != nil {
+				return nil, err
+			}
+			return user, nil
+		}
+	}
+
+	user := &models.User{
+		ID:       uuid.New().String(),
+		Nickname: truncate(info.Name, 100),
+		Avatar:   truncate(info.AvatarURL, 512),
+		Status:   models.UserStatusActive,
+	}
+	if email != "" {
+		if _, err := findUserByEmail(email); err != nil {
+			now := time.Now()
+			user.Email = &email
+			user.EmailVerifiedAt = &now
+		}
+	}
+	if err := createUser(user); err != nil {
+		return nil, err
+	}
+	if err := createOAuthIdentity(user.ID, provider, info); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// linkOAuthIdentity links a provider identity to a logged-in user
+func linkOAuthIdentity(userID, provider string, info *OAuthUserInfo) error {
+	var identity models.OAuthIdentity
+	if err := models.DB.First(&identity, "provider = ? AND subject = ?", provider, info.Subject).Error; err == nil {
+		if identity.UserID != userID {
+			return ErrOAuthIdentityTaken
+		}
+		return nil
+	}
+
+	var count int64
+	models.DB.Model(&models.OAuthIdentity{}).Where("user_id = ? AND provider = ?", userID, provider).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("a %s account is already linked, unlink it first", provider)
+	}
+
+	return createOAuthIdentity(userID, provider, info)
+}
+
+func createOAuthIdentity(userID, provider string, info *OAuthUserInfo) error {
+	now := time.Now()
+	identity := &models.OAuthIdentity{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Provider:    provider,
+		Subject:     info.Subject,
+		Email:       truncate(info.Email, 255),
+		DisplayName: truncate(info.Name, 100),
+		LastLoginAt: &now,
+	}
+	if err := models.DB.Create(identity).Error; err != nil {
+		return fmt.Errorf("failed to link %s account: %w", provider, err)
+	}
+	return nil
+}
+
+// ExchangeOAuthCode redeems the one-time code from a login callback and starts a session
+func ExchangeOAuthCode(code string, client ClientInfo) (*models.User, *TokenPair, error) {
+	var state models.OAuthState
+	if err := models.DB.First(&state, "exchange_hash = ?", hashToken(code)).Error; err != nil {
+		return nil, nil, ErrOAuthInvalidCode
+	}
+	if state.ExchangedAt != nil || state.ExchangeExpiresAt == nil || time.Now().After(*state.ExchangeExpiresAt) {
+		return nil, nil, ErrOAuthInvalidCode
+	}
+
+	result := models.DB.Model(&models.OAuthState{}).
+		Where("id = ? AND exchanged_at IS NULL", state.ID).
+		Update("exchanged_at", time.Now())
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrOAuthInvalidCode
+	}
+
+	user, err := GetUserByID(state.ResultUserID)
+	if err != nil {
+		return nil, nil, ErrOAuthInvalidCode
+	}
+
+	return completeLogin(user, client, "oauth:"+state.Provider)
+}
+
+// ListOAuthIdentities lists the provider accounts linked to a user
+func ListOAuthIdentities(userID string) ([]models.OAuthIdentity, error) {
+	var identities []models.OAuthIdentity
+	if err := models.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// UnlinkOAuthIdentity removes a linked provider account
+// The account must keep at least one other way to log in
+func UnlinkOAuthIdentity(userID, provider string) error {
+	var identity models.OAuthIdentity
+	if err := models.DB.First(&identity, "user_id = ? AND provider = ?", userID, provider).Error; err != nil {
+		return ErrOAuthProviderNotFound
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	var others int64
+	models.DB.Model(&models.OAuthIdentity{}).Where("user_id = ? AND id <> ?", userID, identity.ID).Count(&others)
+	if user.Phone == nil && user.Email == nil && others == 0 {
+		return ErrOAuthLastLoginMethod
+	}
+
+	return models.DB.Delete(&identity).Error
+}
+
+// PurgeOAuthStates removes authorization attempts created before the given time
+func PurgeOAuthStates(before time.Time) (int64, error) {
+	result := models.DB.Where("created_at < ?", before).Delete(&models.OAuthState{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-server/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcDiscoveryTTL controls how often discovery and signing keys are reloaded
+const oidcDiscoveryTTL = time.Hour
+
+// OIDCProvider implements login with any OpenID Connect provider using the authorization code flow with PKCE
+// Endpoints are loaded from the issuer's discovery document, so it works against a local mock OIDC server
+type OIDCProvider struct {
+	name         string
+	displayName  string
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	loadedAt  time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type oidcClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"` // Some providers send "true" as a string
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// NewOIDCProvider creates a generic OpenID Connect provider
+func NewOIDCProvider(name, displayName, issuer, clientID, clientSecret string, scopes []string) *OIDCProvider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	return &OIDCProvider{
+		name:         name,
+		displayName:  displayName,
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+	}
+}
+
+// Name returns the provider name
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// DisplayName returns the name shown to users
+func (p *OIDCProvider) DisplayName() string {
+	return p.displayName
+}
+
+// AuthorizeURL builds the authorization request with state, nonce and PKCE challenge
+func (p *OIDCProvider) AuthorizeURL(ctx context.Context, redirectURI string, state *models.OAuthState, rawState string) (string, error) {
+	discovery, err := p.loadDiscovery(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("scope", strings.Join(p.scopes, " "))
+	params.Set("state", rawState)
+	params.Set("nonce", state.Nonce)
+	params.Set("code_challenge", pkceChallenge(state.CodeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return discovery.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange redeems the code, verifies the ID token and returns the identity
+func (p *OIDCProvider) Exchange(ctx context.Context, redirectURI, code string, state *models.OAuthState) (*OAuthUserInfo, error) {
+	discovery, err := p.loadDiscovery(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", state.CodeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+
+	var token oidcTokenResponse
+	if err := doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("token request failed: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, token.IDToken, discovery.Issuer, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &OAuthUserInfo{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claimBool(claims.EmailVerified),
+		Name:          claims.Name,
+		AvatarURL:     claims.Picture,
+	}
+
+	// Some providers only return profile fields from the userinfo endpoint
+	if info.Email == "" && discovery.UserinfoEndpoint != "" && token.AccessToken != "" {
+		p.fillFromUserinfo(ctx, discovery.UserinfoEndpoint, token.AccessToken, info)
+	}
+
+	return info, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken, issuer, nonce string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// signingKey returns the RSA key with the given ID, reloading the key set once for unknown keys
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	for _, reload := range []bool{false, true} {
+		if _, err := p.loadDiscovery(ctx, reload); err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		key, ok := p.keys[kid]
+		if !ok && kid == "" && len(p.keys) == 1 {
+			for _, k := range p.keys {
+				key, ok = k, true
+			}
+		}
+		p.mu.Unlock()
+
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// loadDiscovery fetches the discovery document and signing keys, cached for oidcDiscoveryTTL
+func (p *OIDCProvider) loadDiscovery(ctx context.Context, force bool) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	if p.discovery != nil && !force && time.Since(p.loadedAt) < oidcDiscoveryTTL {
+		d := p.discovery
+		p.mu.Unlock()
+		return d, nil
+	}
+	p.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var discovery oidcDiscovery
+	if err := doJSON(req, &discovery); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC discovery: %w", err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer mismatch: %s", discovery.Issuer)
+	}
+
+	keys, err := fetchJWKS(ctx, discovery.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.discovery = &discovery
+	p.keys = keys
+	p.loadedAt = time.Now()
+	p.mu.Unlock()
+
+	return &discovery, nil
+}
+
+// fillFromUserinfo copies email and profile fields from the userinfo endpoint
+func (p *OIDCProvider) fillFromUserinfo(ctx context.Context, endpoint, accessToken string, info *OAuthUserInfo) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var userinfo struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+	if err := doJSON(req, &userinfo); err != nil || userinfo.Sub != info.Subject {
+		return
+	}
+
+	info.Email = userinfo.Email
+	info.EmailVerified = claimBool(userinfo.EmailVerified)
+	if info.Name == "" {
+		info.Name = userinfo.Name
+	}
+	if info.AvatarURL == "" {
+		info.AvatarURL = userinfo.Picture
+	}
+}
+
+// fetchJWKS loads the RSA signing keys of a JSON Web Key Set
+func fetchJWKS(ctx context.Context, jwksURI string) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := doJSON(req, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("OIDC provider has no RSA signing keys")
+	}
+	return keys, nil
+}
+
+// doJSON performs a request and decodes a JSON response
+func doJSON(req *http.Request, v interface{}) error {
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response (status %d): %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+// claimBool reads a boolean claim that may be encoded as a string
+func claimBool(v any) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	}
+	return false
+}
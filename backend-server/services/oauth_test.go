@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"backend-server/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCServer is a minimal OpenID Connect provider that signs ID tokens for a fixed subject
+type mockOIDCServer struct {
+	*httptest.Server
+	key     *rsa.PrivateKey
+	subject string
+
+	mu        sync.Mutex
+	nonce     string
+	challenge string
+}
+
+func newMockOIDCServer(t *testing.T, subject string) *mockOIDCServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	m := &mockOIDCServer{key: key, subject: subject}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mu.Lock()
+		nonce, challenge := m.nonce, m.challenge
+		m.mu.Unlock()
+
+		if pkceChallenge(r.PostForm.Get("code_verifier")) != challenge {
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            m.URL,
+			"aud":            "test-client",
+			"sub":            m.subject,
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          nonce,
+			"email":          m.subject + "@example.com",
+			"email_verified": true,
+		})
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "id_token": idToken})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// authorize plays the provider's login page: it records the nonce and PKCE challenge of the
+// authorization request and returns the state the browser is redirected back with
+func (m *mockOIDCServer) authorize(t *testing.T, authorizeURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(authorizeURL)
+	if err != nil {
+		t.Fatalf("parse authorize URL: %v", err)
+	}
+	query := u.Query()
+
+	m.mu.Lock()
+	m.nonce = query.Get("nonce")
+	m.challenge = query.Get("code_challenge")
+	m.mu.Unlock()
+	return query.Get("state")
+}
+
+// setupMockOIDC registers a mock OIDC provider named "mock"
+func setupMockOIDC(t *testing.T, subject string) *mockOIDCServer {
+	t.Helper()
+
+	m := newMockOIDCServer(t, subject)
+	previous := oauthProviders
+	oauthProviders = map[string]OAuthProvider{
+		"mock": NewOIDCProvider("mock", "Mock", m.URL, "test-client", "test-secret", nil),
+	}
+	t.Cleanup(func() { oauthProviders = previous })
+	return m
+}
+
+func TestOIDCLogin(t *testing.T) {
+	setupTestDB(t)
+	m := setupMockOIDC(t, "alice")
+	ctx := context.Background()
+
+	authorizeURL, err := StartOAuth(ctx, "mock", "", "browser-key")
+	if err != nil {
+		t.Fatalf("StartOAuth: %v", err)
+	}
+	state := m.authorize(t, authorizeURL)
+
+	result, err := HandleOAuthCallback(ctx, "mock", "code", state, "browser-key")
+	if err != nil {
+		t.Fatalf("HandleOAuthCallback: %v", err)
+	}
+	if result.Linked || result.ExchangeCode == "" {
+		t.Fatalf("expected an exchange code, got %+v", result)
+	}
+
+	user, tokens, err := ExchangeOAuthCode(result.ExchangeCode, ClientInfo{})
+	if err != nil {
+		t.Fatalf("ExchangeOAuthCode: %v", err)
+	}
+	if tokens.AccessToken == "" {
+		t.Fatal("expected an access token")
+	}
+
+	var identity models.OAuthIdentity
+	if err := models.DB.First(&identity, "provider = ? AND subject = ?", "mock", "alice").Error; err != nil {
+		t.Fatalf("identity not created: %v", err)
+	}
+	if identity.UserID != user.ID {
+		t.Fatalf("identity linked to %s, want %s", identity.UserID, user.ID)
+	}
+
+	// The state can only be used once
+	if _, err := HandleOAuthCallback(ctx, "mock", "code", state, "browser-key"); !errors.Is(err, ErrOAuthInvalidState) {
+		t.Fatalf("reused state: got %v, want ErrOAuthInvalidState", err)
+	}
+}
+
+func TestOAuthCallbackFromAnotherBrowser(t *testing.T) {
+	setupTestDB(t)
+	m := setupMockOIDC(t, "alice")
+	ctx := context.Background()
+
+	authorizeURL, err := StartOAuth(ctx, "mock", "", "attacker-browser")
+	if err != nil {
+		t.Fatalf("StartOAuth: %v", err)
+	}
+	state := m.authorize(t, authorizeURL)
+
+	for _, browserKey := range []string{"victim-browser", ""} {
+		if _, err := HandleOAuthCallback(ctx, "mock", "code", state, browserKey); !errors.Is(err, ErrOAuthBrowserMismatch) {
+			t.Fatalf("callback with browser key %q: got %v, want ErrOAuthBrowserMismatch", browserKey, err)
+		}
+	}
+
+	// A rejected callback does not use up the attempt of the browser that started it
+	if _, err := HandleOAuthCallback(ctx, "mock", "code", state, "attacker-browser"); err != nil {
+		t.Fatalf("callback from the starting browser: %v", err)
+	}
+}
+
+func TestOAuthLinkFromAnotherBrowser(t *testing.T) {
+	setupTestDB(t)
+	m := setupMockOIDC(t, "victim")
+	ctx := context.Background()
+	attacker := createTestUser(t, 0)
+
+	// The attacker starts linking and sends the provider URL to the victim
+	authorizeURL, err := StartOAuth(ctx, "mock", attacker.ID, "attacker-browser")
+	if err != nil {
+		t.Fatalf("StartOAuth: %v", err)
+	}
+	state := m.authorize(t, authorizeURL)
+
+	if _, err := HandleOAuthCallback(ctx, "mock", "code", state, "victim-browser"); !errors.Is(err, ErrOAuthBrowserMismatch) {
+		t.Fatalf("got %v, want ErrOAuthBrowserMismatch", err)
+	}
+
+	var count int64
+	models.DB.Model(&models.OAuthIdentity{}).Where("user_id = ?", attacker.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("victim's identity was linked to the attacker")
+	}
+}
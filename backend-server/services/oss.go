@@ -105,3 +105,16 @@ func (r *bytesReader) Read(p []byte) (n int, err error) {
 func GetObject(objectKey string) (io.ReadCloser, error) {
 	return ossBucket.GetObject(objectKey)
 }
+
+// GetObjectRange retrieves the byte range [start, end] (inclusive) of an object from OSS
+func GetObjectRange(objectKey string, start, end int64) (io.ReadCloser, error) {
+	return ossBucket.GetObject(objectKey, oss.Range(start, end))
+}
+
+// DeleteObject deletes an object from OSS (deleting a missing object is not an error)
+func DeleteObject(objectKey string) error {
+	if err := ossBucket.DeleteObject(objectKey); err != nil {
+		return fmt.Errorf("failed to delete from OSS: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"backend-server/config"
+)
+
+// DefaultCountryCode is assumed when a request carries no country code
+const DefaultCountryCode = "86"
+
+var ErrInvalidPhone = errors.New("invalid phone number format")
+
+// phoneRegion describes the mobile number format of a calling code
+type phoneRegion struct {
+	Region  string
+	Pattern *regexp.Regexp // Matches the national number without trunk prefix
+}
+
+// phoneRegions lists the calling codes with known mobile number formats
+// Numbers from other calling codes only pass the generic E.164 length check
+var phoneRegions = map[string]phoneRegion{
+	"86":  {"CN", regexp.MustCompile(`^1[3-9]\d{9}$`)},
+	"852": {"HK", regexp.MustCompile(`^[4-9]\d{7}$`)},
+	"853": {"MO", regexp.MustCompile(`^6\d{7}$`)},
+	"886": {"TW", regexp.MustCompile(`^9\d{8}$`)},
+	"1":   {"US", regexp.MustCompile(`^[2-9]\d{2}[2-9]\d{6}$`)},
+	"44":  {"GB", regexp.MustCompile(`^7\d{9}$`)},
+	"61":  {"AU", regexp.MustCompile(`^4\d{8}$`)},
+	"65":  {"SG", regexp.MustCompile(`^[89]\d{7}$`)},
+	"60":  {"MY", regexp.MustCompile(`^1\d{8,9}$`)},
+	"81":  {"JP", regexp.MustCompile(`^[789]0\d{8}$`)},
+	"82":  {"KR", regexp.MustCompile(`^1\d{8,9}$`)},
+}
+
+// twoDigitCallingCodes lists the assigned two-digit ITU calling codes
+// Calling codes are prefix-free: +1 and +7 are the only one-digit codes, every other code that is
+// not listed here has three digits
+var twoDigitCallingCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true,
+	"39": true, "40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true,
+	"48": true, "49": true, "51": true, "52": true, "53": true, "54": true, "55": true, "56": true,
+	"57": true, "58": true, "60": true, "61": true, "62": true, "63": true, "64": true, "65": true,
+	"66": true, "81": true, "82": true, "84": true, "86": true, "90": true, "91": true, "92": true,
+	"93": true, "94": true, "95": true, "98": true,
+}
+
+var (
+	countryCodePattern    = regexp.MustCompile(`^[1-9]\d{0,2}$`)
+	nationalNumberPattern = regexp.MustCompile(`^\d{4,14}$`)
+	e164Pattern           = regexp.MustCompile(`^[1-9]\d{6,14}$`)
+)
+
+// NormalizePhone validates a phone number and returns it in E.164 format (e.g. +8613800138000)
+// The number may be given in national form together with countryCode, or already in E.164 form
+// An empty countryCode defaults to mainland China
+func NormalizePhone(countryCode, phone string) (string, error) {
+	phone = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '(' || r == ')' {
+			return -1
+		}
+		return r
+	}, phone)
+	countryCode = strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+
+	if strings.HasPrefix(phone, "+") {
+		cc, national, ok := splitE164(phone, countryCode)
+		if !ok {
+			return "", ErrInvalidPhone
+		}
+		countryCode, phone = cc, national
+	} else if countryCode == "" {
+		countryCode = DefaultCountryCode
+	}
+
+	if !countryCodePattern.MatchString(countryCode) {
+		return "", ErrInvalidPhone
+	}
+	if !isCountryCodeAllowed(countryCode) {
+		return "", errors.New("phone numbers from this region are not supported")
+	}
+
+	// Drop the trunk prefix used when dialing nationally, e.g. 07700 900123 in the UK
+	if countryCode != "86" {
+		phone = strings.TrimPrefix(phone, "0")
+	}
+
+	if region, ok := phoneRegions[countryCode]; ok {
+		if !region.Pattern.MatchString(phone) {
+			return "", ErrInvalidPhone
+		}
+	} else if !nationalNumberPattern.MatchString(phone) || len(countryCode)+len(phone) > 15 {
+		return "", ErrInvalidPhone
+	}
+
+	return "+" + countryCode + phone, nil
+}
+
+// splitE164 splits an E.164 number into calling code and national number
+// When countryCode is given the number must start with it, otherwise the calling code is read from
+// the number itself, so numbers of regions without a known format are accepted as well
+func splitE164(phone, countryCode string) (string, string, bool) {
+	digits := strings.TrimPrefix(phone, "+")
+	if !e164Pattern.MatchString(digits) {
+		return "", "", false
+	}
+
+	if countryCode != "" {
+		if !strings.HasPrefix(digits, countryCode) {
+			return "", "", false
+		}
+		return countryCode, digits[len(countryCode):], true
+	}
+
+	n := callingCodeLength(digits)
+	return digits[:n], digits[n:], true
+}
+
+// callingCodeLength returns the length of the calling code an E.164 number starts with
+func callingCodeLength(digits string) int {
+	switch {
+	case digits[0] == '1' || digits[0] == '7':
+		return 1
+	case twoDigitCallingCodes[digits[:2]]:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// isCountryCodeAllowed checks the calling code against PHONE_ALLOWED_COUNTRY_CODES
+func isCountryCodeAllowed(countryCode string) bool {
+	allowed := config.Cfg.PhoneAllowedCountryCodes
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, cc := range allowed {
+		if strings.TrimPrefix(cc, "+") == countryCode {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMainlandChinaPhone checks if an E.164 number belongs to mainland China
+func IsMainlandChinaPhone(phone string) bool {
+	return strings.HasPrefix(phone, "+"+DefaultCountryCode)
+}
@@ -0,0 +1,36 @@
+package services
+
+import "testing"
+
+func TestNormalizePhoneE164WithoutCountryCode(t *testing.T) {
+	setupTestDB(t)
+
+	cases := []struct {
+		phone string
+		want  string
+	}{
+		{"+8613800138000", "+8613800138000"},
+		{"+85291234567", "+85291234567"},
+		{"+33 6 12 34 56 78", "+33612345678"}, // France, no known format
+		{"+79161234567", "+79161234567"},      // Russia, one-digit calling code
+		{"+971501234567", "+971501234567"},    // UAE, three-digit calling code
+		{"+4915123456789", "+4915123456789"},  // Germany
+		{"+2348031234567", "+2348031234567"},  // Nigeria
+	}
+	for _, c := range cases {
+		got, err := NormalizePhone("", c.phone)
+		if err != nil {
+			t.Errorf("%s: %v", c.phone, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %s, want %s", c.phone, got, c.want)
+		}
+	}
+
+	for _, phone := range []string{"+86123", "+4412345678901234", "+0123456789"} {
+		if _, err := NormalizePhone("", phone); err == nil {
+			t.Errorf("%s: accepted an invalid number", phone)
+		}
+	}
+}
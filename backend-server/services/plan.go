@@ -0,0 +1,278 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// whitelistPlanName is the plan that PHONE_WHITELIST entries are imported into
+const whitelistPlanName = "whitelist"
+
+var (
+	ErrPlanNotFound  = errors.New("plan not found")
+	ErrPlanNameTaken = errors.New("plan name is already in use")
+)
+
+// whitelistPlanID is the ID of the imported whitelist plan, empty if PHONE_WHITELIST is not set
+var whitelistPlanID string
+
+// PlanInput holds the editable fields of a plan
+type PlanInput struct {
+	Name             string
+	Description      string
+	UnlimitedCredits bool
+	UnlimitedStorage bool
+
+	TaskPriority       int
+	QueueWeight        int
+	MaxProcessingTasks int
+
+	AdvancedParams *string
+}
+
+// importPhoneWhitelist moves the deprecated PHONE_WHITELIST into a plan with unlimited credits and storage
+// Whitelisted users without a plan are assigned to it; admins can change assignments afterwards
+func importPhoneWhitelist() error {
+	phones := legacyWhitelistPhones()
+	if len(phones) == 0 {
+		return nil
+	}
+
+	var plan models.Plan
+	err := models.DB.Where("name = ?", whitelistPlanName).First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		plan = models.Plan{
+			ID:               uuid.New().String(),
+			Name:             whitelistPlanName,
+			Description:      "Imported from PHONE_WHITELIST",
+			UnlimitedCredits: true,
+			UnlimitedStorage: true,
+		}
+		err = models.DB.Create(&plan).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create whitelist plan: %w", err)
+	}
+	whitelistPlanID = plan.ID
+
+	result := models.DB.Model(&models.User{}).
+		Where("phone IN ? AND plan_id IS NULL", phones).
+		Update("plan_id", plan.ID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to import phone whitelist: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Assigned %d whitelisted users to plan %q", result.RowsAffected, whitelistPlanName)
+	}
+	log.Printf("PHONE_WHITELIST is deprecated, manage the %q plan through the admin API instead", whitelistPlanName)
+	return nil
+}
+
+// legacyWhitelistPhones returns PHONE_WHITELIST in E.164 format
+// Entries without a calling code are treated as mainland China numbers
+func legacyWhitelistPhones() []string {
+	phones := make([]string, 0, len(config.Cfg.PhoneWhitelist))
+	for _, p := range config.Cfg.PhoneWhitelist {
+		if normalized, err := NormalizePhone("", p); err == nil {
+			phones = append(phones, normalized)
+		}
+	}
+	return phones
+}
+
+// applyInitialAccess sets the role and plan of a user that is about to be created
+func applyInitialAccess(user *models.User) {
+	if user.Role == "" {
+		user.Role = models.UserRoleUser
+	}
+	if isConfiguredAdmin(user) {
+		user.Role = models.UserRoleAdmin
+	}
+
+	if whitelistPlanID == "" || user.PlanID != nil || user.Phone == nil {
+		return
+	}
+	for _, p := range legacyWhitelistPhones() {
+		if p == *user.Phone {
+			planID := whitelistPlanID
+			user.PlanID = &planID
+			return
+		}
+	}
+}
+
+// getUserPlan returns the plan assigned to a user, or nil if the user has none
+func getUserPlan(user *models.User) *models.Plan {
+	if user.PlanID == nil {
+		return nil
+	}
+	var plan models.Plan
+	if err := models.DB.First(&plan, "id = ?", *user.PlanID).Error; err != nil {
+		return nil
+	}
+	return &plan
+}
+
+// HasUnlimitedCredits checks if the user's plan exempts them from credit consumption
+func HasUnlimitedCredits(user *models.User) bool {
+	plan := getUserPlan(user)
+	return plan != nil && plan.UnlimitedCredits
+}
+
+// HasUnlimitedStorage checks if the user's plan exempts them from the storage quota and retention
+func HasUnlimitedStorage(user *models.User) bool {
+	plan := getUserPlan(user)
+	return plan != nil && plan.UnlimitedStorage
+}
+
+// ListPlans returns all plans with the number of users assigned to each
+func ListPlans() ([]models.Plan, map[string]int64, error) {
+	var plans []models.Plan
+	if err := models.DB.Order("name ASC").Find(&plans).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to list plans: %w", err)
+	}
+
+	var rows []struct {
+		PlanID string
+		Count  int64
+	}
+	if err := models.DB.Model(&models.User{}).
+		Select("plan_id, COUNT(*) AS count").
+		Where("plan_id IS NOT NULL").
+		Group("plan_id").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to count plan users: %w", err)
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		counts[r.PlanID] = r.Count
+	}
+	return plans, counts, nil
+}
+
+// CreatePlan creates a new plan
+func CreatePlan(input PlanInput) (*models.Plan, error) {
+	if err := checkPlanName(input.Name, ""); err != nil {
+		return nil, err
+	}
+	advancedParams, err := normalizeAdvancedParamList(input.AdvancedParams)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := models.Plan{
+		ID:               uuid.New().String(),
+		Name:             input.Name,
+		Description:      input.Description,
+		UnlimitedCredits: input.UnlimitedCredits,
+		UnlimitedStorage: input.UnlimitedStorage,
+
+		TaskPriority:       input.TaskPriority,
+		QueueWeight:        input.QueueWeight,
+		MaxProcessingTasks: input.MaxProcessingTasks,
+
+		AdvancedParams: advancedParams,
+	}
+	if err := models.DB.Create(&plan).Error; err != nil {
+		return nil, fmt.Errorf("failed to create plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// GetPlan loads a plan by ID
+func GetPlan(planID string) (*models.Plan, error) {
+	var plan models.Plan
+	if err := models.DB.First(&plan, "id = ?", planID).Error; err != nil {
+		return nil, ErrPlanNotFound
+	}
+	return &plan, nil
+}
+
+// UpdatePlan replaces the editable fields of a plan; changes apply to assigned users immediately
+func UpdatePlan(planID string, input PlanInput) (*models.Plan, error) {
+	plan, err := GetPlan(planID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPlanName(input.Name, plan.ID); err != nil {
+		return nil, err
+	}
+	advancedParams, err := normalizeAdvancedParamList(input.AdvancedParams)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Name = input.Name
+	plan.Description = input.Description
+	plan.UnlimitedCredits = input.UnlimitedCredits
+	plan.UnlimitedStorage = input.UnlimitedStorage
+	plan.TaskPriority = input.TaskPriority
+	plan.QueueWeight = input.QueueWeight
+	plan.MaxProcessingTasks = input.MaxProcessingTasks
+	plan.AdvancedParams = advancedParams
+	if err := models.DB.Save(plan).Error; err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+	return plan, nil
+}
+
+// DeletePlan deletes a plan and unassigns its users
+func DeletePlan(planID string) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", planID).Delete(&models.Plan{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete plan: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrPlanNotFound
+		}
+		return tx.Model(&models.User{}).Unscoped().Where("plan_id = ?", planID).Update("plan_id", nil).Error
+	})
+}
+
+// AssignPlan assigns a plan to a user, an empty plan ID removes the user's plan
+func AssignPlan(userID, planID string) (*models.User, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var value *string
+	if planID != "" {
+		plan, err := GetPlan(planID)
+		if err != nil {
+			return nil, err
+		}
+		value = &plan.ID
+	}
+
+	if err := models.DB.Model(user).Update("plan_id", value).Error; err != nil {
+		return nil, fmt.Errorf("failed to assign plan: %w", err)
+	}
+	user.PlanID = value
+	return user, nil
+}
+
+// checkPlanName makes sure no other plan uses the name
+func checkPlanName(name, exceptID string) error {
+	var count int64
+	query := models.DB.Model(&models.Plan{}).Where("name = ?", name)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check plan name: %w", err)
+	}
+	if count > 0 {
+		return ErrPlanNameTaken
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"backend-server/config"
+	"backend-server/models"
+)
+
+// etaSampleSize is the number of recently completed tasks the processing speed is averaged over
+const etaSampleSize = 100
+
+// processingSpeed is a rolling average of the processing time per character of completed tasks
+type processingSpeed struct {
+	mu      sync.Mutex
+	samples []float64 // Milliseconds per character, oldest first
+	sum     float64
+}
+
+var speed = &processingSpeed{}
+
+func (s *processingSpeed) add(msPerChar float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.samples = append(s.samples, msPerChar)
+	s.sum += msPerChar
+	if len(s.samples) > etaSampleSize {
+		s.sum -= s.samples[0]
+		s.samples = s.samples[1:]
+	}
+}
+
+// msPerChar returns the average processing time per character, or the configured default before any task was measured
+func (s *processingSpeed) msPerChar() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.samples) == 0 {
+		return float64(config.Cfg.QueueETADefaultMsPerChar)
+	}
+	return s.sum / float64(len(s.samples))
+}
+
+// TaskEstimate is the expected queue position and timing of a scheduled, pending or processing task
+type TaskEstimate struct {
+	QueuePosition int64      // 0 for tasks that are scheduled or already processing
+	StartAt       *time.Time // Nil while the worker is paused
+	FinishAt      *time.Time
+}
+
+// PublicQueueStats is the queue summary shown to all users
+type PublicQueueStats struct {
+	Pending              int64   `json:"pending"`
+	Processing           int64   `json:"processing"`
+	Paused               bool    `json:"paused"`
+	AvgSecondsPerChar    float64 `json:"avg_seconds_per_char"`
+	EstimatedWaitSeconds int64   `json:"estimated_wait_seconds"` // Until a task submitted now starts, 0 while paused
+}
+
+// queueSnapshot holds the queue-wide figures estimates are based on
+type queueSnapshot struct {
+	now            time.Time
+	msPerChar      float64
+	concurrency    int
+	paused         bool
+	pending        int64
+	processing     int64
+	avgPendingMs   float64         // Expected processing time of an average pending task
+	processingLeft float64         // Expected remaining processing time of all processing tasks, in ms
+	positions      *queuePositions // Loaded by EstimateTasks when pending tasks are estimated
+}
+
+// InitQueueETA seeds the processing speed from recently completed tasks
+func InitQueueETA() error {
+	var tasks []models.Task
+	if err := models.DB.Select("id, text, started_at, finished_at").
+		Where("status = ? AND started_at IS NOT NULL AND finished_at IS NOT NULL", models.TaskStatusCompleted).
+		Order("finished_at DESC").
+		Limit(etaSampleSize).
+		Find(&tasks).Error; err != nil {
+		return fmt.Errorf("failed to load completed tasks: %w", err)
+	}
+
+	for i := len(tasks) - 1; i >= 0; i-- {
+		recordProcessingTime(&tasks[i], tasks[i].FinishedAt.Sub(*tasks[i].StartedAt))
+	}
+	return nil
+}
+
+// recordProcessingTime adds the processing time of a completed task to the rolling average
+func recordProcessingTime(task *models.Task, elapsed time.Duration) {
+	chars := utf8.RuneCountInString(task.Text)
+	if chars == 0 || elapsed <= 0 {
+		return
+	}
+	speed.add(float64(elapsed.Milliseconds()) / float64(chars))
+}
+
+// expectedProcessingMs returns how long a task is expected to take once started
+func expectedProcessingMs(task *models.Task, msPerChar float64) float64 {
+	return float64(utf8.RuneCountInString(task.Text)) * msPerChar
+}
+
+func loadQueueSnapshot() (*queueSnapshot, error) {
+	q := &queueSnapshot{
+		now:         time.Now(),
+		msPerChar:   speed.msPerChar(),
+		concurrency: max(config.Cfg.WorkerConcurrency, 1),
+	}
+	if w := GetWorker(); w != nil {
+		q.paused = w.isPaused()
+	}
+
+	var pending struct {
+		Count    int64
+		AvgChars *float64
+	}
+	if err := models.DB.Model(&models.Task{}).
+		Select("COUNT(*) AS count, AVG(CHAR_LENGTH(text)) AS avg_chars").
+		Where("status = ?", models.TaskStatusPending).
+		Scan(&pending).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize pending tasks: %w", err)
+	}
+	q.pending = pending.Count
+	if pending.AvgChars != nil {
+		q.avgPendingMs = *pending.AvgChars * q.msPerChar
+	}
+
+	var processing []models.Task
+	if err := models.DB.Select("id, text, started_at").
+		Where("status = ?", models.TaskStatusProcessing).
+		Find(&processing).Error; err != nil {
+		return nil, fmt.Errorf("failed to load processing tasks: %w", err)
+	}
+	q.processing = int64(len(processing))
+	for i := range processing {
+		if left := q.processingRemainingMs(&processing[i]); left > 0 {
+			q.processingLeft += left
+		}
+	}
+
+	return q, nil
+}
+
+// processingRemainingMs returns the expected remaining time of a processing task, negative if it is overdue
+func (q *queueSnapshot) processingRemainingMs(task *models.Task) float64 {
+	expected := expectedProcessingMs(task, q.msPerChar)
+	if task.StartedAt == nil {
+		return expected
+	}
+	return expected - float64(q.now.Sub(*task.StartedAt).Milliseconds())
+}
+
+// waitMs returns the expected time until the task at the given queue position starts
+// Tasks ahead are assumed to be of average length and the worker slots to share the work evenly
+func (q *queueSnapshot) waitMs(position int64) float64 {
+	ahead := float64(position-1) * q.avgPendingMs
+	return (q.processingLeft + ahead) / float64(q.concurrency)
+}
+
+func (q *queueSnapshot) at(ms float64) *time.Time {
+	t := q.now.Add(time.Duration(ms) * time.Millisecond)
+	return &t
+}
+
+func (q *queueSnapshot) estimate(task *models.Task) *TaskEstimate {
+	switch task.Status {
+	case models.TaskStatusScheduled:
+		// Assumes the queue is empty by then
+		if task.RunAt == nil {
+			return nil
+		}
+		finish := task.RunAt.Add(time.Duration(expectedProcessingMs(task, q.msPerChar)) * time.Millisecond)
+		return &TaskEstimate{StartAt: task.RunAt, FinishAt: &finish}
+	case models.TaskStatusProcessing:
+		return &TaskEstimate{
+			StartAt:  task.StartedAt,
+			FinishAt: q.at(max(q.processingRemainingMs(task), 0)),
+		}
+	case models.TaskStatusPending:
+		position := q.positions.position(task)
+		est := &TaskEstimate{QueuePosition: position}
+		if !q.paused {
+			wait := q.waitMs(position)
+			est.StartAt = q.at(wait)
+			est.FinishAt = q.at(wait + expectedProcessingMs(task, q.msPerChar))
+		}
+		return est
+	}
+	return nil
+}
+
+// EstimateTasks returns the queue position and expected start and finish time of scheduled, pending
+// and processing tasks, keyed by task ID. Tasks in other states are left out.
+// The queue is read once for all tasks, however many there are.
+func EstimateTasks(tasks []models.Task) (map[string]*TaskEstimate, error) {
+	estimates := make(map[string]*TaskEstimate)
+
+	var active []*models.Task
+	var pendingUsers []string
+	seen := make(map[string]bool)
+	for i := range tasks {
+		task := &tasks[i]
+		switch task.Status {
+		case models.TaskStatusScheduled, models.TaskStatusProcessing:
+		case models.TaskStatusPending:
+			if !seen[task.UserID] {
+				seen[task.UserID] = true
+				pendingUsers = append(pendingUsers, task.UserID)
+			}
+		default:
+			continue
+		}
+		active = append(active, task)
+	}
+	if len(active) == 0 {
+		return estimates, nil
+	}
+
+	q, err := loadQueueSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if len(pendingUsers) > 0 {
+		if q.positions, err = loadQueuePositions(pendingUsers); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, task := range active {
+		estimates[task.ID] = q.estimate(task)
+	}
+	return estimates, nil
+}
+
+// publicQueueStatsTTL is how long the public queue statistics are cached
+// The endpoint is public and polled by every open frontend, so the queue is read at most this often.
+const publicQueueStatsTTL = 5 * time.Second
+
+var publicQueueStats struct {
+	mu       sync.Mutex
+	stats    *PublicQueueStats
+	loadedAt time.Time
+}
+
+// GetPublicQueueStats returns the queue length and the expected wait for a task submitted now,
+// cached for publicQueueStatsTTL
+func GetPublicQueueStats() (*PublicQueueStats, error) {
+	publicQueueStats.mu.Lock()
+	defer publicQueueStats.mu.Unlock()
+
+	if publicQueueStats.stats != nil && time.Since(publicQueueStats.loadedAt) < publicQueueStatsTTL {
+		return publicQueueStats.stats, nil
+	}
+	stats, err := loadPublicQueueStats()
+	if err != nil {
+		return nil, err
+	}
+	publicQueueStats.stats = stats
+	publicQueueStats.loadedAt = time.Now()
+	return stats, nil
+}
+
+func loadPublicQueueStats() (*PublicQueueStats, error) {
+	q, err := loadQueueSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &PublicQueueStats{
+		Pending:           q.pending,
+		Processing:        q.processing,
+		Paused:            q.paused,
+		AvgSecondsPerChar: q.msPerChar / 1000,
+	}
+	if !q.paused {
+		stats.EstimatedWaitSeconds = int64(q.waitMs(q.pending+1) / 1000)
+	}
+	return stats, nil
+}
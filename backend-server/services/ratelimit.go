@@ -0,0 +1,237 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimit allows Limit actions identified by Key per Window
+type RateLimit struct {
+	Key    string
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimiter decides whether an action identified by key may happen now
+// limit actions are allowed per window; retryAfter is set when the action is denied
+type RateLimiter interface {
+	Allow(key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+	// AllowAll counts an action against every limit, or against none of them when one is exhausted
+	// denied is the first exhausted limit, nil when the action is allowed
+	AllowAll(limits []RateLimit) (denied *RateLimit, retryAfter time.Duration, err error)
+	// Release gives back what AllowAll counted for an action that did not happen after all
+	Release(limits []RateLimit) error
+}
+
+var rateLimiter RateLimiter
+
+// InitRateLimiter initializes the rate limiter backend
+func InitRateLimiter() error {
+	switch config.Cfg.RateLimitBackend {
+	case "", "memory":
+		rateLimiter = NewMemoryRateLimiter()
+	case "db":
+		rateLimiter = NewDBRateLimiter()
+	default:
+		return fmt.Errorf("unknown rate limit backend: %s", config.Cfg.RateLimitBackend)
+	}
+	log.Printf("Rate limiter initialized (backend: %s)", config.Cfg.RateLimitBackend)
+	return nil
+}
+
+// MemoryRateLimiter is an in-process token bucket limiter, suitable for a single replica
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// memoryLimiterMaxKeys bounds memory use; full buckets are dropped beyond this size
+const memoryLimiterMaxKeys = 100000
+
+// NewMemoryRateLimiter creates an in-memory token bucket limiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the bucket of key; buckets hold limit tokens and refill over window
+func (m *MemoryRateLimiter) Allow(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	denied, retryAfter, err := m.AllowAll([]RateLimit{{Key: key, Limit: limit, Window: window}})
+	return denied == nil, retryAfter, err
+}
+
+// AllowAll takes a token from the bucket of every limit, only if all of them have one left
+func (m *MemoryRateLimiter) AllowAll(limits []RateLimit) (*RateLimit, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	buckets := make([]*tokenBucket, len(limits))
+	for i, l := range limits {
+		if l.Limit <= 0 {
+			continue
+		}
+		rate := float64(l.Limit) / l.Window.Seconds() // tokens per second
+
+		b, ok := m.buckets[l.Key]
+		if !ok {
+			if len(m.buckets) >= memoryLimiterMaxKeys {
+				m.evictFull(now, rate, float64(l.Limit))
+			}
+			b = &tokenBucket{tokens: float64(l.Limit), last: now}
+			m.buckets[l.Key] = b
+		}
+
+		b.tokens = math.Min(float64(l.Limit), b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+
+		if b.tokens < 1 {
+			retryAfter := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+			return &limits[i], retryAfter, nil
+		}
+		buckets[i] = b
+	}
+
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens--
+		}
+	}
+	return nil, 0, nil
+}
+
+// Release puts a token back into the bucket of every limit
+func (m *MemoryRateLimiter) Release(limits []RateLimit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, l := range limits {
+		if b, ok := m.buckets[l.Key]; ok && l.Limit > 0 {
+			b.tokens = math.Min(float64(l.Limit), b.tokens+1)
+		}
+	}
+	return nil
+}
+
+// evictFull drops buckets that have refilled completely, they carry no state
+func (m *MemoryRateLimiter) evictFull(now time.Time, rate, limit float64) {
+	for key, b := range m.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= limit {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// DBRateLimiter is a fixed-window counter limiter stored in the database, shared by all replicas
+type DBRateLimiter struct{}
+
+// NewDBRateLimiter creates a database-backed limiter
+func NewDBRateLimiter() *DBRateLimiter {
+	return &DBRateLimiter{}
+}
+
+// Allow increments the counter of key in the current window
+func (d *DBRateLimiter) Allow(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	denied, retryAfter, err := d.AllowAll([]RateLimit{{Key: key, Limit: limit, Window: window}})
+	return denied == nil, retryAfter, err
+}
+
+// AllowAll increments the counter of every limit in one transaction, only if none of them is full
+// Counters are locked in key order so concurrent calls with overlapping keys can not deadlock
+func (d *DBRateLimiter) AllowAll(limits []RateLimit) (*RateLimit, time.Duration, error) {
+	order := make([]int, 0, len(limits))
+	for i, l := range limits {
+		if l.Limit > 0 {
+			order = append(order, i)
+		}
+	}
+	if len(order) == 0 {
+		return nil, 0, nil
+	}
+	sort.Slice(order, func(a, b int) bool { return limits[order[a]].Key < limits[order[b]].Key })
+
+	var denied *RateLimit
+	var retryAfter time.Duration
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		counters := make([]models.RateLimitCounter, len(limits))
+
+		for _, i := range order {
+			l := limits[i]
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.RateLimitCounter{Key: l.Key, WindowStart: now}).Error; err != nil {
+				return err
+			}
+
+			counter := &counters[i]
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(counter, "`key` = ?", l.Key).Error; err != nil {
+				return err
+			}
+
+			if now.Sub(counter.WindowStart) >= l.Window {
+				counter.WindowStart = now
+				counter.Count = 0
+			}
+		}
+
+		// Report the first exhausted limit in the caller's order
+		for i, l := range limits {
+			if l.Limit > 0 && counters[i].Count >= l.Limit {
+				denied = &limits[i]
+				retryAfter = counters[i].WindowStart.Add(l.Window).Sub(now)
+				return nil
+			}
+		}
+
+		for _, i := range order {
+			counters[i].Count++
+			if err := tx.Save(&counters[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("rate limiter error: %w", err)
+	}
+
+	return denied, retryAfter, nil
+}
+
+// Release decrements the counter of every limit, unless a new window already reset it
+func (d *DBRateLimiter) Release(limits []RateLimit) error {
+	for _, l := range limits {
+		if l.Limit <= 0 {
+			continue
+		}
+		if err := models.DB.Model(&models.RateLimitCounter{}).
+			Where("`key` = ? AND count > 0", l.Key).
+			Update("count", gorm.Expr("count - 1")).Error; err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+	}
+	return nil
+}
+
+// PurgeRateLimitCounters removes database counters that have not been touched since before
+func PurgeRateLimitCounters(before time.Time) (int64, error) {
+	result := models.DB.Where("updated_at < ?", before).Delete(&models.RateLimitCounter{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllowAllConsumesNothingOnDenial(t *testing.T) {
+	setupTestDB(t)
+
+	for name, limiter := range map[string]RateLimiter{
+		"memory": NewMemoryRateLimiter(),
+		"db":     NewDBRateLimiter(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ip := RateLimit{Key: name + ":ip", Limit: 3, Window: time.Hour}
+			phone := RateLimit{Key: name + ":phone", Limit: 1, Window: time.Hour}
+
+			if denied, _, err := limiter.AllowAll([]RateLimit{ip, phone}); err != nil || denied != nil {
+				t.Fatalf("first request: denied %v, err %v", denied, err)
+			}
+
+			// The phone limit is exhausted, the IP limit must keep its remaining tokens
+			for i := 0; i < 5; i++ {
+				denied, retryAfter, err := limiter.AllowAll([]RateLimit{ip, phone})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if denied == nil || denied.Key != phone.Key || retryAfter <= 0 {
+					t.Fatalf("request %d: denied %v (retry after %v), want the phone limit", i+2, denied, retryAfter)
+				}
+			}
+
+			for i := 0; i < 2; i++ {
+				if allowed, _, err := limiter.Allow(ip.Key, ip.Limit, ip.Window); err != nil || !allowed {
+					t.Fatalf("IP token %d was consumed by denied requests (err %v)", i+2, err)
+				}
+			}
+			if allowed, _, _ := limiter.Allow(ip.Key, ip.Limit, ip.Window); allowed {
+				t.Fatal("IP limit allowed more than its limit")
+			}
+
+			// Released tokens can be used again
+			if err := limiter.Release([]RateLimit{ip}); err != nil {
+				t.Fatal(err)
+			}
+			if allowed, _, err := limiter.Allow(ip.Key, ip.Limit, ip.Window); err != nil || !allowed {
+				t.Fatalf("released token not available (err %v)", err)
+			}
+		})
+	}
+}
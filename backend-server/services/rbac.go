@@ -0,0 +1,272 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Permissions checked by administrative routes
+const (
+	PermUsersRead       = "users:read"
+	PermUsersWrite      = "users:write"
+	PermCreditsAdjust   = "credits:adjust"
+	PermOrdersRead      = "orders:read"
+	PermTasksRead       = "tasks:read"
+	PermTasksManage     = "tasks:manage"
+	PermPlansManage     = "plans:manage"
+	PermRolesManage     = "roles:manage"
+	PermAuditRead       = "audit:read"
+	PermInferenceManage = "inference:manage"
+)
+
+// AllPermissions lists the permissions that can be granted to a user
+var AllPermissions = []string{
+	PermUsersRead,
+	PermUsersWrite,
+	PermCreditsAdjust,
+	PermOrdersRead,
+	PermTasksRead,
+	PermTasksManage,
+	PermPlansManage,
+	PermRolesManage,
+	PermAuditRead,
+	PermInferenceManage,
+}
+
+// rolePermissions are the permissions every user of a role has
+// Additional permissions can be granted per user and are stored in the database
+var rolePermissions = map[models.UserRole][]string{
+	models.UserRoleUser:    {},
+	models.UserRoleAdmin:   AllPermissions,
+	models.UserRoleSupport: {PermUsersRead, PermTasksRead, PermTasksManage},
+	models.UserRoleBilling: {PermUsersRead, PermOrdersRead, PermCreditsAdjust, PermPlansManage},
+}
+
+var (
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrLastAdmin         = errors.New("cannot remove the last admin")
+)
+
+// InitRBAC promotes the users listed in ADMIN_USERS and imports the legacy phone whitelist
+func InitRBAC() error {
+	if err := promoteConfiguredAdmins(); err != nil {
+		return err
+	}
+	return importPhoneWhitelist()
+}
+
+// promoteConfiguredAdmins gives the admin role to the phones and emails in ADMIN_USERS
+// Users that have not registered yet are promoted when they sign up
+func promoteConfiguredAdmins() error {
+	phones, emails := configuredAdmins()
+	if len(phones) == 0 && len(emails) == 0 {
+		return nil
+	}
+
+	query := models.DB.Model(&models.User{}).Where("role <> ?", models.UserRoleAdmin)
+	switch {
+	case len(phones) > 0 && len(emails) > 0:
+		query = query.Where("phone IN ? OR email IN ?", phones, emails)
+	case len(phones) > 0:
+		query = query.Where("phone IN ?", phones)
+	default:
+		query = query.Where("email IN ?", emails)
+	}
+
+	result := query.Update("role", models.UserRoleAdmin)
+	if result.Error != nil {
+		return fmt.Errorf("failed to promote admin users: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Promoted %d users from ADMIN_USERS to admin", result.RowsAffected)
+	}
+	return nil
+}
+
+// configuredAdmins splits ADMIN_USERS into normalized phone numbers and email addresses
+func configuredAdmins() (phones, emails []string) {
+	for _, entry := range config.Cfg.AdminUsers {
+		if strings.Contains(entry, "@") {
+			if email, err := NormalizeEmail(entry); err == nil {
+				emails = append(emails, email)
+			}
+			continue
+		}
+		if phone, err := NormalizePhone("", entry); err == nil {
+			phones = append(phones, phone)
+		}
+	}
+	return phones, emails
+}
+
+// isConfiguredAdmin checks if a new user is listed in ADMIN_USERS
+func isConfiguredAdmin(user *models.User) bool {
+	phones, emails := configuredAdmins()
+	for _, p := range phones {
+		if p == user.PhoneNumber() {
+			return true
+		}
+	}
+	for _, e := range emails {
+		if e == user.EmailAddress() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidRole checks if a role exists
+func IsValidRole(role models.UserRole) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// IsValidPermission checks if a permission can be granted
+func IsValidPermission(perm string) bool {
+	for _, p := range AllPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaffRole checks if a role grants access to the admin API
+func IsStaffRole(role models.UserRole) bool {
+	return IsValidRole(role) && role != models.UserRoleUser
+}
+
+// UserAccess describes the role and effective permissions of a user
+type UserAccess struct {
+	Role        models.UserRole `json:"role"`
+	Permissions []string        `json:"permissions"`
+}
+
+// Has checks if the access includes a permission
+func (a *UserAccess) Has(perm string) bool {
+	for _, p := range a.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserAccess loads the role of a user and merges role defaults with per-user grants
+func GetUserAccess(userID string) (*UserAccess, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []string
+	if err := models.DB.Model(&models.UserPermission{}).
+		Where("user_id = ?", userID).
+		Pluck("permission", &grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	set := make(map[string]bool)
+	for _, p := range rolePermissions[user.Role] {
+		set[p] = true
+	}
+	for _, p := range grants {
+		set[p] = true
+	}
+
+	access := &UserAccess{Role: user.Role, Permissions: make([]string, 0, len(set))}
+	for p := range set {
+		access.Permissions = append(access.Permissions, p)
+	}
+	sort.Strings(access.Permissions)
+	return access, nil
+}
+
+// ListUserPermissionGrants lists the permissions granted to a user individually
+func ListUserPermissionGrants(userID string) ([]models.UserPermission, error) {
+	var grants []models.UserPermission
+	if err := models.DB.Where("user_id = ?", userID).Order("permission ASC").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	return grants, nil
+}
+
+// SetUserRole changes the role of a user
+// The last remaining admin cannot be demoted
+func SetUserRole(userID string, role models.UserRole) (*models.User, error) {
+	if !IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	var user models.User
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return fmt.Errorf("user not found")
+		}
+		if user.Role == role {
+			return nil
+		}
+
+		if user.Role == models.UserRoleAdmin {
+			var admins int64
+			if err := tx.Model(&models.User{}).Where("role = ?", models.UserRoleAdmin).Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		user.Role = role
+		return tx.Model(&user).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GrantPermission grants a permission to a user; granting an existing permission is a no-op
+func GrantPermission(userID, perm, grantedBy string) error {
+	if !IsValidPermission(perm) {
+		return ErrInvalidPermission
+	}
+	if _, err := GetUserByID(userID); err != nil {
+		return err
+	}
+
+	grant := models.UserPermission{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Permission: perm,
+		GrantedBy:  grantedBy,
+	}
+	if err := models.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error; err != nil {
+		return fmt.Errorf("failed to grant permission: %w", err)
+	}
+	return nil
+}
+
+// RevokePermission removes a permission granted to a user individually
+// Permissions that come with the user's role are not affected
+func RevokePermission(userID, perm string) error {
+	if !IsValidPermission(perm) {
+		return ErrInvalidPermission
+	}
+	if err := models.DB.Where("user_id = ? AND permission = ?", userID, perm).
+		Delete(&models.UserPermission{}).Error; err != nil {
+		return fmt.Errorf("failed to revoke permission: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"gorm.io/gorm"
+)
+
+// maxScheduleAhead is how far in the future a task can be scheduled
+const maxScheduleAhead = 30 * 24 * time.Hour
+
+var ErrInvalidRunAt = errors.New("run_at must be within 30 days from now")
+
+// queueUser is a user with pending tasks as seen by the scheduler
+type queueUser struct {
+	UserID             string
+	TopPriority        int // Highest priority among the user's pending tasks
+	QueueWeight        int
+	MaxProcessingTasks int
+}
+
+// queueActivity is the recent usage of the worker by a user
+type queueActivity struct {
+	UserID     string
+	Processing int64 // Tasks being processed right now
+	Recent     int64 // Tasks started within the fair-share window, including those processing
+}
+
+// planQueueWeight returns the fair-share weight of a plan, users without a plan have weight 1
+func planQueueWeight(plan *models.Plan) int {
+	if plan == nil || plan.QueueWeight < 1 {
+		return 1
+	}
+	return plan.QueueWeight
+}
+
+// maxProcessingTasks returns how many tasks of a user may be processed at the same time, 0 means unlimited
+func maxProcessingTasks(planLimit int) int {
+	if planLimit > 0 {
+		return planLimit
+	}
+	return config.Cfg.UserMaxProcessingTasks
+}
+
+// DefaultTaskPriority returns the priority of new tasks created by a user, taken from their plan
+func DefaultTaskPriority(userID string) int {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return 0
+	}
+	if plan := getUserPlan(user); plan != nil {
+		return plan.TaskPriority
+	}
+	return 0
+}
+
+// CheckRunAt validates the requested execution time of a task
+func CheckRunAt(runAt *time.Time) error {
+	if runAt != nil && runAt.After(time.Now().Add(maxScheduleAhead)) {
+		return ErrInvalidRunAt
+	}
+	return nil
+}
+
+// InitialTaskStatus returns the status of a task to run at runAt: scheduled if it is in the future, pending otherwise
+func InitialTaskStatus(runAt *time.Time) models.TaskStatus {
+	if runAt != nil && runAt.After(time.Now()) {
+		return models.TaskStatusScheduled
+	}
+	return models.TaskStatusPending
+}
+
+// promoteScheduledTasks moves scheduled tasks whose run_at has passed into the queue
+func promoteScheduledTasks() {
+	result := models.DB.Model(&models.Task{}).
+		Where("status = ? AND run_at <= ?", models.TaskStatusScheduled, time.Now()).
+		Update("status", models.TaskStatusPending)
+	if result.Error != nil {
+		log.Printf("Failed to queue scheduled tasks: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Queued %d scheduled tasks", result.RowsAffected)
+	}
+}
+
+// RescheduleTask changes when a user's scheduled or pending task runs
+// A nil or past runAt puts the task into the queue right away
+func RescheduleTask(userID, taskID string, runAt *time.Time) (*models.Task, error) {
+	if err := CheckRunAt(runAt); err != nil {
+		return nil, err
+	}
+
+	var task models.Task
+	if err := models.DB.First(&task, "id = ? AND user_id = ?", taskID, userID).Error; err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	if err := updateTaskIn(&task,
+		[]models.TaskStatus{models.TaskStatusScheduled, models.TaskStatusPending},
+		map[string]interface{}{
+			"status": InitialTaskStatus(runAt),
+			"run_at": runAt,
+		}); err != nil {
+		return nil, err
+	}
+
+	return GetTaskByID(taskID)
+}
+
+// nextTask picks the pending task the worker should process next, nil if there is none
+// The highest task priority always goes first. Among users with pending tasks of that priority,
+// the one with the least recent usage relative to their plan's queue weight is served,
+// so a user submitting many tasks can not starve others. Users already processing their
+// maximum number of tasks are skipped.
+func nextTask() (*models.Task, error) {
+	var users []queueUser
+	if err := models.DB.Model(&models.Task{}).
+		Select("tasks.user_id, MAX(tasks.priority) AS top_priority, MIN(tasks.created_at) AS oldest_at, "+
+			"COALESCE(MAX(plans.queue_weight), 1) AS queue_weight, "+
+			"COALESCE(MAX(plans.max_processing_tasks), 0) AS max_processing_tasks").
+		Joins("JOIN users ON users.id = tasks.user_id").
+		Joins("LEFT JOIN plans ON plans.id = users.plan_id").
+		Where("tasks.status = ?", models.TaskStatusPending).
+		Group("tasks.user_id").
+		Order("oldest_at ASC"). // Ties go to the user waiting longest
+		Scan(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to load queued users: %w", err)
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	userIDs := make([]string, len(users))
+	for i, u := range users {
+		userIDs[i] = u.UserID
+	}
+	activity, err := loadQueueActivity(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *queueUser
+	var bestScore float64
+	for i := range users {
+		u := &users[i]
+		act := activity[u.UserID]
+		if limit := maxProcessingTasks(u.MaxProcessingTasks); limit > 0 && act.Processing >= int64(limit) {
+			continue
+		}
+
+		score := float64(act.Recent) / float64(max(u.QueueWeight, 1))
+		if best == nil || u.TopPriority > best.TopPriority ||
+			(u.TopPriority == best.TopPriority && score < bestScore) {
+			best, bestScore = u, score
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	var task models.Task
+	err = models.DB.Where("user_id = ? AND status = ?", best.UserID, models.TaskStatusPending).
+		Order("priority DESC, created_at ASC").
+		First(&task).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load next task: %w", err)
+	}
+	return &task, nil
+}
+
+// loadQueueActivity returns the recent usage of the given users, keyed by user ID
+func loadQueueActivity(userIDs []string) (map[string]queueActivity, error) {
+	since := time.Now().Add(-time.Duration(config.Cfg.SchedulerFairWindowMinutes) * time.Minute)
+
+	var rows []queueActivity
+	if err := models.DB.Model(&models.Task{}).
+		Select("user_id, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS processing, COUNT(*) AS recent",
+			models.TaskStatusProcessing).
+		Where("user_id IN ? AND (status = ? OR started_at >= ?)", userIDs, models.TaskStatusProcessing, since).
+		Group("user_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load queue activity: %w", err)
+	}
+
+	activity := make(map[string]queueActivity, len(rows))
+	for _, r := range rows {
+		activity[r.UserID] = r
+	}
+	return activity, nil
+}
+
+// pendingGroup counts the pending tasks of one user and priority
+type pendingGroup struct {
+	UserID      string
+	Priority    int
+	Count       int64
+	QueueWeight int
+}
+
+// pendingTask is the part of a pending task its owner's queue order depends on
+type pendingTask struct {
+	UserID    string
+	Priority  int
+	CreatedAt time.Time
+}
+
+// queuePositions computes queue positions from counts loaded once, instead of querying per task
+type queuePositions struct {
+	groups []pendingGroup
+	own    map[string][]pendingTask // Pending tasks of the users whose tasks are positioned
+}
+
+// loadQueuePositions loads the pending task counts of all users, and the pending tasks of the
+// given users, so that the position of any pending task of theirs can be computed
+func loadQueuePositions(userIDs []string) (*queuePositions, error) {
+	p := &queuePositions{own: make(map[string][]pendingTask)}
+	if err := models.DB.Model(&models.Task{}).
+		Select("tasks.user_id, tasks.priority, COUNT(*) AS count, COALESCE(MAX(plans.queue_weight), 1) AS queue_weight").
+		Joins("JOIN users ON users.id = tasks.user_id").
+		Joins("LEFT JOIN plans ON plans.id = users.plan_id").
+		Where("tasks.status = ?", models.TaskStatusPending).
+		Group("tasks.user_id, tasks.priority").
+		Scan(&p.groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to count pending tasks: %w", err)
+	}
+
+	if len(userIDs) > 0 {
+		var tasks []pendingTask
+		if err := models.DB.Model(&models.Task{}).
+			Select("user_id, priority, created_at").
+			Where("status = ? AND user_id IN ?", models.TaskStatusPending, userIDs).
+			Scan(&tasks).Error; err != nil {
+			return nil, fmt.Errorf("failed to load pending tasks: %w", err)
+		}
+		for _, t := range tasks {
+			p.own[t.UserID] = append(p.own[t.UserID], t)
+		}
+	}
+	return p, nil
+}
+
+// position estimates the position of a pending task in the queue, 1 being the next task to run
+// Tasks of higher priority run first; other users with tasks of the same priority are assumed to
+// take turns with the task's owner in proportion to their queue weights. The task's owner must
+// have been passed to loadQueuePositions.
+func (p *queuePositions) position(task *models.Task) int64 {
+	var ahead int64
+	ownWeight := 1
+	for _, g := range p.groups {
+		if g.Priority > task.Priority {
+			ahead += g.Count
+		}
+		if g.UserID == task.UserID && g.Priority == task.Priority {
+			ownWeight = max(g.QueueWeight, 1)
+		}
+	}
+
+	var ownAhead int64
+	for _, t := range p.own[task.UserID] {
+		if t.Priority == task.Priority && t.CreatedAt.Before(task.CreatedAt) {
+			ownAhead++
+		}
+	}
+
+	turns := float64(ownAhead + 1)
+	for _, g := range p.groups {
+		if g.Priority != task.Priority || g.UserID == task.UserID {
+			continue
+		}
+		share := int64(math.Ceil(turns * float64(max(g.QueueWeight, 1)) / float64(ownWeight)))
+		ahead += min(g.Count, share)
+	}
+
+	return ahead + ownAhead + 1
+}
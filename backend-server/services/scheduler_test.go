@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// createQueuedTask creates a pending task with the given priority and creation time
+func createQueuedTask(t *testing.T, userID string, priority int, createdAt time.Time) *models.Task {
+	t.Helper()
+
+	task := &models.Task{
+		ID:                   uuid.New().String(),
+		UserID:               userID,
+		Status:               models.TaskStatusPending,
+		Priority:             priority,
+		Text:                 "hello",
+		ReferenceAudioFileID: uuid.New().String(),
+		CreatedAt:            createdAt,
+	}
+	if err := models.DB.Create(task).Error; err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return task
+}
+
+func TestQueuePositions(t *testing.T) {
+	setupTestDB(t)
+	plan := &models.Plan{ID: uuid.New().String(), Name: "pro", QueueWeight: 2}
+	if err := models.DB.Create(plan).Error; err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	alice := createTestUser(t, 0)
+	bob := createTestUser(t, 0)
+	if err := models.DB.Model(bob).Update("plan_id", plan.ID).Error; err != nil {
+		t.Fatalf("assign plan: %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	urgent := createQueuedTask(t, bob.ID, 10, start.Add(5*time.Minute))
+	var aliceTasks []*models.Task
+	for i := 0; i < 3; i++ {
+		aliceTasks = append(aliceTasks, createQueuedTask(t, alice.ID, 0, start.Add(time.Duration(i)*time.Minute)))
+	}
+	for i := 0; i < 5; i++ {
+		createQueuedTask(t, bob.ID, 0, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	positions, err := loadQueuePositions([]string{alice.ID, bob.ID})
+	if err != nil {
+		t.Fatalf("load positions: %v", err)
+	}
+
+	if got := positions.position(urgent); got != 1 {
+		t.Errorf("urgent task position = %d, want 1", got)
+	}
+	// Bob's plan has twice the weight: he gets two turns for each of Alice's
+	for i, want := range []int64{4, 7, 9} {
+		if got := positions.position(aliceTasks[i]); got != want {
+			t.Errorf("alice task %d position = %d, want %d", i+1, got, want)
+		}
+	}
+}
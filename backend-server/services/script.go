@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidScript       = errors.New("invalid script")
+	ErrScriptCreditsNeeded = errors.New("insufficient credits")
+)
+
+// Script limits
+const (
+	maxScriptSpeakers     = 10
+	maxScriptTextLength   = 5000  // Characters over all lines, the same as a single task
+	maxScriptPauseAfterMs = 10000 // Longest silence after a line
+)
+
+// ScriptLineInput is one line of a new script task
+type ScriptLineInput struct {
+	Speaker      string
+	Text         string
+	Emotion      EmotionSettings
+	PauseAfterMs int
+}
+
+// ScriptTaskInput describes a new script task
+// Speakers maps speaker names to the reference audio file of their voice
+type ScriptTaskInput struct {
+	Speakers map[string]string
+	Lines    []ScriptLineInput
+	RunAt    *time.Time
+	Advanced *AdvancedParams
+}
+
+func scriptError(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidScript}, args...)...)
+}
+
+// CreateScriptTask validates a script and queues it as one task whose lines are synthesized
+// with the voice of their speaker and combined into a single audio file
+// The task costs one task's credits per line
+func CreateScriptTask(userID string, input ScriptTaskInput) (*models.Task, error) {
+	if len(input.Speakers) == 0 || len(input.Speakers) > maxScriptSpeakers {
+		return nil, scriptError("a script needs between 1 and %d speakers", maxScriptSpeakers)
+	}
+	if len(input.Lines) == 0 {
+		return nil, scriptError("a script needs at least one line")
+	}
+
+	for name, fileID := range input.Speakers {
+		if strings.TrimSpace(name) == "" || utf8.RuneCountInString(name) > 64 {
+			return nil, scriptError("speaker names must have 1 to 64 characters")
+		}
+		var refFile models.File
+		if err := models.DB.First(&refFile, "id = ? AND user_id = ?", fileID, userID).Error; err != nil {
+			return nil, scriptError("reference audio file not found for speaker %q", name)
+		}
+	}
+
+	textLength := 0
+	for i := range input.Lines {
+		line := &input.Lines[i]
+		line.Text = strings.TrimSpace(line.Text)
+		if line.Text == "" {
+			return nil, scriptError("lines[%d]: text is required", i)
+		}
+		if _, ok := input.Speakers[line.Speaker]; !ok {
+			return nil, scriptError("lines[%d]: unknown speaker %q", i, line.Speaker)
+		}
+		if line.PauseAfterMs < 0 || line.PauseAfterMs > maxScriptPauseAfterMs {
+			return nil, scriptError("lines[%d]: pause_after_ms must be between 0 and %d", i, maxScriptPauseAfterMs)
+		}
+		if line.Emotion.Mode == "" {
+			line.Emotion.Mode = models.EmotionModeSameAsReference
+		}
+		if err := CheckEmotion(userID, &line.Emotion); err != nil {
+			return nil, scriptError("lines[%d]: %w", i, err)
+		}
+		textLength += utf8.RuneCountInString(line.Text)
+	}
+	if textLength > maxScriptTextLength {
+		return nil, scriptError("the lines have %d characters, at most %d are allowed", textLength, maxScriptTextLength)
+	}
+
+	hasCredits, err := CheckCreditsFor(userID, ScriptCredits(len(input.Lines)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check credits: %w", err)
+	}
+	if !hasCredits {
+		return nil, ErrScriptCreditsNeeded
+	}
+
+	task := &models.Task{
+		ID:                   uuid.New().String(),
+		UserID:               userID,
+		Status:               InitialTaskStatus(input.RunAt),
+		Type:                 models.TaskTypeScript,
+		Priority:             DefaultTaskPriority(userID),
+		RunAt:                input.RunAt,
+		ReferenceAudioFileID: input.Speakers[input.Lines[0].Speaker],
+		EmotionMode:          models.EmotionModeSameAsReference,
+		AdvancedParams:       EncodeAdvancedParams(input.Advanced),
+	}
+
+	text := make([]string, len(input.Lines))
+	lines := make([]models.TaskLine, len(input.Lines))
+	for i, line := range input.Lines {
+		text[i] = line.Speaker + ": " + line.Text
+		lines[i] = models.TaskLine{
+			ID:                   uuid.New().String(),
+			TaskID:               task.ID,
+			Position:             i,
+			Speaker:              line.Speaker,
+			Text:                 line.Text,
+			ReferenceAudioFileID: input.Speakers[line.Speaker],
+			EmotionMode:          line.Emotion.Mode,
+			EmotionPromptFileID:  line.Emotion.PromptFileID,
+			EmotionVector:        encodeEmotionVector(line.Emotion.Vector),
+			EmotionAlpha:         line.Emotion.Alpha,
+			EmotionText:          line.Emotion.Text,
+			PauseAfterMs:         line.PauseAfterMs,
+		}
+	}
+	task.Text = strings.Join(text, "\n")
+
+	// The credits are deducted in the transaction that creates the task, so a script is never
+	// queued without being paid for, even when submissions race past the check above
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+		return deductScriptCreditsTx(tx, userID, task.ID, len(lines))
+	})
+	if errors.Is(err, ErrScriptCreditsNeeded) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return task, nil
+}
+
+// GetTaskLines returns the lines of a script task in order
+func GetTaskLines(taskID string) ([]models.TaskLine, error) {
+	var lines []models.TaskLine
+	if err := models.DB.Where("task_id = ?", taskID).Order("position").Find(&lines).Error; err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"backend-server/models"
+)
+
+func TestCreateScriptTaskChargesInTransaction(t *testing.T) {
+	_, _, storage := setupTestWorker(t)
+	user := createTestUser(t, ScriptCredits(2))
+	voice := createReferenceFile(t, storage, user.ID)
+	input := ScriptTaskInput{
+		Speakers: map[string]string{"Alice": voice.ID},
+		Lines:    []ScriptLineInput{{Speaker: "Alice", Text: "one"}, {Speaker: "Alice", Text: "two"}},
+	}
+
+	// Every submission passes the credit check, only one can be paid for
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = CreateScriptTask(user.ID, input)
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrScriptCreditsNeeded):
+			t.Errorf("err = %v, want insufficient credits", err)
+		}
+	}
+
+	var tasks int64
+	models.DB.Model(&models.Task{}).Where("user_id = ?", user.ID).Count(&tasks)
+	credits, _ := GetUserCredits(user.ID)
+	if created != 1 || tasks != 1 || credits != 0 {
+		t.Fatalf("created %d scripts (%d tasks), %d credits left, want exactly one paid script", created, tasks, credits)
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+)
+
+// ClientInfo describes the device a session was created from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// TokenPair holds an access token and the refresh token used to renew it
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresIn        int       `json:"expires_in"` // Access token lifetime in seconds
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"`
+}
+
+// randomToken generates a random URL-safe token from n random bytes
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the hex SHA-256 digest of a high-entropy token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// CreateSession starts a new session for the user and issues a token pair
+func CreateSession(user *models.User, client ClientInfo) (*TokenPair, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               uuid.New().String(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        truncate(client.UserAgent, 255),
+		IP:               truncate(client.IP, 64),
+		LastUsedAt:       &now,
+		ExpiresAt:        now.AddDate(0, 0, config.Cfg.AuthRefreshTokenDays),
+	}
+	if err := models.DB.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	RecordAudit(auditClient(AuditEntry{
+		ActorID:    user.ID,
+		Action:     AuditTokenIssued,
+		TargetType: AuditTargetSession,
+		TargetID:   session.ID,
+	}, client))
+
+	return issueTokenPair(user, session, refreshToken)
+}
+
+func issueTokenPair(user *models.User, session *models.Session, refreshToken string) (*TokenPair, error) {
+	accessToken, err := GenerateUserToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        config.Cfg.AuthAccessTokenMinutes * 60,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+	}, nil
+}
+
+// RefreshSession exchanges a refresh token for a new token pair, rotating the refresh token
+// Presenting an already rotated refresh token revokes the session, since it indicates theft
+func RefreshSession(refreshToken string, client ClientInfo) (*models.User, *TokenPair, error) {
+	tokenHash := hashToken(refreshToken)
+
+	var session models.Session
+	if err := models.DB.First(&session, "refresh_token_hash = ?", tokenHash).Error; err != nil {
+		// Check for reuse of a rotated token
+		var reused models.Session
+		if models.DB.First(&reused, "previous_token_hash = ?", tokenHash).Error == nil && reused.RevokedAt == nil {
+			log.Printf("Refresh token reuse detected for session %s (user %s), revoking", reused.ID, reused.UserID)
+			revokeSession(&reused)
+			RecordAudit(auditClient(AuditEntry{
+				ActorID:    reused.UserID,
+				Action:     AuditRefreshTokenReuse,
+				TargetType: AuditTargetSession,
+				TargetID:   reused.ID,
+			}, client))
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if !session.IsActive() {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, "id = ?", session.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if user.Status == models.UserStatusDisabled {
+		return nil, nil, errors.New("user account is disabled")
+	}
+
+	newToken, err := randomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Rotate only if the token was not rotated concurrently
+	now := time.Now()
+	result := models.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, tokenHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  hashToken(newToken),
+			"previous_token_hash": tokenHash,
+			"last_used_at":        now,
+			"ip":                  truncate(client.IP, 64),
+			"user_agent":          truncate(client.UserAgent, 255),
+		})
+	if result.Error != nil {
+		return nil, nil, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	tokens, err := issueTokenPair(&user, &session, newToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	RecordAudit(auditClient(AuditEntry{
+		ActorID:    user.ID,
+		Action:     AuditTokenRefreshed,
+		TargetType: AuditTargetSession,
+		TargetID:   session.ID,
+	}, client))
+
+	return &user, tokens, nil
+}
+
+// checkSessionState verifies that the session behind an access token is still valid
+func checkSessionState(claims *UserClaims) error {
+	if claims.SessionID == "" {
+		return errors.New("token is not bound to a session")
+	}
+
+	var state struct {
+		RevokedAt    *time.Time
+		ExpiresAt    time.Time
+		Status       models.UserStatus
+		TokenVersion int
+	}
+	result := models.DB.Table("sessions").
+		Select("sessions.revoked_at, sessions.expires_at, users.status, users.token_version").
+		Joins("JOIN users ON users.id = sessions.user_id AND users.deleted_at IS NULL").
+		Where("sessions.id = ? AND sessions.user_id = ?", claims.SessionID, claims.UserID).
+		Scan(&state)
+	if result.Error != nil {
+		return fmt.Errorf("failed to check session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	if state.RevokedAt != nil || time.Now().After(state.ExpiresAt) {
+		return errors.New("session has been revoked")
+	}
+	if state.Status == models.UserStatusDisabled {
+		return errors.New("user account is disabled")
+	}
+	if state.TokenVersion != claims.TokenVersion {
+		return errors.New("token has been revoked")
+	}
+
+	return nil
+}
+
+func revokeSession(session *models.Session) error {
+	now := time.Now()
+	if err := models.DB.Model(session).Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	session.RevokedAt = &now
+	return nil
+}
+
+// RevokeSession revokes one of the user's sessions
+func RevokeSession(userID, sessionID string) error {
+	var session models.Session
+	if err := models.DB.First(&session, "id = ? AND user_id = ?", sessionID, userID).Error; err != nil {
+		return ErrSessionNotFound
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	return revokeSession(&session)
+}
+
+// RevokeAllSessions revokes every session of the user and invalidates all issued access tokens
+func RevokeAllSessions(userID string) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error
+	})
+}
+
+// ListSessions lists the user's active sessions, most recently used first
+func ListSessions(userID string) ([]models.Session, error) {
+	var sessions []models.Session
+	if err := models.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrShareLinkNotFound      = errors.New("share link not found")
+	ErrShareLinkExpired       = errors.New("share link has expired")
+	ErrShareLinkRevoked       = errors.New("share link has been revoked")
+	ErrShareDownloadLimit     = errors.New("share link download limit reached")
+	ErrSharePasswordRequired  = errors.New("password required")
+	ErrSharePasswordIncorrect = errors.New("incorrect password")
+)
+
+// ShareAccessTTL is how long a password protected link stays unlocked in a browser
+const ShareAccessTTL = time.Hour
+
+// SharePlayTTL is how long the audio URL of a share link's player page stays valid
+const SharePlayTTL = 15 * time.Minute
+
+// MinSharePasswordLength is the shortest password a share link can be protected with
+const MinSharePasswordLength = 8
+
+// ShareCredentials unlock a password protected share link
+type ShareCredentials struct {
+	Password string // The link password, attempts are limited per link and per client IP
+	Access   string // A token from ShareAccessToken, proving the password was entered before
+	IP       string
+}
+
+// CreateShareLinkParams holds the options for a new share link
+type CreateShareLinkParams struct {
+	FileID       string
+	TaskID       string // When set, the task's result file is shared
+	Password     string
+	ExpiresIn    time.Duration // 0 means never expires
+	MaxDownloads int           // 0 means unlimited
+}
+
+// CreateShareLink creates a share link for a file or a completed task owned by the user
+func CreateShareLink(userID string, params CreateShareLinkParams) (*models.ShareLink, error) {
+	fileID := params.FileID
+
+	if params.TaskID != "" {
+		var task models.Task
+		if err := models.DB.First(&task, "id = ? AND user_id = ?", params.TaskID, userID).Error; err != nil {
+			return nil, errors.New("task not found")
+		}
+		if task.Status != models.TaskStatusCompleted || task.ResultAudioFileID == "" {
+			return nil, errors.New("task has no result audio")
+		}
+		fileID = task.ResultAudioFileID
+	}
+
+	if params.Password != "" && utf8.RuneCountInString(params.Password) < MinSharePasswordLength {
+		return nil, fmt.Errorf("password must have at least %d characters", MinSharePasswordLength)
+	}
+
+	if fileID == "" {
+		return nil, errors.New("file_id or task_id is required")
+	}
+
+	var file models.File
+	if err := models.DB.First(&file, "id = ? AND user_id = ?", fileID, userID).Error; err != nil {
+		return nil, errors.New("file not found")
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &models.ShareLink{
+		ID:           uuid.New().String(),
+		Token:        token,
+		UserID:       userID,
+		FileID:       file.ID,
+		TaskID:       params.TaskID,
+		MaxDownloads: params.MaxDownloads,
+	}
+
+	if params.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(params.ExpiresIn)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if params.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	if err := models.DB.Create(link).Error; err != nil {
+		return nil, fmt.Errorf("failed to create share link: %w", err)
+	}
+
+	return link, nil
+}
+
+// ResolveShareLink looks up a share link by token and checks that it can be accessed
+// Returns the link together with the shared file, also when the password is missing or wrong
+func ResolveShareLink(token string, creds ShareCredentials) (*models.ShareLink, *models.File, error) {
+	var link models.ShareLink
+	if err := models.DB.First(&link, "token = ?", token).Error; err != nil {
+		return nil, nil, ErrShareLinkNotFound
+	}
+
+	if link.IsRevoked() {
+		return nil, nil, ErrShareLinkRevoked
+	}
+	if link.IsExpired() {
+		return nil, nil, ErrShareLinkExpired
+	}
+
+	var file models.File
+	if err := models.DB.First(&file, "id = ?", link.FileID).Error; err != nil {
+		return nil, nil, ErrShareLinkNotFound
+	}
+
+	if link.HasPassword() && !validShareAccess(&link, creds.Access) {
+		if creds.Password == "" {
+			return &link, &file, ErrSharePasswordRequired
+		}
+		if err := checkSharePasswordRateLimit(link.ID, creds.IP); err != nil {
+			return &link, &file, err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(creds.Password)) != nil {
+			return &link, &file, ErrSharePasswordIncorrect
+		}
+	}
+
+	return &link, &file, nil
+}
+
+// checkSharePasswordRateLimit limits password attempts per share link and per client IP
+func checkSharePasswordRateLimit(linkID, ip string) error {
+	cfg := config.Cfg
+
+	limits := []RateLimit{{Key: "share:link:" + linkID, Limit: cfg.ShareLimitPerLinkHour, Window: time.Hour}}
+	if ip != "" {
+		limits = append([]RateLimit{{Key: "share:ip:" + ip, Limit: cfg.ShareLimitPerIPHour, Window: time.Hour}}, limits...)
+	}
+
+	denied, retryAfter, err := rateLimiter.AllowAll(limits)
+	if err != nil {
+		return err
+	}
+	if denied != nil {
+		log.Printf("[SECURITY] Share password rate limit hit for %s", denied.Key)
+		authErr := newAuthError(AuthErrRateLimited,
+			fmt.Sprintf("too many password attempts, please try again in %d seconds", int(retryAfter.Seconds())+1))
+		authErr.RetryAfter = retryAfter
+		return authErr
+	}
+	return nil
+}
+
+// ShareAccessToken returns a token proving that the password of a link was entered,
+// valid until expiresAt. It is signed with AUTH_JWT_SECRET and the link's password hash,
+// so it only works for this link and can not be forged from the link token.
+func ShareAccessToken(link *models.ShareLink, expiresAt time.Time) string {
+	return shareToken(link, "access", expiresAt)
+}
+
+// validShareAccess checks a token from ShareAccessToken
+func validShareAccess(link *models.ShareLink, access string) bool {
+	return validShareToken(link, "access", access)
+}
+
+// SharePlayToken returns a token for the audio URL of the player page, valid until expiresAt
+// Audio fetched with it is played on the page and does not count as a download.
+func SharePlayToken(link *models.ShareLink, expiresAt time.Time) string {
+	return shareToken(link, "play", expiresAt)
+}
+
+// ValidSharePlayToken checks a token from SharePlayToken
+func ValidSharePlayToken(link *models.ShareLink, play string) bool {
+	return validShareToken(link, "play", play)
+}
+
+// shareToken signs a link, what the token is for and its expiry, so tokens of one purpose
+// can not be used for another
+func shareToken(link *models.ShareLink, purpose string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expires + "." + shareSignature(link, purpose, expires)
+}
+
+func validShareToken(link *models.ShareLink, purpose, token string) bool {
+	expires, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() >= unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(shareSignature(link, purpose, expires)))
+}
+
+func shareSignature(link *models.ShareLink, purpose, expires string) string {
+	mac := hmac.New(sha256.New, []byte(config.Cfg.AuthJWTSecret))
+	mac.Write([]byte(purpose + "\n" + link.ID + "\n" + link.PasswordHash + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RecordShareView increments the view counter of a share link
+func RecordShareView(linkID string) error {
+	return models.DB.Model(&models.ShareLink{}).
+		Where("id = ?", linkID).
+		Updates(map[string]interface{}{
+			"view_count":       gorm.Expr("view_count + 1"),
+			"last_accessed_at": time.Now(),
+		}).Error
+}
+
+// RecordShareDownload increments the download counter of a share link
+// Returns ErrShareDownloadLimit if the download limit has been reached
+func RecordShareDownload(linkID string) error {
+	result := models.DB.Model(&models.ShareLink{}).
+		Where("id = ? AND revoked_at IS NULL", linkID).
+		Where("max_downloads = 0 OR download_count < max_downloads").
+		Updates(map[string]interface{}{
+			"download_count":   gorm.Expr("download_count + 1"),
+			"last_accessed_at": time.Now(),
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareDownloadLimit
+	}
+	return nil
+}
+
+// ListShareLinks lists share links created by a user
+func ListShareLinks(userID, fileID string, page, pageSize int) ([]models.ShareLink, int64, error) {
+	var links []models.ShareLink
+	var total int64
+
+	query := models.DB.Model(&models.ShareLink{}).Where("user_id = ?", userID)
+	if fileID != "" {
+		query = query.Where("file_id = ?", fileID)
+	}
+	query.Count(&total)
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&links).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return links, total, nil
+}
+
+// RevokeShareLink revokes a share link owned by the user
+func RevokeShareLink(userID, linkID string) (*models.ShareLink, error) {
+	var link models.ShareLink
+	if err := models.DB.First(&link, "id = ? AND user_id = ?", linkID, userID).Error; err != nil {
+		return nil, ErrShareLinkNotFound
+	}
+
+	if link.IsRevoked() {
+		return &link, nil
+	}
+
+	now := time.Now()
+	if err := models.DB.Model(&link).Update("revoked_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	link.RevokedAt = &now
+
+	return &link, nil
+}
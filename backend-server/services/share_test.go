@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// createTestShareLink shares a new file of a user, protected by password
+func createTestShareLink(t *testing.T, password string) *models.ShareLink {
+	t.Helper()
+
+	user := createTestUser(t, 0)
+	file := &models.File{
+		ID:          uuid.New().String(),
+		UserID:      user.ID,
+		Kind:        models.FileKindResult,
+		Filename:    "result.wav",
+		OSSKey:      "results/" + uuid.New().String() + ".wav",
+		ContentType: "audio/wav",
+	}
+	if err := models.DB.Create(file).Error; err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	link, err := CreateShareLink(user.ID, CreateShareLinkParams{FileID: file.ID, Password: password})
+	if err != nil {
+		t.Fatalf("create share link: %v", err)
+	}
+	return link
+}
+
+func TestShareLinkPasswordTooShort(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 0)
+
+	_, err := CreateShareLink(user.ID, CreateShareLinkParams{FileID: uuid.New().String(), Password: "1234"})
+	if err == nil || !strings.Contains(err.Error(), "at least 8 characters") {
+		t.Fatalf("err = %v, want the password to be rejected as too short", err)
+	}
+}
+
+func TestShareLinkAccessToken(t *testing.T) {
+	setupTestDB(t)
+	link := createTestShareLink(t, "correct horse")
+
+	if _, _, err := ResolveShareLink(link.Token, ShareCredentials{}); !errors.Is(err, ErrSharePasswordRequired) {
+		t.Fatalf("err = %v, want password required", err)
+	}
+
+	access := ShareAccessToken(link, time.Now().Add(ShareAccessTTL))
+	if _, _, err := ResolveShareLink(link.Token, ShareCredentials{Access: access}); err != nil {
+		t.Fatalf("valid access token rejected: %v", err)
+	}
+
+	expired := ShareAccessToken(link, time.Now().Add(-time.Second))
+	other := ShareAccessToken(createTestShareLink(t, "correct horse"), time.Now().Add(ShareAccessTTL))
+	for _, access := range []string{expired, other, "9999999999.forged", ""} {
+		if _, _, err := ResolveShareLink(link.Token, ShareCredentials{Access: access}); !errors.Is(err, ErrSharePasswordRequired) {
+			t.Errorf("access %q: err = %v, want password required", access, err)
+		}
+	}
+}
+
+func TestShareLinkPasswordRateLimit(t *testing.T) {
+	setupTestDB(t)
+	previous := rateLimiter
+	rateLimiter = NewMemoryRateLimiter()
+	t.Cleanup(func() { rateLimiter = previous })
+	config.Cfg.ShareLimitPerLinkHour = 3
+	config.Cfg.ShareLimitPerIPHour = 100
+
+	link := createTestShareLink(t, "correct horse")
+	for i := 0; i < 3; i++ {
+		creds := ShareCredentials{Password: "wrong guess", IP: "10.0.0.1"}
+		if _, _, err := ResolveShareLink(link.Token, creds); !errors.Is(err, ErrSharePasswordIncorrect) {
+			t.Fatalf("attempt %d: err = %v, want incorrect password", i+1, err)
+		}
+	}
+
+	// The link is locked for every client, even with the right password
+	_, _, err := ResolveShareLink(link.Token, ShareCredentials{Password: "correct horse", IP: "10.0.0.2"})
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Code != AuthErrRateLimited || authErr.RetryAfter <= 0 {
+		t.Fatalf("err = %v, want rate limited", err)
+	}
+}
+
+func TestSharePlayToken(t *testing.T) {
+	setupTestDB(t)
+	link := createTestShareLink(t, "correct horse")
+
+	play := SharePlayToken(link, time.Now().Add(SharePlayTTL))
+	if !ValidSharePlayToken(link, play) {
+		t.Fatal("valid play token rejected")
+	}
+	if ValidSharePlayToken(link, SharePlayToken(link, time.Now().Add(-time.Second))) {
+		t.Error("expired play token accepted")
+	}
+
+	// Play and access tokens can not stand in for each other
+	access := ShareAccessToken(link, time.Now().Add(ShareAccessTTL))
+	if ValidSharePlayToken(link, access) {
+		t.Error("access token accepted as play token")
+	}
+	if _, _, err := ResolveShareLink(link.Token, ShareCredentials{Access: play}); !errors.Is(err, ErrSharePasswordRequired) {
+		t.Errorf("play token unlocked the link: err = %v", err)
+	}
+}
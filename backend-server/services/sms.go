@@ -2,6 +2,7 @@ package services
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"fmt"
 	"log"
 	"math/big"
@@ -10,43 +11,10 @@ import (
 	"backend-server/config"
 	"backend-server/models"
 
-	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
-	dysmsapi "github.com/alibabacloud-go/dysmsapi-20170525/v4/client"
-	"github.com/alibabacloud-go/tea/tea"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-var smsClient *dysmsapi.Client
-var smsEnabled bool
-
-// InitSMS initializes the Aliyun SMS client
-func InitSMS() error {
-	cfg := config.Cfg
-
-	// Check if SMS is configured
-	if cfg.SMSAccessKeyID == "" || cfg.SMSAccessKeySecret == "" {
-		log.Println("SMS service not configured, running in development mode (codes will be printed to console)")
-		smsEnabled = false
-		return nil
-	}
-
-	clientConfig := &openapi.Config{
-		AccessKeyId:     tea.String(cfg.SMSAccessKeyID),
-		AccessKeySecret: tea.String(cfg.SMSAccessKeySecret),
-		Endpoint:        tea.String("dysmsapi.aliyuncs.com"),
-	}
-
-	client, err := dysmsapi.NewClient(clientConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create SMS client: %w", err)
-	}
-
-	smsClient = client
-	smsEnabled = true
-	log.Println("SMS service initialized successfully")
-	return nil
-}
-
 // GenerateVerificationCode generates a random 6-digit code
 func GenerateVerificationCode() (string, error) {
 	code := ""
@@ -60,8 +28,62 @@ func GenerateVerificationCode() (string, error) {
 	return code, nil
 }
 
+// reserveSMSLimits enforces the SMS sending limits for a phone number and client IP and
+// returns the limits it counted the code against, to be released if the code is not sent.
+// Returns an AuthError with RetryAfter when a limit is hit, or with code captcha_required
+// when the client has crossed the captcha threshold and did not pass a valid captcha
+func reserveSMSLimits(phone, ip, captchaToken string) ([]RateLimit, error) {
+	cfg := config.Cfg
+
+	if CaptchaEnabled() && cfg.SMSCaptchaAfterPerHour > 0 && ip != "" {
+		allowed, _, err := rateLimiter.Allow("sms:captcha:ip:"+ip, cfg.SMSCaptchaAfterPerHour, time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			ok, err := VerifyCaptcha(captchaToken, ip)
+			if err != nil {
+				log.Printf("Captcha verification failed: %v", err)
+			}
+			if !ok {
+				return nil, newAuthError(AuthErrCaptchaRequired, "captcha verification required")
+			}
+		}
+	}
+
+	limits := []RateLimit{
+		{Key: "sms:phone:" + phone, Limit: cfg.SMSLimitPerPhoneDay, Window: 24 * time.Hour},
+		{Key: "sms:global", Limit: cfg.SMSLimitGlobalHour, Window: time.Hour},
+	}
+	if ip != "" {
+		limits = append([]RateLimit{{Key: "sms:ip:" + ip, Limit: cfg.SMSLimitPerIPHour, Window: time.Hour}}, limits...)
+	}
+
+	// A request denied by one limit must not use up the others
+	denied, retryAfter, err := rateLimiter.AllowAll(limits)
+	if err != nil {
+		return nil, err
+	}
+	if denied != nil {
+		if denied.Key == "sms:global" {
+			log.Printf("[SECURITY] Global SMS limit reached (%d per hour)", denied.Limit)
+		} else {
+			log.Printf("[SECURITY] SMS rate limit hit for %s", denied.Key)
+		}
+		authErr := newAuthError(AuthErrRateLimited,
+			fmt.Sprintf("too many verification codes requested, please try again in %d seconds", int(retryAfter.Seconds())+1))
+		authErr.RetryAfter = retryAfter
+		return nil, authErr
+	}
+
+	return limits, nil
+}
+
 // SendVerificationCode sends a verification code to the specified phone number
-func SendVerificationCode(phone string, purpose models.CodePurpose) (*models.VerificationCode, error) {
+// The cooldown is checked first, then the per-IP, per-phone and global sending limits (see
+// reserveSMSLimits). A code that is not delivered is not counted against the limits, so
+// refused or failed requests do not use up the daily quota of someone else's phone number.
+func SendVerificationCode(phone string, purpose models.CodePurpose, ip, captchaToken string) (*models.VerificationCode, error) {
 	cfg := config.Cfg
 
 	// Check cooldown - find the most recent code for this phone
@@ -79,6 +101,19 @@ func SendVerificationCode(phone string, purpose models.CodePurpose) (*models.Ver
 		}
 	}
 
+	limits, err := reserveSMSLimits(phone, ip, captchaToken)
+	if err != nil {
+		return nil, err
+	}
+	sent := false
+	defer func() {
+		if !sent {
+			if err := rateLimiter.Release(limits); err != nil {
+				log.Printf("Failed to release SMS limits for %s: %v", phone, err)
+			}
+		}
+	}()
+
 	// Generate verification code
 	code, err := GenerateVerificationCode()
 	if err != nil {
@@ -102,71 +137,112 @@ func SendVerificationCode(phone string, purpose models.CodePurpose) (*models.Ver
 		return nil, fmt.Errorf("failed to save verification code: %w", err)
 	}
 
-	// Send SMS
-	if smsEnabled {
-		err = sendAliyunSMS(phone, code)
-		if err != nil {
-			return nil, fmt.Errorf("failed to send SMS: %w", err)
-		}
-		log.Printf("Verification code sent to %s", phone)
-	} else {
-		// Development mode - print to console
-		log.Printf("[DEV MODE] Verification code for %s: %s (expires at %s)", phone, code, expiresAt.Format("15:04:05"))
-	}
-
-	return verificationCode, nil
-}
-
-// sendAliyunSMS sends SMS via Aliyun SMS service
-func sendAliyunSMS(phone, code string) error {
-	cfg := config.Cfg
-
-	request := &dysmsapi.SendSmsRequest{
-		PhoneNumbers:  tea.String(phone),
-		SignName:      tea.String(cfg.SMSSignName),
-		TemplateCode:  tea.String(cfg.SMSTemplateCode),
-		TemplateParam: tea.String(fmt.Sprintf(`{"code":"%s"}`, code)),
-	}
-
-	response, err := smsClient.SendSms(request)
+	// Send SMS, falling back to the secondary provider on failure
+	provider, err := deliverSMSCode(phone, code)
 	if err != nil {
-		return fmt.Errorf("SMS API error: %w", err)
+		// Drop the undelivered code so it does not hold the cooldown
+		models.DB.Delete(verificationCode)
+		return nil, fmt.Errorf("failed to send SMS: %w", err)
 	}
 
-	if *response.Body.Code != "OK" {
-		return fmt.Errorf("SMS send failed: %s - %s", *response.Body.Code, *response.Body.Message)
-	}
+	sent = true
+	verificationCode.Provider = provider
+	models.DB.Model(verificationCode).Update("provider", provider)
+
+	// Replace earlier codes, so that a code used up by wrong guesses cannot be followed by another
+	models.DB.Model(&models.VerificationCode{}).
+		Where("phone = ? AND purpose = ? AND used = ? AND id <> ?", phone, purpose, false, verificationCode.ID).
+		Update("used", true)
+	log.Printf("Verification code sent to %s via %s (expires at %s)", phone, provider, expiresAt.Format("15:04:05"))
 
-	return nil
+	return verificationCode, nil
 }
 
 // VerifyCode verifies a verification code and marks it as used
+// Only the most recent code for the phone and purpose is accepted. Each guess reserves an
+// attempt on the code before it is compared, so concurrent guesses cannot exceed
+// SMS_CODE_MAX_ATTEMPTS; the code is invalidated once the limit is reached
 func VerifyCode(phone, code string, purpose models.CodePurpose) (*models.VerificationCode, error) {
+	maxAttempts := config.Cfg.SMSCodeMaxAttempts
 	var verificationCode models.VerificationCode
 
-	// Find the most recent unused code for this phone and purpose
-	result := models.DB.Where("phone = ? AND purpose = ? AND used = ?", phone, purpose, false).
+	// Find the most recent code for this phone and purpose, earlier codes were replaced by it
+	result := models.DB.Where("phone = ? AND purpose = ?", phone, purpose).
 		Order("created_at DESC").
 		First(&verificationCode)
 
 	if result.Error != nil {
-		return nil, fmt.Errorf("verification code not found")
-	}
-
-	// Check if code matches
-	if verificationCode.Code != code {
-		return nil, fmt.Errorf("invalid verification code")
+		return nil, newAuthError(AuthErrCodeNotFound, "verification code not found")
+	}
+	if verificationCode.Used {
+		return nil, usedCodeError(&verificationCode, maxAttempts)
+	}
+
+	// Reserve an attempt before comparing the code
+	reserve := models.DB.Model(&models.VerificationCode{}).
+		Where("id = ? AND used = ?", verificationCode.ID, false)
+	if maxAttempts > 0 {
+		reserve = reserve.Where("failed_attempts < ?", maxAttempts)
+	}
+	reserved := reserve.Update("failed_attempts", gorm.Expr("failed_attempts + 1"))
+	if reserved.Error != nil {
+		return nil, fmt.Errorf("failed to check verification code: %w", reserved.Error)
+	}
+	if reserved.RowsAffected == 0 {
+		// Used up or redeemed by a concurrent request
+		models.DB.First(&verificationCode, "id = ?", verificationCode.ID)
+		return nil, usedCodeError(&verificationCode, maxAttempts)
+	}
+
+	// Check if code matches (constant time)
+	if subtle.ConstantTimeCompare([]byte(verificationCode.Code), []byte(code)) != 1 {
+		authErr := newAuthError(AuthErrCodeInvalid, "invalid verification code")
+		if maxAttempts > 0 {
+			// Invalidate the code once it reaches the attempt limit
+			invalidated := models.DB.Model(&models.VerificationCode{}).
+				Where("id = ? AND used = ? AND failed_attempts >= ?", verificationCode.ID, false, maxAttempts).
+				Update("used", true)
+			if invalidated.Error == nil && invalidated.RowsAffected > 0 {
+				log.Printf("[SECURITY] Verification code %s for %s invalidated after %d failed attempts", verificationCode.ID, phone, maxAttempts)
+			}
+
+			var attempts int
+			models.DB.Model(&models.VerificationCode{}).
+				Where("id = ?", verificationCode.ID).
+				Select("failed_attempts").
+				Scan(&attempts)
+			authErr.AttemptsRemaining = max(maxAttempts-attempts, 0)
+		}
+		return nil, authErr
 	}
 
 	// Check if code has expired
 	if verificationCode.IsExpired() {
-		return nil, fmt.Errorf("verification code has expired")
+		return nil, newAuthError(AuthErrCodeExpired, "verification code has expired")
 	}
 
-	// Mark code as used
-	if err := models.DB.Model(&verificationCode).Update("used", true).Error; err != nil {
-		return nil, fmt.Errorf("failed to mark code as used: %w", err)
+	// Mark code as used and give back the reserved attempt, guarding against concurrent use of the same code
+	update := models.DB.Model(&models.VerificationCode{}).
+		Where("id = ? AND used = ?", verificationCode.ID, false).
+		Updates(map[string]interface{}{
+			"used":            true,
+			"failed_attempts": gorm.Expr("failed_attempts - 1"),
+		})
+	if update.Error != nil {
+		return nil, fmt.Errorf("failed to mark code as used: %w", update.Error)
+	}
+	if update.RowsAffected == 0 {
+		return nil, newAuthError(AuthErrCodeNotFound, "verification code not found")
 	}
+	verificationCode.Used = true
 
 	return &verificationCode, nil
 }
+
+// usedCodeError describes why a code can no longer be verified
+func usedCodeError(verificationCode *models.VerificationCode, maxAttempts int) *AuthError {
+	if maxAttempts > 0 && verificationCode.FailedAttempts >= maxAttempts {
+		return newAuthError(AuthErrCodeTooManyTries, "too many failed attempts, please request a new code")
+	}
+	return newAuthError(AuthErrCodeNotFound, "verification code not found")
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"backend-server/config"
+
+	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
+	dysmsapi "github.com/alibabacloud-go/dysmsapi-20170525/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// AliyunSMSProvider sends codes through Aliyun SMS (dysmsapi)
+type AliyunSMSProvider struct {
+	client           *dysmsapi.Client
+	signName         string
+	templateCode     string
+	intlTemplateCode string
+}
+
+// NewAliyunSMSProvider creates the Aliyun provider from config
+func NewAliyunSMSProvider() (*AliyunSMSProvider, error) {
+	cfg := config.Cfg
+
+	if cfg.SMSAccessKeyID == "" || cfg.SMSAccessKeySecret == "" {
+		return nil, errors.New("aliyun SMS provider requires SMS_ACCESS_KEY_ID and SMS_ACCESS_KEY_SECRET")
+	}
+
+	clientConfig := &openapi.Config{
+		AccessKeyId:     tea.String(cfg.SMSAccessKeyID),
+		AccessKeySecret: tea.String(cfg.SMSAccessKeySecret),
+		Endpoint:        tea.String("dysmsapi.aliyuncs.com"),
+	}
+
+	client, err := dysmsapi.NewClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SMS client: %w", err)
+	}
+
+	return &AliyunSMSProvider{
+		client:           client,
+		signName:         cfg.SMSSignName,
+		templateCode:     cfg.SMSTemplateCode,
+		intlTemplateCode: cfg.SMSIntlTemplateCode,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *AliyunSMSProvider) Name() string {
+	return SMSProviderAliyun
+}
+
+// SendCode sends the code using the configured sign name and template
+// Mainland numbers are sent without the +86 prefix, international numbers with the
+// calling code and the international template
+func (p *AliyunSMSProvider) SendCode(phone, code string) error {
+	number := strings.TrimPrefix(phone, "+"+DefaultCountryCode)
+	templateCode := p.templateCode
+	if !IsMainlandChinaPhone(phone) {
+		if p.intlTemplateCode == "" {
+			return errors.New("international SMS template not configured")
+		}
+		number = strings.TrimPrefix(phone, "+")
+		templateCode = p.intlTemplateCode
+	}
+
+	request := &dysmsapi.SendSmsRequest{
+		PhoneNumbers:  tea.String(number),
+		SignName:      tea.String(p.signName),
+		TemplateCode:  tea.String(templateCode),
+		TemplateParam: tea.String(fmt.Sprintf(`{"code":"%s"}`, code)),
+	}
+
+	response, err := p.client.SendSms(request)
+	if err != nil {
+		return fmt.Errorf("SMS API error: %w", err)
+	}
+
+	if *response.Body.Code != "OK" {
+		return fmt.Errorf("SMS send failed: %s - %s", *response.Body.Code, *response.Body.Message)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-server/config"
+)
+
+// SMSProvider delivers verification codes through an SMS gateway
+type SMSProvider interface {
+	// Name identifies the provider, it is recorded on each delivered verification code
+	Name() string
+	// SendCode delivers a verification code to a phone number in E.164 format
+	SendCode(phone, code string) error
+}
+
+// SMS provider names
+const (
+	SMSProviderAliyun  = "aliyun"
+	SMSProviderTencent = "tencent"
+	SMSProviderMock    = "mock"
+	SMSProviderConsole = "console"
+)
+
+// smsProviders holds the providers for mainland China numbers in failover order
+var smsProviders []SMSProvider
+
+// smsIntlProviders holds the providers for international numbers in failover order
+var smsIntlProviders []SMSProvider
+
+// InitSMS initializes the primary and fallback SMS providers
+func InitSMS() error {
+	cfg := config.Cfg
+
+	primary := cfg.SMSProvider
+	if primary == "" {
+		// Keep the historical behaviour: Aliyun when configured, console otherwise
+		primary = SMSProviderConsole
+		if cfg.SMSAccessKeyID != "" && cfg.SMSAccessKeySecret != "" {
+			primary = SMSProviderAliyun
+		}
+	}
+
+	providers, err := newSMSProviderChain(primary, cfg.SMSFallbackProvider)
+	if err != nil {
+		return err
+	}
+	smsProviders = providers
+	smsIntlProviders = providers
+
+	if cfg.SMSIntlProvider != "" {
+		intlProviders, err := newSMSProviderChain(cfg.SMSIntlProvider, cfg.SMSFallbackProvider)
+		if err != nil {
+			return err
+		}
+		smsIntlProviders = intlProviders
+	}
+
+	if primary == SMSProviderConsole {
+		log.Println("SMS service not configured, running in development mode (codes will be printed to console)")
+	}
+	log.Printf("SMS service initialized (providers: %s, international: %s)",
+		smsProviderNames(smsProviders), smsProviderNames(smsIntlProviders))
+	return nil
+}
+
+// newSMSProviderChain creates a primary provider followed by an optional distinct fallback
+func newSMSProviderChain(primary, fallback string) ([]SMSProvider, error) {
+	names := []string{primary}
+	if fallback != "" && fallback != primary {
+		names = append(names, fallback)
+	}
+
+	providers := make([]SMSProvider, 0, len(names))
+	for _, name := range names {
+		provider, err := newSMSProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+func smsProviderNames(providers []SMSProvider) string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, " -> ")
+}
+
+// newSMSProvider creates a provider by name
+func newSMSProvider(name string) (SMSProvider, error) {
+	switch name {
+	case SMSProviderAliyun:
+		return NewAliyunSMSProvider()
+	case SMSProviderTencent:
+		return NewTencentSMSProvider()
+	case SMSProviderMock:
+		return NewMockSMSProvider(config.Cfg.SMSMockFile, config.Cfg.SMSMockURL), nil
+	case SMSProviderConsole:
+		return ConsoleSMSProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown SMS provider: %s", name)
+	}
+}
+
+// deliverSMSCode sends a code to an E.164 number through the configured providers in order
+// International numbers use the international provider chain
+// Returns the name of the provider that delivered it
+func deliverSMSCode(phone, code string) (string, error) {
+	providers := smsProviders
+	if !IsMainlandChinaPhone(phone) {
+		providers = smsIntlProviders
+	}
+	if len(providers) == 0 {
+		return "", errors.New("SMS service not initialized")
+	}
+
+	var errs []error
+	for i, provider := range providers {
+		err := provider.SendCode(phone, code)
+		if err == nil {
+			if i > 0 {
+				log.Printf("Verification code to %s delivered by fallback provider %s", phone, provider.Name())
+			}
+			return provider.Name(), nil
+		}
+		log.Printf("SMS provider %s failed: %v", provider.Name(), err)
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+
+	return "", errors.Join(errs...)
+}
+
+// ConsoleSMSProvider prints codes to the log, used in development
+type ConsoleSMSProvider struct{}
+
+// Name returns the provider name
+func (ConsoleSMSProvider) Name() string {
+	return SMSProviderConsole
+}
+
+// SendCode prints the code to the log
+func (ConsoleSMSProvider) SendCode(phone, code string) error {
+	log.Printf("[DEV MODE] Verification code for %s: %s", phone, code)
+	return nil
+}
+
+// MockSMSProvider records codes instead of sending them, for tests and staging
+// Messages are appended as JSON lines to a file and/or posted to an HTTP endpoint
+type MockSMSProvider struct {
+	filePath string
+	url      string
+	client   *http.Client
+	mu       sync.Mutex
+}
+
+// mockSMSMessage is the record written by MockSMSProvider
+type mockSMSMessage struct {
+	Phone  string    `json:"phone"`
+	Code   string    `json:"code"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// NewMockSMSProvider creates a mock provider writing to filePath and/or posting to url
+func NewMockSMSProvider(filePath, url string) *MockSMSProvider {
+	return &MockSMSProvider{
+		filePath: filePath,
+		url:      url,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (m *MockSMSProvider) Name() string {
+	return SMSProviderMock
+}
+
+// SendCode records the code to the configured file and endpoint
+func (m *MockSMSProvider) SendCode(phone, code string) error {
+	body, err := json.Marshal(mockSMSMessage{Phone: phone, Code: code, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	if m.filePath != "" {
+		if err := m.appendToFile(body); err != nil {
+			return fmt.Errorf("failed to write mock SMS: %w", err)
+		}
+	}
+
+	if m.url != "" {
+		resp, err := m.client.Post(m.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to post mock SMS: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("mock SMS endpoint returned status %d", resp.StatusCode)
+		}
+	}
+
+	if m.filePath == "" && m.url == "" {
+		log.Printf("[MOCK SMS] Verification code for %s: %s", phone, code)
+	}
+	return nil
+}
+
+func (m *MockSMSProvider) appendToFile(line []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
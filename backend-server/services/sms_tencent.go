@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-server/config"
+)
+
+const (
+	tencentSMSHost    = "sms.tencentcloudapi.com"
+	tencentSMSService = "sms"
+	tencentSMSVersion = "2021-01-11"
+)
+
+// TencentSMSProvider sends codes through Tencent Cloud SMS
+// Requests are signed with TC3-HMAC-SHA256, so no SDK is required
+type TencentSMSProvider struct {
+	secretID       string
+	secretKey      string
+	region         string
+	appID          string
+	signName       string
+	templateID     string
+	intlTemplateID string
+	client         *http.Client
+}
+
+// NewTencentSMSProvider creates the Tencent Cloud provider from config
+func NewTencentSMSProvider() (*TencentSMSProvider, error) {
+	cfg := config.Cfg
+
+	if cfg.TencentSMSSecretID == "" || cfg.TencentSMSSecretKey == "" || cfg.TencentSMSAppID == "" {
+		return nil, errors.New("tencent SMS provider requires TENCENT_SMS_SECRET_ID, TENCENT_SMS_SECRET_KEY and TENCENT_SMS_APP_ID")
+	}
+
+	return &TencentSMSProvider{
+		secretID:       cfg.TencentSMSSecretID,
+		secretKey:      cfg.TencentSMSSecretKey,
+		region:         cfg.TencentSMSRegion,
+		appID:          cfg.TencentSMSAppID,
+		signName:       cfg.TencentSMSSignName,
+		templateID:     cfg.TencentSMSTemplateID,
+		intlTemplateID: cfg.TencentSMSIntlTemplateID,
+		client:         &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Name returns the provider name
+func (p *TencentSMSProvider) Name() string {
+	return SMSProviderTencent
+}
+
+type tencentSendSMSRequest struct {
+	PhoneNumberSet   []string `json:"PhoneNumberSet"`
+	SmsSdkAppId      string   `json:"SmsSdkAppId"`
+	SignName         string   `json:"SignName,omitempty"`
+	TemplateId       string   `json:"TemplateId"`
+	TemplateParamSet []string `json:"TemplateParamSet"`
+}
+
+type tencentSendSMSResponse struct {
+	Response struct {
+		SendStatusSet []struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"SendStatusSet"`
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+		RequestId string `json:"RequestId"`
+	} `json:"Response"`
+}
+
+// SendCode sends the code using the configured sign name and template
+// International messages use the international template and carry no sign name
+func (p *TencentSMSProvider) SendCode(phone, code string) error {
+	request := tencentSendSMSRequest{
+		PhoneNumberSet:   []string{phone},
+		SmsSdkAppId:      p.appID,
+		SignName:         p.signName,
+		TemplateId:       p.templateID,
+		TemplateParamSet: []string{code},
+	}
+	if !IsMainlandChinaPhone(phone) {
+		if p.intlTemplateID == "" {
+			return errors.New("international SMS template not configured")
+		}
+		request.SignName = ""
+		request.TemplateId = p.intlTemplateID
+	}
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://"+tencentSMSHost, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	p.sign(req, payload, time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("SMS API error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read SMS response: %w", err)
+	}
+
+	var result tencentSendSMSResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse SMS response (status %d): %w", resp.StatusCode, err)
+	}
+
+	if result.Response.Error != nil {
+		return fmt.Errorf("SMS send failed: %s - %s", result.Response.Error.Code, result.Response.Error.Message)
+	}
+	if len(result.Response.SendStatusSet) == 0 {
+		return fmt.Errorf("SMS send failed: empty response (request %s)", result.Response.RequestId)
+	}
+	if status := result.Response.SendStatusSet[0]; status.Code != "Ok" {
+		return fmt.Errorf("SMS send failed: %s - %s", status.Code, status.Message)
+	}
+
+	return nil
+}
+
+// sign adds the TC3-HMAC-SHA256 authorization headers to a request
+func (p *TencentSMSProvider) sign(req *http.Request, payload []byte, now time.Time) {
+	const contentType = "application/json; charset=utf-8"
+	timestamp := now.Unix()
+	date := now.UTC().Format("2006-01-02")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		"content-type:" + contentType + "\nhost:" + tencentSMSHost + "\n",
+		"content-type;host",
+		sha256Hex(payload),
+	}, "\n")
+
+	credentialScope := date + "/" + tencentSMSService + "/tc3_request"
+	stringToSign := strings.Join([]string{
+		"TC3-HMAC-SHA256",
+		strconv.FormatInt(timestamp, 10),
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	secretDate := hmacSHA256([]byte("TC3"+p.secretKey), date)
+	secretService := hmacSHA256(secretDate, tencentSMSService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Host", tencentSMSHost)
+	req.Header.Set("X-TC-Action", "SendSms")
+	req.Header.Set("X-TC-Version", tencentSMSVersion)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-TC-Region", p.region)
+	req.Header.Set("Authorization", fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host, Signature=%s",
+		p.secretID, credentialScope, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
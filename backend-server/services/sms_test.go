@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+func createTestCode(t *testing.T, phone, code string, createdAt time.Time) *models.VerificationCode {
+	t.Helper()
+
+	vc := &models.VerificationCode{
+		ID:        uuid.New().String(),
+		Phone:     phone,
+		Code:      code,
+		Purpose:   models.CodePurposeLogin,
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+		CreatedAt: createdAt,
+	}
+	if err := models.DB.Create(vc).Error; err != nil {
+		t.Fatalf("create code: %v", err)
+	}
+	return vc
+}
+
+func TestVerifyCodeConcurrentGuesses(t *testing.T) {
+	setupTestDB(t)
+	config.Cfg.SMSCodeMaxAttempts = 3
+	vc := createTestCode(t, "+8613800000000", "123456", time.Now())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			VerifyCode(vc.Phone, "000000", models.CodePurposeLogin)
+		}()
+	}
+	wg.Wait()
+
+	var stored models.VerificationCode
+	models.DB.First(&stored, "id = ?", vc.ID)
+	if stored.FailedAttempts != 3 || !stored.Used {
+		t.Fatalf("got %d attempts (used %v), want 3 and used", stored.FailedAttempts, stored.Used)
+	}
+
+	_, err := VerifyCode(vc.Phone, "123456", models.CodePurposeLogin)
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Code != AuthErrCodeTooManyTries {
+		t.Fatalf("correct code after the limit: got %v, want %s", err, AuthErrCodeTooManyTries)
+	}
+}
+
+func TestVerifyCodeOnlyLatest(t *testing.T) {
+	setupTestDB(t)
+	config.Cfg.SMSCodeMaxAttempts = 3
+	phone := "+8613800000001"
+	createTestCode(t, phone, "111111", time.Now().Add(-time.Minute))
+	latest := createTestCode(t, phone, "222222", time.Now())
+
+	// Using up the latest code must not make the earlier one guessable again
+	for i := 0; i < 3; i++ {
+		VerifyCode(phone, "000000", models.CodePurposeLogin)
+	}
+	if _, err := VerifyCode(phone, "111111", models.CodePurposeLogin); err == nil {
+		t.Fatal("earlier code was accepted")
+	}
+
+	var stored models.VerificationCode
+	models.DB.First(&stored, "id = ?", latest.ID)
+	if !stored.Used {
+		t.Fatal("latest code was not invalidated")
+	}
+}
+
+func TestVerifyCodeSuccess(t *testing.T) {
+	setupTestDB(t)
+	config.Cfg.SMSCodeMaxAttempts = 3
+	vc := createTestCode(t, "+8613800000002", "123456", time.Now())
+
+	if _, err := VerifyCode(vc.Phone, "000000", models.CodePurposeLogin); err == nil {
+		t.Fatal("wrong code was accepted")
+	}
+	if _, err := VerifyCode(vc.Phone, "123456", models.CodePurposeLogin); err != nil {
+		t.Fatalf("VerifyCode: %v", err)
+	}
+
+	var stored models.VerificationCode
+	models.DB.First(&stored, "id = ?", vc.ID)
+	if !stored.Used || stored.FailedAttempts != 1 {
+		t.Fatalf("got used %v with %d attempts, want used with 1", stored.Used, stored.FailedAttempts)
+	}
+
+	// A code can only be used once
+	if _, err := VerifyCode(vc.Phone, "123456", models.CodePurposeLogin); err == nil {
+		t.Fatal("code was accepted twice")
+	}
+}
+
+// failingSMSProvider fails every delivery
+type failingSMSProvider struct{}
+
+func (failingSMSProvider) Name() string                      { return "failing" }
+func (failingSMSProvider) SendCode(phone, code string) error { return errors.New("provider down") }
+
+func TestSendVerificationCodeOnlyCountsSentCodes(t *testing.T) {
+	setupTestDB(t)
+	config.Cfg.SMSLimitPerPhoneDay = 2
+	config.Cfg.SMSCodeCooldownSeconds = 60
+	previousLimiter, previousProviders := rateLimiter, smsProviders
+	rateLimiter = NewMemoryRateLimiter()
+	t.Cleanup(func() { rateLimiter, smsProviders = previousLimiter, previousProviders })
+	phone := "+8613800000002"
+
+	// Failed deliveries do not use up the phone's quota
+	smsProviders = []SMSProvider{failingSMSProvider{}}
+	for i := 0; i < 3; i++ {
+		if _, err := SendVerificationCode(phone, models.CodePurposeLogin, "10.0.0.1", ""); err == nil {
+			t.Fatal("failed delivery reported as sent")
+		}
+	}
+
+	// Neither do requests refused by the cooldown
+	smsProviders = []SMSProvider{ConsoleSMSProvider{}}
+	if _, err := SendVerificationCode(phone, models.CodePurposeLogin, "10.0.0.1", ""); err != nil {
+		t.Fatalf("first code: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := SendVerificationCode(phone, models.CodePurposeLogin, "10.0.0.2", ""); err == nil {
+			t.Fatal("code sent during the cooldown")
+		}
+	}
+
+	models.DB.Model(&models.VerificationCode{}).Where("phone = ?", phone).
+		Update("created_at", time.Now().Add(-time.Hour))
+	if _, err := SendVerificationCode(phone, models.CodePurposeLogin, "10.0.0.3", ""); err != nil {
+		t.Fatalf("second code after the cooldown: %v", err)
+	}
+
+	models.DB.Model(&models.VerificationCode{}).Where("phone = ?", phone).
+		Update("created_at", time.Now().Add(-time.Hour))
+	_, err := SendVerificationCode(phone, models.CodePurposeLogin, "10.0.0.4", "")
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Code != AuthErrRateLimited {
+		t.Fatalf("third code: err = %v, want the daily limit of 2 reached", err)
+	}
+}
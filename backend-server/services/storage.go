@@ -0,0 +1,44 @@
+package services
+
+import "io"
+
+// ObjectStorage stores the audio files the worker reads and writes
+// The worker only depends on this interface, so it can be tested without OSS
+type ObjectStorage interface {
+	// SignedURL returns a temporary URL the inference service can download an object from
+	SignedURL(key string, expireSeconds int64) (string, error)
+	// Upload stores data under a new key and returns the key
+	Upload(data []byte, filename string, contentType string) (string, error)
+	// Download returns the content of an object
+	Download(key string) ([]byte, error)
+	// Delete removes an object
+	Delete(key string) error
+}
+
+// OSSStorage stores objects in the bucket configured for InitOSS
+type OSSStorage struct{}
+
+// SignedURL returns a signed GET URL for an object
+func (OSSStorage) SignedURL(key string, expireSeconds int64) (string, error) {
+	return GetSignedURL(key, expireSeconds)
+}
+
+// Upload uploads data to OSS and returns the object key
+func (OSSStorage) Upload(data []byte, filename string, contentType string) (string, error) {
+	return UploadBytes(data, filename, contentType)
+}
+
+// Download reads a whole object from OSS
+func (OSSStorage) Download(key string) ([]byte, error) {
+	body, err := GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// Delete deletes an object from OSS
+func (OSSStorage) Delete(key string) error {
+	return DeleteObject(key)
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrTaskInvalidState  = errors.New("operation not allowed in the current task status")
+	ErrWorkerUnavailable = errors.New("worker is not running in this process")
+	ErrTaskCreditsNeeded = errors.New("the task was refunded and its user has insufficient credits to run it again")
+)
+
+// TaskFilter selects tasks in the admin task list
+type TaskFilter struct {
+	Status      models.TaskStatus
+	UserID      string
+	OlderThan   time.Duration // Only tasks created at least this long ago
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time // Exclusive
+	Page        int
+	PageSize    int
+}
+
+// QueueStats summarizes the task queue and recent processing times
+type QueueStats struct {
+	Scheduled         int64         `json:"scheduled"`
+	Pending           int64         `json:"pending"`
+	Processing        int64         `json:"processing"`
+	OldestPendingAt   *time.Time    `json:"oldest_pending_at,omitempty"`
+	WindowHours       int           `json:"window_hours"`
+	Completed         int64         `json:"completed"` // Finished within the window
+	Failed            int64         `json:"failed"`
+	Cancelled         int64         `json:"cancelled"`
+	AvgWaitSeconds    float64       `json:"avg_wait_seconds"`       // From creation to pickup, tasks started within the window
+	AvgProcessSeconds float64       `json:"avg_processing_seconds"` // From pickup to completion, tasks completed within the window
+	Worker            *WorkerStatus `json:"worker,omitempty"`
+}
+
+// ListAllTasks lists tasks of all users
+// Pending tasks are returned in queue order, other statuses newest first
+func ListAllTasks(filter TaskFilter) ([]models.Task, int64, error) {
+	query := models.DB.Model(&models.Task{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.OlderThan > 0 {
+		query = query.Where("created_at <= ?", time.Now().Add(-filter.OlderThan))
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count tasks: %w", err)
+	}
+
+	order := "created_at DESC"
+	switch filter.Status {
+	case models.TaskStatusPending:
+		order = "priority DESC, created_at ASC"
+	case models.TaskStatusScheduled:
+		order = "run_at ASC"
+	}
+
+	var tasks []models.Task
+	offset := (filter.Page - 1) * filter.PageSize
+	if err := query.Order(order).Offset(offset).Limit(filter.PageSize).Find(&tasks).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	return tasks, total, nil
+}
+
+// GetQueueStats returns queue depth and the average wait and processing time over the last windowHours
+func GetQueueStats(windowHours int) (*QueueStats, error) {
+	stats := &QueueStats{WindowHours: windowHours}
+	since := time.Now().Add(-time.Duration(windowHours) * time.Hour)
+
+	var counts []struct {
+		Status models.TaskStatus
+		Count  int64
+	}
+	if err := models.DB.Model(&models.Task{}).
+		Select("status, COUNT(*) AS count").
+		Where("status IN ? OR finished_at >= ?",
+			[]models.TaskStatus{models.TaskStatusScheduled, models.TaskStatusPending, models.TaskStatusProcessing}, since).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count tasks: %w", err)
+	}
+	for _, c := range counts {
+		switch c.Status {
+		case models.TaskStatusScheduled:
+			stats.Scheduled = c.Count
+		case models.TaskStatusPending:
+			stats.Pending = c.Count
+		case models.TaskStatusProcessing:
+			stats.Processing = c.Count
+		case models.TaskStatusCompleted:
+			stats.Completed = c.Count
+		case models.TaskStatusFailed:
+			stats.Failed = c.Count
+		case models.TaskStatusCancelled:
+			stats.Cancelled = c.Count
+		}
+	}
+
+	var oldest models.Task
+	if err := models.DB.Where("status = ?", models.TaskStatusPending).
+		Order("created_at ASC").Limit(1).Find(&oldest).Error; err != nil {
+		return nil, fmt.Errorf("failed to find oldest pending task: %w", err)
+	}
+	if oldest.ID != "" {
+		stats.OldestPendingAt = &oldest.CreatedAt
+	}
+
+	var avgWait, avgProcess *float64
+	if err := models.DB.Model(&models.Task{}).
+		Select("AVG(TIMESTAMPDIFF(SECOND, created_at, started_at))").
+		Where("started_at >= ?", since).
+		Scan(&avgWait).Error; err != nil {
+		return nil, fmt.Errorf("failed to compute wait time: %w", err)
+	}
+	if err := models.DB.Model(&models.Task{}).
+		Select("AVG(TIMESTAMPDIFF(SECOND, started_at, finished_at))").
+		Where("status = ? AND finished_at >= ? AND started_at IS NOT NULL", models.TaskStatusCompleted, since).
+		Scan(&avgProcess).Error; err != nil {
+		return nil, fmt.Errorf("failed to compute processing time: %w", err)
+	}
+	if avgWait != nil {
+		stats.AvgWaitSeconds = *avgWait
+	}
+	if avgProcess != nil {
+		stats.AvgProcessSeconds = *avgProcess
+	}
+
+	if w := GetWorker(); w != nil {
+		status := w.Status()
+		stats.Worker = &status
+	}
+
+	return stats, nil
+}
+
+// GetTaskByID loads a task of any user
+func GetTaskByID(taskID string) (*models.Task, error) {
+	var task models.Task
+	if err := models.DB.First(&task, "id = ?", taskID).Error; err != nil {
+		return nil, ErrTaskNotFound
+	}
+	return &task, nil
+}
+
+// updateTaskIn changes a task only if it is still in one of the given statuses
+func updateTaskIn(task *models.Task, statuses []models.TaskStatus, updates map[string]interface{}) error {
+	result := models.DB.Model(task).Where("status IN ?", statuses).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update task: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskInvalidState
+	}
+	return nil
+}
+
+// RequeueTask puts a failed, cancelled or stuck processing task back into the queue
+// Processing tasks count as stuck once they started TASK_STUCK_MINUTES ago, a worker may still
+// be running younger ones. Credits are not charged again, unless the task was refunded: then its
+// price is deducted again, in the same transaction as the status change.
+func RequeueTask(taskID string) (*models.Task, error) {
+	task, err := GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	stuckBefore := time.Now().Add(-time.Duration(config.Cfg.TaskStuckMinutes) * time.Minute)
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the user first, like refunds, so the task's credit balance can not change under us
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", task.UserID).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+
+		result := tx.Model(task).
+			Where("status IN ? OR (status = ? AND started_at < ?)",
+				[]models.TaskStatus{models.TaskStatusFailed, models.TaskStatusCancelled},
+				models.TaskStatusProcessing, stuckBefore).
+			Updates(map[string]interface{}{
+				"status":          models.TaskStatusPending,
+				"error_message":   "",
+				"started_at":      nil,
+				"finished_at":     nil,
+				"claim_id":        "",
+				"failed_attempts": 0,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update task: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrTaskInvalidState
+		}
+
+		var refunds int64
+		if err := tx.Model(&models.CreditLog{}).
+			Where("ref_id = ? AND type = ?", task.ID, "refund").
+			Count(&refunds).Error; err != nil {
+			return fmt.Errorf("failed to look up task refunds: %w", err)
+		}
+		balance, err := taskCreditBalance(tx, task.ID)
+		if err != nil {
+			return err
+		}
+		if refunds == 0 || balance > 0 || HasUnlimitedCredits(&user) {
+			return nil
+		}
+
+		price, err := taskPrice(tx, task)
+		if err != nil {
+			return err
+		}
+		creditLog, err := deductCreditsTx(tx, task.UserID, price, task.ID, "TTS task re-queued after refund")
+		if err != nil {
+			return ErrTaskCreditsNeeded
+		}
+		return auditCreditLog(tx, creditLog, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetTaskByID(taskID)
+}
+
+// taskPrice returns the credits a task costs: one task's worth, or one per line for scripts
+func taskPrice(tx *gorm.DB, task *models.Task) (int, error) {
+	if task.Type != models.TaskTypeScript {
+		return config.Cfg.CreditsPerTask, nil
+	}
+	var lines int64
+	if err := tx.Model(&models.TaskLine{}).Where("task_id = ?", task.ID).Count(&lines).Error; err != nil {
+		return 0, fmt.Errorf("failed to count script lines: %w", err)
+	}
+	return ScriptCredits(int(lines)), nil
+}
+
+// FailTask marks a scheduled, pending or processing task as failed, optionally refunding its credits
+// A result produced by a worker that is still running the task is discarded
+func FailTask(taskID, reason string, refund bool) (*models.Task, error) {
+	task, err := GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	message := "Failed by administrator"
+	if reason != "" {
+		message += ": " + reason
+	}
+	if err := updateTaskIn(task,
+		[]models.TaskStatus{models.TaskStatusScheduled, models.TaskStatusPending, models.TaskStatusProcessing},
+		map[string]interface{}{
+			"status":        models.TaskStatusFailed,
+			"error_message": message,
+			"finished_at":   time.Now(),
+		}); err != nil {
+		return nil, err
+	}
+
+	if refund {
+		if err := RefundTaskCredits(task, "Refund for failed task"); err != nil {
+			return nil, err
+		}
+	}
+
+	return GetTaskByID(taskID)
+}
+
+// CancelTask cancels a scheduled or pending task, optionally refunding its credits
+func CancelTask(taskID string, refund bool) (*models.Task, error) {
+	task, err := GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := updateTaskIn(task,
+		[]models.TaskStatus{models.TaskStatusScheduled, models.TaskStatusPending},
+		map[string]interface{}{
+			"status":      models.TaskStatusCancelled,
+			"finished_at": time.Now(),
+		}); err != nil {
+		return nil, err
+	}
+
+	if refund {
+		if err := RefundTaskCredits(task, "Refund for cancelled task"); err != nil {
+			return nil, err
+		}
+	}
+
+	return GetTaskByID(taskID)
+}
+
+// SetTaskPriority changes the priority of a scheduled or pending task
+func SetTaskPriority(taskID string, priority int) (*models.Task, error) {
+	task, err := GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := updateTaskIn(task,
+		[]models.TaskStatus{models.TaskStatusScheduled, models.TaskStatusPending},
+		map[string]interface{}{"priority": priority}); err != nil {
+		return nil, err
+	}
+
+	return GetTaskByID(taskID)
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// createTaskWithStatus creates a single-voice task of a user in the given status
+func createTaskWithStatus(t *testing.T, userID string, status models.TaskStatus, startedAt *time.Time) *models.Task {
+	t.Helper()
+
+	task := &models.Task{
+		ID:                   uuid.New().String(),
+		UserID:               userID,
+		Status:               status,
+		Type:                 models.TaskTypeSingle,
+		Text:                 "hello",
+		ReferenceAudioFileID: uuid.New().String(),
+		StartedAt:            startedAt,
+	}
+	if err := models.DB.Create(task).Error; err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return task
+}
+
+func TestRequeueProcessingTaskOnlyWhenStuck(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 100)
+
+	recent := time.Now().Add(-time.Minute)
+	running := createTaskWithStatus(t, user.ID, models.TaskStatusProcessing, &recent)
+	other := createTaskWithStatus(t, user.ID, models.TaskStatusFailed, nil)
+	if _, err := RequeueTask(running.ID); !errors.Is(err, ErrTaskInvalidState) {
+		t.Fatalf("err = %v, want invalid state for a task that started a minute ago", err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	stuck := createTaskWithStatus(t, user.ID, models.TaskStatusProcessing, &old)
+	task, err := RequeueTask(stuck.ID)
+	if err != nil {
+		t.Fatalf("requeue stuck task: %v", err)
+	}
+	if task.Status != models.TaskStatusPending || task.StartedAt != nil {
+		t.Errorf("status = %s, started_at = %v, want pending", task.Status, task.StartedAt)
+	}
+
+	if reloaded, _ := GetTaskByID(other.ID); reloaded.Status != models.TaskStatusFailed {
+		t.Errorf("other task status = %s, want it left failed", reloaded.Status)
+	}
+}
+
+func TestRequeueRefundedTaskChargesAgain(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 100)
+	task := createTaskWithStatus(t, user.ID, models.TaskStatusFailed, nil)
+	if err := DeductCredits(user.ID, task.ID); err != nil {
+		t.Fatalf("deduct: %v", err)
+	}
+
+	// Not refunded: requeued for free
+	if _, err := RequeueTask(task.ID); err != nil {
+		t.Fatalf("requeue: %v", err)
+	}
+	if credits, _ := GetUserCredits(user.ID); credits != 90 {
+		t.Fatalf("credits = %d, want 90", credits)
+	}
+
+	// Refunded: charged again
+	if _, err := FailTask(task.ID, "", true); err != nil {
+		t.Fatalf("fail with refund: %v", err)
+	}
+	if _, err := RequeueTask(task.ID); err != nil {
+		t.Fatalf("requeue refunded task: %v", err)
+	}
+	if credits, _ := GetUserCredits(user.ID); credits != 90 {
+		t.Errorf("credits = %d, want 90 after charging the requeued task", credits)
+	}
+}
+
+func TestRequeueRefundedTaskWithoutCredits(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, 10)
+	task := createTaskWithStatus(t, user.ID, models.TaskStatusPending, nil)
+	if err := DeductCredits(user.ID, task.ID); err != nil {
+		t.Fatalf("deduct: %v", err)
+	}
+	if _, err := CancelTask(task.ID, true); err != nil {
+		t.Fatalf("cancel with refund: %v", err)
+	}
+	if err := models.DB.Model(user).Update("credits", 5).Error; err != nil {
+		t.Fatalf("spend credits: %v", err)
+	}
+
+	if _, err := RequeueTask(task.ID); !errors.Is(err, ErrTaskCreditsNeeded) {
+		t.Fatalf("err = %v, want credits needed", err)
+	}
+	if reloaded, _ := GetTaskByID(task.ID); reloaded.Status != models.TaskStatusCancelled {
+		t.Errorf("status = %s, want the task left cancelled", reloaded.Status)
+	}
+}
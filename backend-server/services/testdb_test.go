@@ -0,0 +1,59 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB loads the default configuration and points models.DB at a fresh SQLite database
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	if err := config.Load(); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	config.Cfg.AuthJWTSecret = "test-secret"
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := models.Migrate(db); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() {
+		models.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// createTestUser creates an active user with the given credits
+func createTestUser(t *testing.T, credits int) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		ID:      uuid.New().String(),
+		Credits: credits,
+		Status:  models.UserStatusActive,
+	}
+	if err := models.DB.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// wavSampleRate is the sample rate of the audio produced by IndexTTS
+const wavSampleRate = 22050
+
+// wavMaxSampleRate bounds the sample rate accepted from a WAV header, the worker allocates
+// pauses from it
+const wavMaxSampleRate = 384000
+
+// WAV format codes
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// encodeWAV encodes 16-bit mono PCM samples as a WAV file
+func encodeWAV(samples []int16, sampleRate int) []byte {
+	dataSize := len(samples) * 2
+
+	var buf bytes.Buffer
+	buf.Grow(44 + dataSize)
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))           // Chunk size
+	binary.Write(&buf, binary.LittleEndian, uint16(wavFormatPCM)) // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(1))            // Mono
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))   // Sample rate
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2)) // Byte rate
+	binary.Write(&buf, binary.LittleEndian, uint16(2))            // Block align
+	binary.Write(&buf, binary.LittleEndian, uint16(16))           // Bits per sample
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+// decodeWAV decodes a 16-bit PCM or 32-bit float WAV file into mono 16-bit samples,
+// averaging the channels of multi-channel audio
+func decodeWAV(data []byte) ([]int16, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, errors.New("not a WAV file")
+	}
+
+	var format, channels, bits uint16
+	var sampleRate uint32
+	var pcm []byte
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8 : min(pos+8+size, len(data))]
+
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, 0, errors.New("invalid WAV format chunk")
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			sampleRate = binary.LittleEndian.Uint32(body[4:8])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+			if format == wavFormatExtensible && len(body) >= 26 {
+				format = binary.LittleEndian.Uint16(body[24:26]) // First two bytes of the sub-format GUID
+			}
+		case "data":
+			pcm = body
+		}
+		pos += 8 + size + size%2 // Chunks are padded to an even size
+	}
+
+	if channels == 0 || pcm == nil {
+		return nil, 0, errors.New("WAV file has no audio data")
+	}
+	if sampleRate == 0 || sampleRate > wavMaxSampleRate {
+		return nil, 0, fmt.Errorf("invalid WAV sample rate %d", sampleRate)
+	}
+
+	var frameSize int
+	switch {
+	case format == wavFormatPCM && bits == 16:
+		frameSize = 2 * int(channels)
+	case format == wavFormatFloat && bits == 32:
+		frameSize = 4 * int(channels)
+	default:
+		return nil, 0, fmt.Errorf("unsupported WAV encoding (format %d, %d bits)", format, bits)
+	}
+	if len(pcm) < frameSize {
+		return nil, 0, errors.New("WAV file has no complete audio frame")
+	}
+
+	samples := make([]int16, len(pcm)/frameSize)
+	for i := range samples {
+		frame := pcm[i*frameSize : (i+1)*frameSize]
+		var sum float64
+		for ch := 0; ch < int(channels); ch++ {
+			if format == wavFormatPCM {
+				sum += float64(int16(binary.LittleEndian.Uint16(frame[ch*2:])))
+			} else {
+				v := float64(math.Float32frombits(binary.LittleEndian.Uint32(frame[ch*4:])))
+				sum += max(-1, min(v, 1)) * math.MaxInt16
+			}
+		}
+		samples[i] = int16(sum / float64(channels))
+	}
+	return samples, int(sampleRate), nil
+}
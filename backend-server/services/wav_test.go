@@ -0,0 +1,35 @@
+package services
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeWAV(t *testing.T) {
+	samples := []int16{0, 1000, -1000, 32767}
+	decoded, rate, err := decodeWAV(encodeWAV(samples, wavSampleRate))
+	if err != nil || rate != wavSampleRate || len(decoded) != len(samples) {
+		t.Fatalf("decoded %d samples at %d Hz (err %v), want %d at %d Hz", len(decoded), rate, err, len(samples), wavSampleRate)
+	}
+
+	// Header offsets of encodeWAV: sample rate at 24, data chunk size at 40
+	zeroRate := encodeWAV(samples, wavSampleRate)
+	binary.LittleEndian.PutUint32(zeroRate[24:], 0)
+	hugeRate := encodeWAV(samples, wavSampleRate)
+	binary.LittleEndian.PutUint32(hugeRate[24:], 1<<31)
+	partialFrame := encodeWAV(nil, wavSampleRate)
+	binary.LittleEndian.PutUint32(partialFrame[40:], 1)
+	partialFrame = append(partialFrame, 0)
+
+	for name, data := range map[string][]byte{
+		"zero sample rate": zeroRate,
+		"huge sample rate": hugeRate,
+		"partial frame":    partialFrame,
+		"empty data chunk": encodeWAV(nil, wavSampleRate),
+		"not a WAV file":   []byte("RIFF"),
+	} {
+		if _, _, err := decodeWAV(data); err == nil {
+			t.Errorf("%s: decoded without error", name)
+		}
+	}
+}
@@ -3,30 +3,140 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"backend-server/config"
 	"backend-server/models"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Worker processes TTS tasks in the background
 type Worker struct {
-	ctx    context.Context
-	cancel context.CancelFunc
+	ctx         context.Context
+	cancel      context.CancelFunc
+	client      InferenceClient
+	storage     ObjectStorage
+	concurrency int
+
+	mu           sync.Mutex
+	paused       bool
+	pausedAt     *time.Time
+	currentTasks map[string]bool
+}
+
+// WorkerStatus describes the runtime state of the worker
+type WorkerStatus struct {
+	Running      bool          `json:"running"`
+	Paused       bool          `json:"paused"`
+	PausedAt     *time.Time    `json:"paused_at,omitempty"`
+	Concurrency  int           `json:"concurrency"`
+	CurrentTasks []string      `json:"current_tasks"` // Tasks being processed right now
+	Circuit      CircuitStatus `json:"circuit"`       // Inference circuit breaker, tasks are not taken while it is open
 }
 
-// NewWorker creates a new worker
-func NewWorker() *Worker {
+// defaultWorker is the worker started by main, controlled through the admin API
+var defaultWorker *Worker
+
+// NewWorker creates a new worker that generates audio with the given client and keeps
+// reference and result audio in the given storage
+func NewWorker(client InferenceClient, storage ObjectStorage) *Worker {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Worker{
-		ctx:    ctx,
-		cancel: cancel,
+	w := &Worker{
+		ctx:          ctx,
+		cancel:       cancel,
+		client:       client,
+		storage:      storage,
+		concurrency:  max(config.Cfg.WorkerConcurrency, 1),
+		currentTasks: make(map[string]bool),
+	}
+	defaultWorker = w
+	return w
+}
+
+// GetWorker returns the worker created by NewWorker, or nil if there is none
+func GetWorker() *Worker {
+	return defaultWorker
+}
+
+// Pause stops the worker from picking up new tasks; tasks in progress are finished
+func (w *Worker) Pause() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.paused {
+		now := time.Now()
+		w.paused = true
+		w.pausedAt = &now
+		log.Println("Worker paused")
+	}
+}
+
+// Resume lets a paused worker pick up tasks again
+func (w *Worker) Resume() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.paused {
+		w.paused = false
+		w.pausedAt = nil
+		log.Println("Worker resumed")
+	}
+}
+
+// Status returns the runtime state of the worker
+func (w *Worker) Status() WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current := make([]string, 0, len(w.currentTasks))
+	for id := range w.currentTasks {
+		current = append(current, id)
+	}
+	sort.Strings(current)
+
+	return WorkerStatus{
+		Running:      w.ctx.Err() == nil,
+		Paused:       w.paused,
+		PausedAt:     w.pausedAt,
+		Concurrency:  w.concurrency,
+		CurrentTasks: current,
+		Circuit:      GetCircuitStatus(),
+	}
+}
+
+func (w *Worker) setCurrentTask(taskID string, running bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if running {
+		w.currentTasks[taskID] = true
+	} else {
+		delete(w.currentTasks, taskID)
 	}
 }
 
+// hasFreeSlot reports whether the worker is running fewer tasks than its concurrency allows
+func (w *Worker) hasFreeSlot() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.currentTasks) < w.concurrency
+}
+
+func (w *Worker) isPaused() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.paused
+}
+
 // Start begins processing tasks
 func (w *Worker) Start() {
 	go w.run()
@@ -49,141 +159,474 @@ func (w *Worker) run() {
 			log.Println("Worker stopped")
 			return
 		case <-ticker.C:
-			w.processNextTask()
+			promoteScheduledTasks()
+			w.dispatch()
 		}
 	}
 }
 
-func (w *Worker) processNextTask() {
-	// Find the next pending task
-	var task models.Task
-	result := models.DB.Where("status = ?", models.TaskStatusPending).
-		Order("created_at ASC").
-		First(&task)
+// dispatch starts tasks until every slot is busy or no task can be scheduled
+// Tasks stay queued while the inference client is not ready
+func (w *Worker) dispatch() {
+	for !w.isPaused() && w.hasFreeSlot() && w.client.Ready() {
+		task := claimNextTask()
+		if task == nil {
+			return
+		}
 
-	if result.Error != nil {
-		// No pending tasks
-		return
+		w.setCurrentTask(task.ID, true)
+		go func() {
+			defer w.setCurrentTask(task.ID, false)
+			defer func() {
+				// A bug triggered by one task must not take the server down
+				if r := recover(); r != nil {
+					log.Printf("Task %s panicked: %v\n%s", task.ID, r, debug.Stack())
+					failTask(task, "Internal error while processing the task")
+				}
+			}()
+			w.processTask(task)
+		}()
 	}
+}
+
+// claimNextTask picks the next task from the scheduler and marks it as processing
+func claimNextTask() *models.Task {
+	task, err := nextTask()
+	if err != nil {
+		log.Printf("Failed to schedule next task: %v", err)
+		return nil
+	}
+	if task == nil {
+		return nil
+	}
+
+	// Mark as processing, unless an administrator or another worker changed the task in the meantime
+	now := time.Now()
+	claimID := uuid.New().String()
+	claimed := models.DB.Model(task).
+		Where("status = ?", models.TaskStatusPending).
+		Updates(map[string]interface{}{
+			"status":     models.TaskStatusProcessing,
+			"started_at": now,
+			"claim_id":   claimID,
+		})
+	if claimed.Error != nil || claimed.RowsAffected == 0 {
+		return nil
+	}
+	task.Status = models.TaskStatusProcessing
+	task.StartedAt = &now
+	task.ClaimID = claimID
+	return task
+}
+
+// errTaskChanged stops a task that an administrator failed or re-queued while it was running
+var errTaskChanged = errors.New("task was changed while processing")
+
+// ttsInput is the text, voice and emotion of a task or script line
+type ttsInput struct {
+	Text                 string
+	ReferenceAudioFileID string
+	EmotionMode          models.EmotionMode
+	EmotionPromptFileID  string
+	EmotionVector        string
+	EmotionAlpha         *float64
+	EmotionText          string
+}
+
+// synthesisResult is the audio generated for a task
+type synthesisResult struct {
+	audio []byte
+	lines []models.TaskLine // Script lines with their timing
+}
+
+// resultBytesPerChar is a lower bound of the result size per character of text: 60 ms of audio,
+// faster than anyone speaks, so the estimate does not reject results that would fit
+const resultBytesPerChar = wavSampleRate * 2 * 60 / 1000
+
+// estimatedResultSize returns the smallest size the result of a task can be expected to have
+func estimatedResultSize(task *models.Task) int64 {
+	return int64(utf8.RuneCountInString(task.Text)) * resultBytesPerChar
+}
 
+func (w *Worker) processTask(task *models.Task) {
 	log.Printf("Processing task %s", task.ID)
 
-	// Mark as processing
-	models.DB.Model(&task).Update("status", models.TaskStatusProcessing)
+	// Do not spend inference time on a result that can not be stored
+	if err := CheckStorageQuota(task.UserID, estimatedResultSize(task)); err != nil {
+		log.Printf("Task %s failed storage quota check: %v", task.ID, err)
+		failTaskForQuota(task, err)
+		return
+	}
+
+	var result *synthesisResult
+	var err error
+	if task.Type == models.TaskTypeScript {
+		result, err = w.synthesizeScript(task)
+	} else {
+		result, err = w.synthesizeTask(task)
+	}
+	if errors.Is(err, errTaskChanged) {
+		log.Printf("Task %s was changed while processing, stopping", task.ID)
+		return
+	}
+	if requeueOnFailure(err) {
+		// The inference service went down after the task was claimed, wait for it to recover,
+		// unless it keeps failing on this task: then the task is probably the cause
+		attempts := task.FailedAttempts
+		var failure *backendError
+		if errors.As(err, &failure) {
+			attempts++
+		}
+		if limit := config.Cfg.InferenceTaskMaxAttempts; limit > 0 && attempts >= limit {
+			log.Printf("Task %s failed after %d attempts: %v", task.ID, attempts, err)
+			failTask(task, fmt.Sprintf("Inference failed %d times: %v", attempts, err))
+			return
+		}
+		log.Printf("Task %s returned to the queue: %v", task.ID, err)
+		unclaimTask(task, attempts)
+		return
+	}
+	if err != nil {
+		log.Printf("Task %s failed: %v", task.ID, err)
+		failTask(task, err.Error())
+		return
+	}
+
+	w.storeResult(task, result)
+}
+
+// synthesizeTask generates the audio of a single-voice task
+func (w *Worker) synthesizeTask(task *models.Task) (*synthesisResult, error) {
+	req, err := w.buildTTSRequest(task, &ttsInput{
+		Text:                 task.Text,
+		ReferenceAudioFileID: task.ReferenceAudioFileID,
+		EmotionMode:          task.EmotionMode,
+		EmotionPromptFileID:  task.EmotionPromptFileID,
+		EmotionVector:        task.EmotionVector,
+		EmotionAlpha:         task.EmotionAlpha,
+		EmotionText:          task.EmotionText,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	audioData, err := w.client.Synthesize(req)
+	if err != nil {
+		return nil, err
+	}
+	return &synthesisResult{audio: audioData}, nil
+}
+
+// synthesizeScript generates the lines of a script task one after another and joins them,
+// with the requested pause after each line, into one audio file
+func (w *Worker) synthesizeScript(task *models.Task) (*synthesisResult, error) {
+	lines, err := GetTaskLines(task.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load script lines: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("Script has no lines")
+	}
+
+	result := &synthesisResult{lines: lines}
+	var timeline []int16
+	sampleRate := 0
+	for i := range lines {
+		line := &lines[i]
+
+		// Stop early if the task was cancelled or re-queued between lines
+		if !isProcessing(task) {
+			return nil, errTaskChanged
+		}
+
+		audioData, err := w.lineAudio(task, line)
+		if errors.Is(err, errTaskChanged) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %w", i+1, err)
+		}
 
+		samples, rate, err := decodeWAV(audioData)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: invalid audio from inference service: %w", i+1, err)
+		}
+		if sampleRate == 0 {
+			sampleRate = rate
+		} else if rate != sampleRate {
+			return nil, fmt.Errorf("Line %d: sample rate %d differs from %d of the first line", i+1, rate, sampleRate)
+		}
+
+		startMs := int64(len(timeline)) * 1000 / int64(sampleRate)
+		timeline = append(timeline, samples...)
+		endMs := int64(len(timeline)) * 1000 / int64(sampleRate)
+		line.StartMs = &startMs
+		line.EndMs = &endMs
+
+		if i < len(lines)-1 && line.PauseAfterMs > 0 {
+			timeline = append(timeline, make([]int16, line.PauseAfterMs*sampleRate/1000)...)
+		}
+	}
+
+	result.audio = encodeWAV(timeline, sampleRate)
+	return result, nil
+}
+
+// lineAudio returns the audio of a script line: the stem stored by an earlier run of the task,
+// or newly synthesized audio, which is stored as the line's stem right away so that a task
+// re-queued halfway does not synthesize its finished lines again
+func (w *Worker) lineAudio(task *models.Task, line *models.TaskLine) ([]byte, error) {
+	if line.StemAudioFileID != "" {
+		data, err := w.loadFile(line.StemAudioFileID)
+		if err == nil {
+			return data, nil
+		}
+		log.Printf("Task %s: stored audio of line %d is unavailable, synthesizing it again: %v", task.ID, line.Position+1, err)
+	}
+
+	req, err := w.buildTTSRequest(task, &ttsInput{
+		Text:                 line.Text,
+		ReferenceAudioFileID: line.ReferenceAudioFileID,
+		EmotionMode:          line.EmotionMode,
+		EmotionPromptFileID:  line.EmotionPromptFileID,
+		EmotionVector:        line.EmotionVector,
+		EmotionAlpha:         line.EmotionAlpha,
+		EmotionText:          line.EmotionText,
+	})
+	if err != nil {
+		return nil, err
+	}
+	audioData, err := w.client.Synthesize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckStorageQuota(task.UserID, int64(len(audioData))); err != nil {
+		return nil, errors.New("Failed to store result: " + err.Error())
+	}
+	stemFile, err := w.storeResultFile(task, audioData, fmt.Sprintf("result_%s_line_%d.wav", task.ID, line.Position+1))
+	if err != nil {
+		return nil, err
+	}
+
+	// Attach the stem, unless another run of the re-queued task attached one in the meantime
+	attached := models.DB.Model(line).
+		Where("stem_audio_file_id = ?", line.StemAudioFileID).
+		Update("stem_audio_file_id", stemFile.ID)
+	if attached.Error != nil || attached.RowsAffected == 0 {
+		w.discardResultFiles([]*models.File{stemFile})
+		if attached.Error != nil {
+			return nil, errors.New("Failed to save line audio: " + attached.Error.Error())
+		}
+		return nil, errTaskChanged
+	}
+	line.StemAudioFileID = stemFile.ID
+	return audioData, nil
+}
+
+// loadFile downloads a stored file
+func (w *Worker) loadFile(fileID string) ([]byte, error) {
+	var file models.File
+	if err := models.DB.First(&file, "id = ?", fileID).Error; err != nil {
+		return nil, err
+	}
+	return w.storage.Download(file.OSSKey)
+}
+
+// whileClaimed scopes an update to a task that is still processing under this run's claim
+// An administrator may re-queue a stuck task while its first run is still going; once the task
+// is claimed again, the first run must neither complete nor fail it.
+func whileClaimed(task *models.Task) *gorm.DB {
+	return models.DB.Model(task).
+		Where("status = ? AND claim_id = ?", models.TaskStatusProcessing, task.ClaimID)
+}
+
+// isProcessing reports whether a task is still processing under this run's claim
+func isProcessing(task *models.Task) bool {
+	var count int64
+	models.DB.Model(&models.Task{}).
+		Where("id = ? AND status = ? AND claim_id = ?", task.ID, models.TaskStatusProcessing, task.ClaimID).
+		Count(&count)
+	return count > 0
+}
+
+// buildTTSRequest builds the inference request for a task or one of its script lines
+// Errors are meant as the failure message of the task
+func (w *Worker) buildTTSRequest(task *models.Task, in *ttsInput) (*TTSRequest, error) {
 	// Get signed URL for reference audio
 	var refFile models.File
-	if err := models.DB.First(&refFile, "id = ?", task.ReferenceAudioFileID).Error; err != nil {
-		log.Printf("Task %s failed: reference audio file not found", task.ID)
-		models.DB.Model(&task).Updates(map[string]interface{}{
-			"status":        models.TaskStatusFailed,
-			"error_message": "Reference audio file not found",
-		})
-		return
+	if err := models.DB.First(&refFile, "id = ?", in.ReferenceAudioFileID).Error; err != nil {
+		return nil, errors.New("Reference audio file not found")
 	}
 
-	refAudioURL, err := GetSignedURL(refFile.OSSKey, 3600)
+	refAudioURL, err := w.storage.SignedURL(refFile.OSSKey, 3600)
 	if err != nil {
-		log.Printf("Task %s failed to get signed URL for reference audio: %v", task.ID, err)
-		models.DB.Model(&task).Updates(map[string]interface{}{
-			"status":        models.TaskStatusFailed,
-			"error_message": "Failed to get signed URL for reference audio: " + err.Error(),
-		})
-		return
+		return nil, errors.New("Failed to get signed URL for reference audio: " + err.Error())
 	}
 
 	// Build inference request
 	req := &TTSRequest{
-		Text:           task.Text,
+		Text:           in.Text,
 		ReferenceAudio: refAudioURL,
 	}
 
 	// Set emotion parameters based on mode
-	switch task.EmotionMode {
+	switch in.EmotionMode {
 	case models.EmotionModeSameAsReference:
 		// No additional parameters needed
 	case models.EmotionModePrompt:
 		// Get signed URL for emotion prompt
 		var emotionFile models.File
-		if err := models.DB.First(&emotionFile, "id = ?", task.EmotionPromptFileID).Error; err != nil {
-			log.Printf("Task %s failed: emotion prompt file not found", task.ID)
-			models.DB.Model(&task).Updates(map[string]interface{}{
-				"status":        models.TaskStatusFailed,
-				"error_message": "Emotion prompt file not found",
-			})
-			return
+		if err := models.DB.First(&emotionFile, "id = ?", in.EmotionPromptFileID).Error; err != nil {
+			return nil, errors.New("Emotion prompt file not found")
 		}
-		emotionURL, err := GetSignedURL(emotionFile.OSSKey, 3600)
+		emotionURL, err := w.storage.SignedURL(emotionFile.OSSKey, 3600)
 		if err != nil {
-			log.Printf("Task %s failed to get signed URL for emotion prompt: %v", task.ID, err)
-			models.DB.Model(&task).Updates(map[string]interface{}{
-				"status":        models.TaskStatusFailed,
-				"error_message": "Failed to get signed URL for emotion prompt: " + err.Error(),
-			})
-			return
+			return nil, errors.New("Failed to get signed URL for emotion prompt: " + err.Error())
 		}
 		req.EmotionPrompt = emotionURL
 	case models.EmotionModeVector:
-		if task.EmotionVector != "" {
+		if in.EmotionVector != "" {
 			var vector []float64
-			if err := json.Unmarshal([]byte(task.EmotionVector), &vector); err == nil {
+			if err := json.Unmarshal([]byte(in.EmotionVector), &vector); err == nil {
 				req.EmotionVector = vector
 			}
 		}
 	case models.EmotionModeText:
 		useText := true
 		req.UseEmotionText = &useText
+	case models.EmotionModeDescription:
+		useText := true
+		req.UseEmotionText = &useText
+		req.EmotionText = in.EmotionText
 	}
 
-	if task.EmotionAlpha != nil {
-		req.EmotionAlpha = task.EmotionAlpha
+	if in.EmotionAlpha != nil {
+		req.EmotionAlpha = in.EmotionAlpha
 	}
+	req.Advanced = decodeAdvancedParams(task)
+	req.Retried = task.FailedAttempts > 0
+	return req, nil
+}
 
-	// Call inference API
-	audioData, err := CallInference(req)
-	if err != nil {
-		log.Printf("Task %s failed: %v", task.ID, err)
-		models.DB.Model(&task).Updates(map[string]interface{}{
-			"status":        models.TaskStatusFailed,
-			"error_message": err.Error(),
-		})
+// storeResult uploads the audio of a task and marks the task as completed
+// The audio of each script line was already stored while the lines were synthesized.
+func (w *Worker) storeResult(task *models.Task, result *synthesisResult) {
+	// Check storage quota before storing the result, the estimate checked before may be too low
+	if err := CheckStorageQuota(task.UserID, int64(len(result.audio))); err != nil {
+		log.Printf("Task %s failed storage quota check: %v", task.ID, err)
+		failTaskForQuota(task, err)
 		return
 	}
 
-	// Upload result to OSS (returns OSS key, not URL)
-	resultOSSKey, err := UploadBytes(audioData, "result.wav", "audio/wav")
+	resultFile, err := w.storeResultFile(task, result.audio, fmt.Sprintf("result_%s.wav", task.ID))
 	if err != nil {
-		log.Printf("Task %s failed to upload result: %v", task.ID, err)
-		models.DB.Model(&task).Updates(map[string]interface{}{
-			"status":        models.TaskStatusFailed,
-			"error_message": "Failed to upload result: " + err.Error(),
+		log.Printf("Task %s failed to store result: %v", task.ID, err)
+		failTask(task, err.Error())
+		return
+	}
+
+	// Mark as completed with file ID
+	completed := whileClaimed(task).
+		Updates(map[string]interface{}{
+			"status":               models.TaskStatusCompleted,
+			"result_audio_file_id": resultFile.ID,
+			"finished_at":          time.Now(),
 		})
+	if completed.Error == nil && completed.RowsAffected == 0 {
+		// An administrator failed or re-queued the task while it was running, maybe it runs again
+		log.Printf("Task %s was changed while processing, discarding result", task.ID)
+		w.discardResultFiles([]*models.File{resultFile})
 		return
 	}
 
+	for _, line := range result.lines {
+		models.DB.Model(&line).Updates(map[string]interface{}{
+			"start_ms": line.StartMs,
+			"end_ms":   line.EndMs,
+		})
+	}
+
+	recordProcessingTime(task, time.Since(*task.StartedAt))
+	log.Printf("Task %s completed successfully, result file: %s", task.ID, resultFile.ID)
+}
+
+// storeResultFile uploads generated audio and records it as a result file of the task's user
+// Errors are meant as the failure message of the task
+func (w *Worker) storeResultFile(task *models.Task, data []byte, filename string) (*models.File, error) {
+	// Upload result to storage (returns the object key, not URL)
+	ossKey, err := w.storage.Upload(data, "result.wav", "audio/wav")
+	if err != nil {
+		return nil, errors.New("Failed to upload result: " + err.Error())
+	}
+
 	// Create file record for the result audio (inherit user_id from task)
-	resultFile := models.File{
+	file := &models.File{
 		ID:          uuid.New().String(),
 		UserID:      task.UserID,
-		Filename:    fmt.Sprintf("result_%s.wav", task.ID),
-		OSSKey:      resultOSSKey,
+		Kind:        models.FileKindResult,
+		Filename:    filename,
+		OSSKey:      ossKey,
 		ContentType: "audio/wav",
-		Size:        int64(len(audioData)),
+		Size:        int64(len(data)),
 	}
-	if err := models.DB.Create(&resultFile).Error; err != nil {
-		log.Printf("Task %s failed to create file record: %v", task.ID, err)
-		models.DB.Model(&task).Updates(map[string]interface{}{
+	if err := models.DB.Create(file).Error; err != nil {
+		w.storage.Delete(ossKey)
+		return nil, errors.New("Failed to create file record: " + err.Error())
+	}
+	return file, nil
+}
+
+// discardResultFiles deletes result files of a task that did not complete
+func (w *Worker) discardResultFiles(files []*models.File) {
+	for _, file := range files {
+		w.storage.Delete(file.OSSKey)
+		models.DB.Unscoped().Delete(file)
+	}
+}
+
+// unclaimTask puts a task being processed back into the queue, recording how often the
+// inference service failed on it
+func unclaimTask(task *models.Task, failedAttempts int) {
+	whileClaimed(task).
+		Updates(map[string]interface{}{
+			"status":          models.TaskStatusPending,
+			"started_at":      nil,
+			"claim_id":        "",
+			"failed_attempts": failedAttempts,
+		})
+}
+
+// failTask marks a task being processed as failed
+// Tasks that are no longer processing under this run's claim, e.g. because an administrator
+// intervened, are left alone.
+// Script tasks are charged for all their lines up front, so their credits are refunded.
+// Returns whether the task was failed.
+func failTask(task *models.Task, message string) bool {
+	failed := whileClaimed(task).
+		Updates(map[string]interface{}{
 			"status":        models.TaskStatusFailed,
-			"error_message": "Failed to create file record: " + err.Error(),
+			"error_message": message,
+			"finished_at":   time.Now(),
 		})
-		return
+	if failed.Error != nil || failed.RowsAffected == 0 {
+		return false
 	}
+	if task.Type == models.TaskTypeScript {
+		if err := RefundTaskCredits(task, "Refund for failed script task"); err != nil {
+			log.Printf("Failed to refund script task %s: %v", task.ID, err)
+		}
+	}
+	return true
+}
 
-	// Mark as completed with file ID
-	models.DB.Model(&task).Updates(map[string]interface{}{
-		"status":               models.TaskStatusCompleted,
-		"result_audio_file_id": resultFile.ID,
-	})
-
-	log.Printf("Task %s completed successfully, result file: %s", task.ID, resultFile.ID)
+// failTaskForQuota fails a task whose result does not fit into the user's storage quota
+// The user never receives a result, so single tasks are refunded as well as scripts.
+func failTaskForQuota(task *models.Task, err error) {
+	if !failTask(task, "Failed to store result: "+err.Error()) || task.Type == models.TaskTypeScript {
+		return
+	}
+	if err := RefundTaskCredits(task, "Refund for task over storage quota"); err != nil {
+		log.Printf("Failed to refund task %s: %v", task.ID, err)
+	}
 }
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend-server/config"
+	"backend-server/models"
+
+	"github.com/google/uuid"
+)
+
+// memoryStorage keeps objects in memory instead of OSS
+type memoryStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{objects: make(map[string][]byte)}
+}
+
+func (s *memoryStorage) SignedURL(key string, expireSeconds int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[key]; !ok {
+		return "", fmt.Errorf("object %s not found", key)
+	}
+	return "memory://" + key, nil
+}
+
+func (s *memoryStorage) Upload(data []byte, filename string, contentType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := "results/" + uuid.New().String() + "/" + filename
+	s.objects[key] = data
+	return key, nil
+}
+
+func (s *memoryStorage) Download(key string) ([]byte, error) {
+	data, ok := s.get(key)
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	return data, nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memoryStorage) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+// setupTestWorker creates a worker with the fake client, without latency, and in-memory storage
+func setupTestWorker(t *testing.T) (*Worker, *FakeInferenceClient, *memoryStorage) {
+	t.Helper()
+	setupTestDB(t)
+
+	client := NewFakeInferenceClient()
+	client.SetLatency(0)
+	storage := newMemoryStorage()
+	previous := defaultWorker
+	w := NewWorker(client, storage)
+	t.Cleanup(func() { defaultWorker = previous })
+	return w, client, storage
+}
+
+// createReferenceFile stores a reference audio for a user
+func createReferenceFile(t *testing.T, storage *memoryStorage, userID string) *models.File {
+	t.Helper()
+
+	key := "reference/" + uuid.New().String() + ".wav"
+	storage.objects[key] = fakeTone(1)
+	file := &models.File{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Kind:        models.FileKindReference,
+		Filename:    "voice.wav",
+		OSSKey:      key,
+		ContentType: "audio/wav",
+		Size:        int64(len(storage.objects[key])),
+	}
+	if err := models.DB.Create(file).Error; err != nil {
+		t.Fatalf("create reference file: %v", err)
+	}
+	return file
+}
+
+// createPendingTask queues a single-voice task
+func createPendingTask(t *testing.T, userID, refFileID, text string) *models.Task {
+	t.Helper()
+
+	task := &models.Task{
+		ID:                   uuid.New().String(),
+		UserID:               userID,
+		Status:               models.TaskStatusPending,
+		Type:                 models.TaskTypeSingle,
+		Text:                 text,
+		ReferenceAudioFileID: refFileID,
+		EmotionMode:          models.EmotionModeSameAsReference,
+	}
+	if err := models.DB.Create(task).Error; err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return task
+}
+
+// runNextTask claims the next task and processes it synchronously
+func runNextTask(t *testing.T, w *Worker) *models.Task {
+	t.Helper()
+
+	task := claimNextTask()
+	if task == nil {
+		t.Fatal("no task claimed")
+	}
+	w.processTask(task)
+
+	var reloaded models.Task
+	if err := models.DB.First(&reloaded, "id = ?", task.ID).Error; err != nil {
+		t.Fatalf("reload task: %v", err)
+	}
+	return &reloaded
+}
+
+func TestWorkerCompletesTask(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	ref := createReferenceFile(t, storage, user.ID)
+	createPendingTask(t, user.ID, ref.ID, "hello")
+
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", task.Status, task.ErrorMessage)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d inference requests, want 1", len(requests))
+	}
+	if requests[0].Text != "hello" || requests[0].ReferenceAudio != "memory://"+ref.OSSKey {
+		t.Errorf("request = %+v, want the task text and the signed reference URL", requests[0])
+	}
+
+	var result models.File
+	if err := models.DB.First(&result, "id = ?", task.ResultAudioFileID).Error; err != nil {
+		t.Fatalf("load result file: %v", err)
+	}
+	data, ok := storage.get(result.OSSKey)
+	if !ok {
+		t.Fatal("result audio was not uploaded")
+	}
+	samples, _, err := decodeWAV(data)
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if want := 5 * fakeMsPerChar * wavSampleRate / 1000; len(samples) != want {
+		t.Errorf("result has %d samples, want %d", len(samples), want)
+	}
+	if result.Kind != models.FileKindResult || result.UserID != user.ID || result.Size != int64(len(data)) {
+		t.Errorf("result file = %+v", result)
+	}
+}
+
+func TestWorkerCompletesScript(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	alice := createReferenceFile(t, storage, user.ID)
+	bob := createReferenceFile(t, storage, user.ID)
+
+	_, err := CreateScriptTask(user.ID, ScriptTaskInput{
+		Speakers: map[string]string{"Alice": alice.ID, "Bob": bob.ID},
+		Lines: []ScriptLineInput{
+			{Speaker: "Alice", Text: "hi", PauseAfterMs: 300},
+			{Speaker: "Bob", Text: "hello"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create script: %v", err)
+	}
+
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", task.Status, task.ErrorMessage)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 2 ||
+		requests[0].ReferenceAudio != "memory://"+alice.OSSKey ||
+		requests[1].ReferenceAudio != "memory://"+bob.OSSKey {
+		t.Fatalf("requests = %+v, want one per line with the speaker's voice", requests)
+	}
+
+	lines, err := GetTaskLines(task.ID)
+	if err != nil {
+		t.Fatalf("load lines: %v", err)
+	}
+	want := [][2]int64{{0, 200}, {500, 1000}}
+	for i, line := range lines {
+		if line.StartMs == nil || line.EndMs == nil || *line.StartMs != want[i][0] || *line.EndMs != want[i][1] {
+			t.Errorf("line %d timing = %v-%v, want %v", i, line.StartMs, line.EndMs, want[i])
+		}
+		var stem models.File
+		if err := models.DB.First(&stem, "id = ?", line.StemAudioFileID).Error; err != nil {
+			t.Errorf("line %d has no stem: %v", i, err)
+			continue
+		}
+		if _, ok := storage.get(stem.OSSKey); !ok {
+			t.Errorf("line %d stem was not uploaded", i)
+		}
+	}
+
+	var result models.File
+	if err := models.DB.First(&result, "id = ?", task.ResultAudioFileID).Error; err != nil {
+		t.Fatalf("load result file: %v", err)
+	}
+	data, _ := storage.get(result.OSSKey)
+	samples, _, err := decodeWAV(data)
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if want := 1000 * wavSampleRate / 1000; len(samples) != want {
+		t.Errorf("result has %d samples, want %d", len(samples), want)
+	}
+}
+
+func TestWorkerRequeuesWithoutBackend(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	ref := createReferenceFile(t, storage, user.ID)
+	createPendingTask(t, user.ID, ref.ID, "hello")
+
+	client.SetError(ErrNoInferenceBackend, 0)
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusPending || task.StartedAt != nil {
+		t.Fatalf("status = %s, started_at = %v, want pending again", task.Status, task.StartedAt)
+	}
+
+	client.SetError(nil, 0)
+	task = runNextTask(t, w)
+	if task.Status != models.TaskStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed after the backend recovered", task.Status, task.ErrorMessage)
+	}
+}
+
+func TestWorkerChecksQuotaAndRefunds(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	config.Cfg.StorageQuotaMB = 1
+	user := createTestUser(t, 100)
+	ref := createReferenceFile(t, storage, user.ID)
+
+	// Leave room for the estimate of a five character result, but not for the audio itself
+	free := estimatedResultSize(&models.Task{Text: "hello"}) + 1000
+	models.DB.Model(ref).Update("size", 1024*1024-free)
+
+	tooLong := createPendingTask(t, user.ID, ref.ID, strings.Repeat("long text ", 10))
+	DeductCredits(user.ID, tooLong.ID)
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusFailed || len(client.Requests()) != 0 {
+		t.Fatalf("status = %s after %d requests, want failed before inference", task.Status, len(client.Requests()))
+	}
+
+	fits := createPendingTask(t, user.ID, ref.ID, "hello")
+	DeductCredits(user.ID, fits.ID)
+	task = runNextTask(t, w)
+	if task.Status != models.TaskStatusFailed || !strings.Contains(task.ErrorMessage, ErrStorageQuotaExceeded.Error()) {
+		t.Fatalf("status = %s (%s), want failed on the quota after inference", task.Status, task.ErrorMessage)
+	}
+
+	if credits, _ := GetUserCredits(user.ID); credits != 100 {
+		t.Errorf("credits = %d, want both tasks refunded", credits)
+	}
+}
+
+func TestWorkerStaleRunDoesNotFinishRequeuedTask(t *testing.T) {
+	w, _, storage := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	ref := createReferenceFile(t, storage, user.ID)
+	createPendingTask(t, user.ID, ref.ID, "hello")
+
+	// The first run hangs until an administrator re-queues the task as stuck and it is claimed again
+	first := claimNextTask()
+	models.DB.Model(first).Update("started_at", first.StartedAt.Add(-2*time.Hour))
+	if _, err := RequeueTask(first.ID); err != nil {
+		t.Fatalf("requeue stuck task: %v", err)
+	}
+	second := claimNextTask()
+	if second == nil || second.ClaimID == first.ClaimID {
+		t.Fatal("task was not claimed again with a new claim")
+	}
+
+	w.processTask(first)
+	failTask(first, "stale failure")
+	unclaimTask(first, 0)
+	reloaded, _ := GetTaskByID(first.ID)
+	if reloaded.Status != models.TaskStatusProcessing || reloaded.ResultAudioFileID != "" || reloaded.ClaimID != second.ClaimID {
+		t.Fatalf("status = %s, result %q: the stale run changed the task", reloaded.Status, reloaded.ResultAudioFileID)
+	}
+
+	w.processTask(second)
+	reloaded, _ = GetTaskByID(second.ID)
+	if reloaded.Status != models.TaskStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed by the second run", reloaded.Status, reloaded.ErrorMessage)
+	}
+	if len(storage.objects) != 2 {
+		t.Errorf("%d objects stored, want the reference and one result", len(storage.objects))
+	}
+}
+
+func TestWorkerFailsTaskAfterMaxAttempts(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	setTestBreaker(t, CircuitOpen)
+	config.Cfg.InferenceTaskMaxAttempts = 2
+	user := createTestUser(t, 100)
+	ref := createReferenceFile(t, storage, user.ID)
+	createPendingTask(t, user.ID, ref.ID, "hello")
+
+	// While the circuit is open the task goes back to the queue, but not forever
+	client.SetError(&backendError{errors.New("inference API returned status 503")}, 0)
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusPending || task.FailedAttempts != 1 {
+		t.Fatalf("status = %s after %d attempts, want pending after 1", task.Status, task.FailedAttempts)
+	}
+	if requests := client.Requests(); requests[len(requests)-1].Retried {
+		t.Error("first attempt marked as retried")
+	}
+
+	task = runNextTask(t, w)
+	if task.Status != models.TaskStatusFailed || !strings.Contains(task.ErrorMessage, "failed 2 times") {
+		t.Fatalf("status = %s (%s), want failed after 2 attempts", task.Status, task.ErrorMessage)
+	}
+	if requests := client.Requests(); !requests[len(requests)-1].Retried {
+		t.Error("second attempt not marked as retried")
+	}
+
+	// Re-queued by an administrator, the task gets a fresh set of attempts
+	if task, err := RequeueTask(task.ID); err != nil || task.FailedAttempts != 0 {
+		t.Fatalf("requeue: %v, %d attempts, want 0", err, task.FailedAttempts)
+	}
+}
+
+func TestWorkerFailsTask(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	ref := createReferenceFile(t, storage, user.ID)
+	createPendingTask(t, user.ID, ref.ID, "hello")
+
+	client.SetError(errors.New("text is not supported"), 0)
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusFailed || !strings.Contains(task.ErrorMessage, "text is not supported") {
+		t.Fatalf("status = %s (%s), want failed with the inference error", task.Status, task.ErrorMessage)
+	}
+	if task.ResultAudioFileID != "" || len(storage.objects) != 1 {
+		t.Errorf("failed task stored a result: %q, %d objects", task.ResultAudioFileID, len(storage.objects))
+	}
+}
+
+func TestWorkerFailsWithoutReferenceAudio(t *testing.T) {
+	w, client, _ := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	createPendingTask(t, user.ID, uuid.New().String(), "hello")
+
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusFailed || task.ErrorMessage != "Reference audio file not found" {
+		t.Fatalf("status = %s (%s), want failed for the missing reference", task.Status, task.ErrorMessage)
+	}
+	if len(client.Requests()) != 0 {
+		t.Error("inference was called without a reference audio")
+	}
+}
+
+// createTestScript queues a script of three lines spoken by two speakers
+func createTestScript(t *testing.T, storage *memoryStorage, userID string) *models.Task {
+	t.Helper()
+
+	alice := createReferenceFile(t, storage, userID)
+	bob := createReferenceFile(t, storage, userID)
+	task, err := CreateScriptTask(userID, ScriptTaskInput{
+		Speakers: map[string]string{"Alice": alice.ID, "Bob": bob.ID},
+		Lines: []ScriptLineInput{
+			{Speaker: "Alice", Text: "one"},
+			{Speaker: "Bob", Text: "two"},
+			{Speaker: "Alice", Text: "three"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create script: %v", err)
+	}
+	return task
+}
+
+func TestWorkerResumesScript(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	createTestScript(t, storage, user.ID)
+
+	// The backend goes away after the first line
+	client.SetError(ErrNoInferenceBackend, 2)
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusPending {
+		t.Fatalf("status = %s (%s), want pending", task.Status, task.ErrorMessage)
+	}
+	lines, _ := GetTaskLines(task.ID)
+	if lines[0].StemAudioFileID == "" || lines[1].StemAudioFileID != "" {
+		t.Fatalf("stems = %q, %q, want only the first line stored", lines[0].StemAudioFileID, lines[1].StemAudioFileID)
+	}
+	firstStem := lines[0].StemAudioFileID
+
+	client.SetError(nil, 0)
+	task = runNextTask(t, w)
+	if task.Status != models.TaskStatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", task.Status, task.ErrorMessage)
+	}
+
+	var texts []string
+	for _, req := range client.Requests() {
+		texts = append(texts, req.Text)
+	}
+	if got := strings.Join(texts, ","); got != "one,two,two,three" {
+		t.Errorf("synthesized %s, want the first line only once", got)
+	}
+	lines, _ = GetTaskLines(task.ID)
+	if lines[0].StemAudioFileID != firstStem {
+		t.Errorf("first line stem changed from %s to %s", firstStem, lines[0].StemAudioFileID)
+	}
+	for i, line := range lines {
+		if line.StemAudioFileID == "" || line.StartMs == nil || line.EndMs == nil {
+			t.Errorf("line %d incomplete: %+v", i, line)
+		}
+	}
+	if credits, _ := GetUserCredits(user.ID); credits != 100-ScriptCredits(3) {
+		t.Errorf("credits = %d, want the script charged once", credits)
+	}
+}
+
+func TestWorkerRefundsFailedScript(t *testing.T) {
+	w, client, storage := setupTestWorker(t)
+	user := createTestUser(t, 100)
+	createTestScript(t, storage, user.ID)
+	if credits, _ := GetUserCredits(user.ID); credits != 100-ScriptCredits(3) {
+		t.Fatalf("credits = %d, want the script charged", credits)
+	}
+
+	client.SetError(errors.New("text is not supported"), 3)
+	task := runNextTask(t, w)
+	if task.Status != models.TaskStatusFailed {
+		t.Fatalf("status = %s, want failed", task.Status)
+	}
+	if credits, _ := GetUserCredits(user.ID); credits != 100 {
+		t.Errorf("credits = %d, want the failed script refunded", credits)
+	}
+}
@@ -2,15 +2,59 @@
 import React, { useState, useEffect } from 'react';
 import VoiceStudio from './components/VoiceStudio';
 import Auth from './components/Auth';
-import { User, getCachedUser, getCurrentUser, logout, isAuthenticated } from './services/api';
+import { User, getCachedUser, getCurrentUser, logout, clearAuth, isAuthenticated, refreshAccessToken, verifyEmailToken, exchangeOAuthCode } from './services/api';
+
+// 访问令牌有效期较短，定期刷新以保证直接使用 token 的请求可用
+const TOKEN_REFRESH_INTERVAL_MS = 10 * 60 * 1000;
 
 const App: React.FC = () => {
   const [user, setUser] = useState<User | null>(null);
   const [loading, setLoading] = useState(true);
+  const [resetToken, setResetToken] = useState<string | null>(null);
+  const [linkError, setLinkError] = useState<string | null>(null);
 
   // 初始化时检查登录状态
   useEffect(() => {
     const checkAuth = async () => {
+      const params = new URLSearchParams(window.location.search);
+
+      // 处理第三方登录回调
+      const oauthCode = params.get('oauth_code');
+      const oauthError = params.get('oauth_error');
+      if (oauthCode || oauthError || params.has('oauth_linked')) {
+        window.history.replaceState(null, '', window.location.pathname);
+        if (oauthError) {
+          setLinkError(oauthError);
+        } else if (oauthCode) {
+          try {
+            const response = await exchangeOAuthCode(oauthCode);
+            setUser(response.user);
+            setLoading(false);
+            return;
+          } catch (err) {
+            setLinkError(err instanceof Error ? err.message : '第三方登录失败');
+          }
+        }
+      }
+
+      // 处理邮件链接: 重置密码交给登录页, 其余 (注册验证/绑定/免密登录) 直接换取登录态
+      const emailToken = params.get('email_token');
+      if (emailToken) {
+        window.history.replaceState(null, '', window.location.pathname);
+        if (params.get('purpose') === 'password_reset') {
+          setResetToken(emailToken);
+        } else {
+          try {
+            const response = await verifyEmailToken(emailToken);
+            setUser(response.user);
+            setLoading(false);
+            return;
+          } catch (err) {
+            setLinkError(err instanceof Error ? err.message : '链接无效或已过期');
+          }
+        }
+      }
+
       if (isAuthenticated()) {
         // 尝试从缓存获取用户信息
         const cachedUser = getCachedUser();
@@ -24,7 +68,7 @@ const App: React.FC = () => {
           setUser(currentUser);
         } catch {
           // token 失效，清除登录状态
-          logout();
+          clearAuth();
           setUser(null);
         }
       }
@@ -34,12 +78,25 @@ const App: React.FC = () => {
     checkAuth();
   }, []);
 
+  // 登录状态下定期刷新访问令牌
+  useEffect(() => {
+    if (!user) {
+      return;
+    }
+    const timer = setInterval(async () => {
+      if (!(await refreshAccessToken()) && !isAuthenticated()) {
+        setUser(null);
+      }
+    }, TOKEN_REFRESH_INTERVAL_MS);
+    return () => clearInterval(timer);
+  }, [user]);
+
   const handleLoginSuccess = (loggedInUser: User) => {
     setUser(loggedInUser);
   };
 
-  const handleLogout = () => {
-    logout();
+  const handleLogout = async () => {
+    await logout();
     setUser(null);
   };
 
@@ -65,7 +122,7 @@ const App: React.FC = () => {
           <div className="absolute top-4 right-4 flex items-center gap-4">
             <span className="text-gray-400 text-sm">
               <i className="fas fa-user-circle mr-2"></i>
-              {user.nickname || user.phone}
+              {user.nickname || user.phone || user.email}
             </span>
             <button
               onClick={handleLogout}
@@ -91,7 +148,14 @@ const App: React.FC = () => {
         {user ? (
           <VoiceStudio user={user} onUserUpdate={setUser} />
         ) : (
-          <Auth onLoginSuccess={handleLoginSuccess} />
+          <>
+            {linkError && (
+              <div className="max-w-md mx-auto mb-4 p-3 bg-red-900/30 border border-red-500/30 rounded-xl text-red-400 text-sm text-center">
+                {linkError}
+              </div>
+            )}
+            <Auth onLoginSuccess={handleLoginSuccess} resetToken={resetToken} onResetDone={() => setResetToken(null)} />
+          </>
         )}
       </main>
 
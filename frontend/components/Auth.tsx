@@ -1,13 +1,45 @@
 
 import React, { useState, useEffect } from 'react';
-import { sendVerificationCode, login, User } from '../services/api';
+import {
+  sendVerificationCode, login, loginWithEmail, registerWithEmail, sendMagicLink, forgotPassword, resetPassword, User,
+  OAuthProvider, getOAuthProviders, getOAuthStartURL,
+} from '../services/api';
+
+// 常用国际区号
+const COUNTRY_CODES = [
+  { code: '86', label: '中国大陆 +86' },
+  { code: '852', label: '中国香港 +852' },
+  { code: '853', label: '中国澳门 +853' },
+  { code: '886', label: '中国台湾 +886' },
+  { code: '1', label: '美国/加拿大 +1' },
+  { code: '44', label: '英国 +44' },
+  { code: '61', label: '澳大利亚 +61' },
+  { code: '65', label: '新加坡 +65' },
+  { code: '60', label: '马来西亚 +60' },
+  { code: '81', label: '日本 +81' },
+  { code: '82', label: '韩国 +82' },
+];
 
 interface AuthProps {
   onLoginSuccess: (user: User) => void;
+  resetToken?: string | null; // 来自重置密码邮件链接
+  onResetDone?: () => void;
 }
 
-const Auth: React.FC<AuthProps> = ({ onLoginSuccess }) => {
+const inputClass = 'w-full bg-black/40 border border-gray-800 rounded-xl py-3.5 pl-11 pr-4 text-white focus:ring-1 focus:ring-red-500 focus:border-transparent outline-none transition-all placeholder:text-gray-700';
+
+const Auth: React.FC<AuthProps> = ({ onLoginSuccess, resetToken, onResetDone }) => {
   const [isLogin, setIsLogin] = useState(true);
+  const [method, setMethod] = useState<'phone' | 'email'>('phone');
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const [notice, setNotice] = useState<string | null>(null);
+  const [oauthProviders, setOAuthProviders] = useState<OAuthProvider[]>([]);
+
+  useEffect(() => {
+    getOAuthProviders().then(setOAuthProviders).catch(() => setOAuthProviders([]));
+  }, []);
+  const [countryCode, setCountryCode] = useState('86');
   const [phone, setPhone] = useState('');
   const [code, setCode] = useState('');
   const [countdown, setCountdown] = useState(0);
@@ -26,8 +58,8 @@ const Auth: React.FC<AuthProps> = ({ onLoginSuccess }) => {
   }, [countdown]);
 
   const handleSendCode = async () => {
-    if (!/^1[3-9]\d{9}$/.test(phone)) {
-      setError('请输入正确的11位手机号码');
+    if (countryCode === '86' ? !/^1[3-9]\d{9}$/.test(phone) : !/^\d{4,14}$/.test(phone)) {
+      setError(countryCode === '86' ? '请输入正确的11位手机号码' : '请输入正确的手机号码');
       return;
     }
 
@@ -35,7 +67,7 @@ const Auth: React.FC<AuthProps> = ({ onLoginSuccess }) => {
     setSendingCode(true);
 
     try {
-      await sendVerificationCode(phone);
+      await sendVerificationCode(phone, countryCode);
       setCountdown(60);
     } catch (err) {
       setError(err instanceof Error ? err.message : '发送验证码失败');
@@ -60,7 +92,7 @@ const Auth: React.FC<AuthProps> = ({ onLoginSuccess }) => {
     setLoading(true);
 
     try {
-      const response = await login(phone, code);
+      const response = await login(phone, code, countryCode);
       onLoginSuccess(response.user);
     } catch (err) {
       setError(err instanceof Error ? err.message : '登录失败');
@@ -69,6 +101,82 @@ const Auth: React.FC<AuthProps> = ({ onLoginSuccess }) => {
     }
   };
 
+  const handleEmailSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+
+    if (!email || !password) {
+      setError('请填写完整信息');
+      return;
+    }
+
+    setError(null);
+    setNotice(null);
+    setLoading(true);
+
+    try {
+      if (isLogin) {
+        const response = await loginWithEmail(email, password);
+        onLoginSuccess(response.user);
+      } else {
+        await registerWithEmail(email, password);
+        setNotice('验证邮件已发送，请点击邮件中的链接完成注册');
+      }
+    } catch (err) {
+      setError(err instanceof Error ? err.message : isLogin ? '登录失败' : '注册失败');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  // 发送免密登录链接或重置密码邮件
+  const handleEmailLink = async (kind: 'magic' | 'reset') => {
+    if (!email) {
+      setError('请输入邮箱地址');
+      return;
+    }
+
+    setError(null);
+    setNotice(null);
+    setLoading(true);
+
+    try {
+      if (kind === 'magic') {
+        await sendMagicLink(email);
+        setNotice('登录链接已发送，请查收邮件');
+      } else {
+        await forgotPassword(email);
+        setNotice('如果该邮箱已注册，重置密码邮件已发送');
+      }
+    } catch (err) {
+      setError(err instanceof Error ? err.message : '发送邮件失败');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleResetSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!resetToken || !password) {
+      setError('请输入新密码');
+      return;
+    }
+
+    setError(null);
+    setLoading(true);
+
+    try {
+      await resetPassword(resetToken, password);
+      setPassword('');
+      setMethod('email');
+      setNotice('密码已重置，请使用新密码登录');
+      onResetDone?.();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : '重置密码失败');
+    } finally {
+      setLoading(false);
+    }
+  };
+
   return (
     <div className="max-w-md mx-auto mt-10 animate-in fade-in zoom-in duration-500">
       <div className="glass-morphism rounded-3xl p-10 shadow-2xl border border-red-500/10 relative overflow-hidden bg-black/40">
@@ -84,82 +192,217 @@ const Auth: React.FC<AuthProps> = ({ onLoginSuccess }) => {
             <h2 className="text-3xl font-extrabold text-white mb-2">
               {isLogin ? '欢迎回来' : '开启克隆之旅'}
             </h2>
-            <p className="text-gray-400 text-sm font-light">使用手机号码快速{isLogin ? '登录' : '注册'}</p>
+            <p className="text-gray-400 text-sm font-light">
+              使用{method === 'phone' ? '手机号码' : '邮箱'}快速{isLogin ? '登录' : '注册'}
+            </p>
           </div>
 
+          {!resetToken && (
+            <div className="flex mb-6 bg-black/40 border border-gray-800 rounded-xl p-1">
+              {(['phone', 'email'] as const).map((m) => (
+                <button
+                  key={m}
+                  type="button"
+                  onClick={() => { setMethod(m); setError(null); setNotice(null); }}
+                  className={`flex-1 py-2 rounded-lg text-sm transition-all ${method === m ? 'bg-red-600/20 text-red-400' : 'text-gray-500 hover:text-gray-300'}`}
+                >
+                  {m === 'phone' ? '手机号' : '邮箱'}
+                </button>
+              ))}
+            </div>
+          )}
+
           {/* 错误提示 */}
           {error && (
             <div className="mb-6 p-3 bg-red-900/30 border border-red-500/30 rounded-xl text-red-400 text-sm text-center">
               {error}
             </div>
           )}
+          {notice && (
+            <div className="mb-6 p-3 bg-emerald-900/20 border border-emerald-500/30 rounded-xl text-emerald-400 text-sm text-center">
+              {notice}
+            </div>
+          )}
 
-          <form onSubmit={handleSubmit} className="space-y-6">
-            <div className="space-y-2">
-              <label className="text-xs font-medium text-gray-500 ml-1 uppercase tracking-widest">手机号码</label>
-              <div className="relative">
-                <span className="absolute left-4 top-1/2 -translate-y-1/2 text-gray-600">
-                  <i className="fas fa-mobile-alt"></i>
-                </span>
-                <input
-                  type="tel"
-                  placeholder="请输入手机号"
-                  className="w-full bg-black/40 border border-gray-800 rounded-xl py-3.5 pl-11 pr-4 text-white focus:ring-1 focus:ring-red-500 focus:border-transparent outline-none transition-all placeholder:text-gray-700"
-                  value={phone}
-                  onChange={(e) => setPhone(e.target.value)}
-                  maxLength={11}
-                />
+          {resetToken ? (
+            <form onSubmit={handleResetSubmit} className="space-y-6">
+              <div className="space-y-2">
+                <label className="text-xs font-medium text-gray-500 ml-1 uppercase tracking-widest">新密码</label>
+                <div className="relative">
+                  <span className="absolute left-4 top-1/2 -translate-y-1/2 text-gray-600">
+                    <i className="fas fa-lock"></i>
+                  </span>
+                  <input
+                    type="password"
+                    placeholder="至少8位"
+                    className={inputClass}
+                    value={password}
+                    onChange={(e) => setPassword(e.target.value)}
+                  />
+                </div>
+              </div>
+              <button
+                type="submit"
+                disabled={loading}
+                className="w-full py-4 bg-gradient-to-r from-red-600 to-rose-700 hover:from-red-500 hover:to-rose-600 text-white rounded-xl font-bold text-lg shadow-xl shadow-red-900/30 transition-all active:scale-[0.98] flex items-center justify-center gap-3"
+              >
+                {loading ? <i className="fas fa-spinner fa-spin"></i> : '设置新密码'}
+              </button>
+            </form>
+          ) : method === 'email' ? (
+            <form onSubmit={handleEmailSubmit} className="space-y-6">
+              <div className="space-y-2">
+                <label className="text-xs font-medium text-gray-500 ml-1 uppercase tracking-widest">邮箱</label>
+                <div className="relative">
+                  <span className="absolute left-4 top-1/2 -translate-y-1/2 text-gray-600">
+                    <i className="fas fa-envelope"></i>
+                  </span>
+                  <input
+                    type="email"
+                    placeholder="请输入邮箱"
+                    className={inputClass}
+                    value={email}
+                    onChange={(e) => setEmail(e.target.value)}
+                  />
+                </div>
               </div>
-            </div>
 
-            <div className="space-y-2">
-              <label className="text-xs font-medium text-gray-500 ml-1 uppercase tracking-widest">验证码</label>
-              <div className="flex gap-3">
-                <div className="relative flex-1">
+              <div className="space-y-2">
+                <label className="text-xs font-medium text-gray-500 ml-1 uppercase tracking-widest">密码</label>
+                <div className="relative">
                   <span className="absolute left-4 top-1/2 -translate-y-1/2 text-gray-600">
-                    <i className="fas fa-shield-alt"></i>
+                    <i className="fas fa-lock"></i>
                   </span>
                   <input
-                    type="text"
-                    placeholder="6位验证码"
-                    className="w-full bg-black/40 border border-gray-800 rounded-xl py-3.5 pl-11 pr-4 text-white focus:ring-1 focus:ring-red-500 focus:border-transparent outline-none transition-all placeholder:text-gray-700"
-                    value={code}
-                    onChange={(e) => setCode(e.target.value.replace(/\D/g, ''))}
-                    maxLength={6}
+                    type="password"
+                    placeholder={isLogin ? '请输入密码' : '至少8位'}
+                    className={inputClass}
+                    value={password}
+                    onChange={(e) => setPassword(e.target.value)}
                   />
                 </div>
-                <button
-                  type="button"
-                  disabled={countdown > 0 || sendingCode}
-                  onClick={handleSendCode}
-                  className={`px-4 rounded-xl font-medium text-xs transition-all whitespace-nowrap min-w-[110px] flex items-center justify-center
-                    ${countdown > 0 || sendingCode
-                      ? 'bg-gray-900 text-gray-600 cursor-not-allowed border border-gray-800'
-                      : 'bg-white/5 text-red-400 border border-red-500/20 hover:bg-red-500/10'}`}
-                >
-                  {sendingCode ? (
-                    <i className="fas fa-spinner fa-spin"></i>
-                  ) : countdown > 0 ? (
-                    `${countdown}s`
-                  ) : (
-                    '获取验证码'
-                  )}
-                </button>
               </div>
-            </div>
 
-            <button
-              type="submit"
-              disabled={loading}
-              className="w-full py-4 bg-gradient-to-r from-red-600 to-rose-700 hover:from-red-500 hover:to-rose-600 text-white rounded-xl font-bold text-lg shadow-xl shadow-red-900/30 transition-all active:scale-[0.98] flex items-center justify-center gap-3"
-            >
-              {loading ? (
-                <i className="fas fa-spinner fa-spin"></i>
-              ) : (
-                isLogin ? '立即登录' : '立即注册'
+              <button
+                type="submit"
+                disabled={loading}
+                className="w-full py-4 bg-gradient-to-r from-red-600 to-rose-700 hover:from-red-500 hover:to-rose-600 text-white rounded-xl font-bold text-lg shadow-xl shadow-red-900/30 transition-all active:scale-[0.98] flex items-center justify-center gap-3"
+              >
+                {loading ? <i className="fas fa-spinner fa-spin"></i> : isLogin ? '立即登录' : '立即注册'}
+              </button>
+
+              {isLogin && (
+                <div className="flex justify-between text-xs">
+                  <button type="button" onClick={() => handleEmailLink('magic')} className="text-gray-500 hover:text-red-400 transition-colors">
+                    发送免密登录链接
+                  </button>
+                  <button type="button" onClick={() => handleEmailLink('reset')} className="text-gray-500 hover:text-red-400 transition-colors">
+                    忘记密码？
+                  </button>
+                </div>
               )}
-            </button>
-          </form>
+            </form>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-6">
+              <div className="space-y-2">
+                <label className="text-xs font-medium text-gray-500 ml-1 uppercase tracking-widest">手机号码</label>
+                <div className="flex gap-3">
+                  <select
+                    className="bg-black/40 border border-gray-800 rounded-xl px-3 text-white text-sm focus:ring-1 focus:ring-red-500 outline-none"
+                    value={countryCode}
+                    onChange={(e) => setCountryCode(e.target.value)}
+                  >
+                    {COUNTRY_CODES.map((c) => (
+                      <option key={c.code} value={c.code}>{c.label}</option>
+                    ))}
+                  </select>
+                  <div className="relative flex-1">
+                    <span className="absolute left-4 top-1/2 -translate-y-1/2 text-gray-600">
+                      <i className="fas fa-mobile-alt"></i>
+                    </span>
+                    <input
+                      type="tel"
+                      placeholder="请输入手机号"
+                      className="w-full bg-black/40 border border-gray-800 rounded-xl py-3.5 pl-11 pr-4 text-white focus:ring-1 focus:ring-red-500 focus:border-transparent outline-none transition-all placeholder:text-gray-700"
+                      value={phone}
+                      onChange={(e) => setPhone(e.target.value.replace(/\D/g, ''))}
+                      maxLength={countryCode === '86' ? 11 : 14}
+                    />
+                  </div>
+                </div>
+              </div>
+
+              <div className="space-y-2">
+                <label className="text-xs font-medium text-gray-500 ml-1 uppercase tracking-widest">验证码</label>
+                <div className="flex gap-3">
+                  <div className="relative flex-1">
+                    <span className="absolute left-4 top-1/2 -translate-y-1/2 text-gray-600">
+                      <i className="fas fa-shield-alt"></i>
+                    </span>
+                    <input
+                      type="text"
+                      placeholder="6位验证码"
+                      className="w-full bg-black/40 border border-gray-800 rounded-xl py-3.5 pl-11 pr-4 text-white focus:ring-1 focus:ring-red-500 focus:border-transparent outline-none transition-all placeholder:text-gray-700"
+                      value={code}
+                      onChange={(e) => setCode(e.target.value.replace(/\D/g, ''))}
+                      maxLength={6}
+                    />
+                  </div>
+                  <button
+                    type="button"
+                    disabled={countdown > 0 || sendingCode}
+                    onClick={handleSendCode}
+                    className={`px-4 rounded-xl font-medium text-xs transition-all whitespace-nowrap min-w-[110px] flex items-center justify-center
+                      ${countdown > 0 || sendingCode
+                        ? 'bg-gray-900 text-gray-600 cursor-not-allowed border border-gray-800'
+                        : 'bg-white/5 text-red-400 border border-red-500/20 hover:bg-red-500/10'}`}
+                  >
+                    {sendingCode ? (
+                      <i className="fas fa-spinner fa-spin"></i>
+                    ) : countdown > 0 ? (
+                      `${countdown}s`
+                    ) : (
+                      '获取验证码'
+                    )}
+                  </button>
+                </div>
+              </div>
+
+              <button
+                type="submit"
+                disabled={loading}
+                className="w-full py-4 bg-gradient-to-r from-red-600 to-rose-700 hover:from-red-500 hover:to-rose-600 text-white rounded-xl font-bold text-lg shadow-xl shadow-red-900/30 transition-all active:scale-[0.98] flex items-center justify-center gap-3"
+              >
+                {loading ? (
+                  <i className="fas fa-spinner fa-spin"></i>
+                ) : (
+                  isLogin ? '立即登录' : '立即注册'
+                )}
+              </button>
+            </form>
+          )}
+
+          {!resetToken && oauthProviders.length > 0 && (
+            <div className="mt-8">
+              <div className="flex items-center gap-3 text-gray-600 text-xs mb-4">
+                <div className="flex-1 h-px bg-gray-800"></div>
+                其他登录方式
+                <div className="flex-1 h-px bg-gray-800"></div>
+              </div>
+              <div className="flex gap-3">
+                {oauthProviders.map((p) => (
+                  <a
+                    key={p.name}
+                    href={getOAuthStartURL(p.name)}
+                    className="flex-1 py-3 rounded-xl text-sm text-center text-gray-300 bg-white/5 border border-gray-800 hover:border-red-500/30 hover:text-red-400 transition-all"
+                  >
+                    <i className={`fab ${p.name === 'wechat' ? 'fa-weixin' : 'fa-openid'} mr-2`}></i>
+                    {p.display_name}
+                  </a>
+                ))}
+              </div>
+            </div>
+          )}
 
           <div className="mt-8 text-center">
             <button
@@ -1,9 +1,9 @@
 
 import React, { useState, useRef, useEffect, useCallback } from 'react';
-import { VoiceProject, EmotionType, EmotionVectors, CloneTask, emotionTypeToMode, TaskListItem, BackendTaskStatus } from '../types';
+import { VoiceProject, EmotionType, EmotionVectors, CloneTask, TaskStatus, emotionTypeToMode, TaskListItem, BackendTaskStatus, QueueStats } from '../types';
 import { fileToBase64 } from '../services/audioUtils';
 import { uploadAudioFile } from '../services/fileService';
-import { createTask, getTasks, pollTaskUntilDone } from '../services/taskService';
+import { createTask, getTasks, getQueueStats, pollTaskUntilDone } from '../services/taskService';
 import { getAudioBlobUrl } from '../services/fileService';
 import { User, getCurrentUser } from '../services/api';
 import TaskList from './TaskList';
@@ -30,6 +30,19 @@ const emotionLabels: Record<keyof EmotionVectors, string> = {
   calm: '平静',
 };
 
+// 后端任务状态映射到界面状态：定时、排队中的任务显示为处理中，已取消显示为失败
+const toCloneStatus = (status: BackendTaskStatus): TaskStatus => {
+  switch (status) {
+    case 'completed':
+      return 'completed';
+    case 'failed':
+    case 'cancelled':
+      return 'failed';
+    default:
+      return 'processing';
+  }
+};
+
 interface ToastState {
   type: 'success' | 'error';
   message: string;
@@ -47,6 +60,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
     emotionType: EmotionType.SAME_AS_VOICE,
     emotionVectors: { ...initialVectors },
     emotionReference: null,
+    emotionText: '',
     emotionAlpha: 0.8
   });
 
@@ -60,6 +74,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
   const [isUserMenuOpen, setIsUserMenuOpen] = useState(false);
   const [tasksPage, setTasksPage] = useState(1);
   const [tasksTotal, setTasksTotal] = useState(0);
+  const [queueStats, setQueueStats] = useState<QueueStats | null>(null);
 
   const menuRef = useRef<HTMLDivElement>(null);
   const voiceInputRef = useRef<HTMLInputElement>(null);
@@ -85,6 +100,14 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
     }
   }, [toast]);
 
+  // 定时刷新队列状态
+  useEffect(() => {
+    const refresh = () => getQueueStats().then(setQueueStats).catch(() => setQueueStats(null));
+    refresh();
+    const timer = setInterval(refresh, 30000);
+    return () => clearInterval(timer);
+  }, []);
+
   // 从后端加载任务列表
   const loadTasks = useCallback(async () => {
     try {
@@ -107,7 +130,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
 
           return {
             id: task.id,
-            status: task.status === 'pending' ? 'processing' : task.status,
+            status: toCloneStatus(task.status),
             script: task.text,
             audioUrl,
             createdAt: new Date(task.created_at).getTime(),
@@ -208,6 +231,11 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
       return;
     }
 
+    if (project.emotionType === EmotionType.DESCRIPTION && !project.emotionText.trim()) {
+      setToast({ type: 'error', message: "请输入情感描述" });
+      return;
+    }
+
     if (user.credits < 1) {
       setToast({ type: 'error', message: "余额不足，请先充值" });
       return;
@@ -223,7 +251,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
       // 2. 如果需要，上传情感参考音频
       let emotionPromptFileId: string | undefined;
       if (project.emotionType === EmotionType.REFERENCE_AUDIO && emotionReferenceFile) {
-        const emotionUploadResult = await uploadAudioFile(emotionReferenceFile);
+        const emotionUploadResult = await uploadAudioFile(emotionReferenceFile, 'emotion_prompt');
         emotionPromptFileId = emotionUploadResult.id;
       }
 
@@ -251,6 +279,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
         emotion_prompt_file_id: emotionPromptFileId,
         emotion_vector: emotionVector,
         emotion_alpha: project.emotionAlpha,
+        emotion_text: project.emotionType === EmotionType.DESCRIPTION ? project.emotionText.trim() : undefined,
       });
 
       // 5. 添加任务到列表（处理中状态）
@@ -272,7 +301,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
           setTasks(prev =>
             prev.map(t =>
               t.id === createResult.id
-                ? { ...t, status: status === 'pending' ? 'processing' : status }
+                ? { ...t, status: toCloneStatus(status) }
                 : t
             )
           );
@@ -299,7 +328,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
         setTasks(prev =>
           prev.map(t =>
             t.id === createResult.id
-              ? { ...t, status: 'failed', errorMessage: completedTask.error_message || '生成失败' }
+              ? { ...t, status: 'failed', errorMessage: completedTask.error_message || (completedTask.status === 'cancelled' ? '任务已取消' : '生成失败') }
               : t
           )
         );
@@ -330,7 +359,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
             <div className="space-y-4">
               <div className="border-b border-white/5 pb-3">
                 <p className="text-[10px] text-gray-500 font-bold uppercase tracking-widest mb-1">账户 ID</p>
-                <p className="text-sm text-white font-medium">{user.phone.replace(/(\d{3})\d{4}(\d{4})/, '$1****$2')}</p>
+                <p className="text-sm text-white font-medium">{user.phone ? user.phone.replace(/(\d{3})\d{4}(\d{4})$/, '$1****$2') : user.email}</p>
               </div>
               <div className="flex justify-between items-end">
                 <div>
@@ -439,7 +468,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
           <div className="space-y-3">
             <label className="text-sm font-medium text-gray-300">情感倾向</label>
             <div className="flex gap-1 overflow-x-auto pb-1 no-scrollbar">
-              {['VECTORS', 'SAME_AS_VOICE', 'REFERENCE_AUDIO'].map(key => {
+              {['VECTORS', 'SAME_AS_VOICE', 'REFERENCE_AUDIO', 'DESCRIPTION'].map(key => {
                 const type = EmotionType[key as keyof typeof EmotionType];
                 const isActive = project.emotionType === type;
                 return (
@@ -451,7 +480,7 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
                       isActive ? 'bg-red-600 border-red-500 text-white shadow-lg' : 'bg-gray-800 border-gray-700 text-gray-500'
                     }`}
                   >
-                    {key === 'VECTORS' ? '精细调节' : key === 'SAME_AS_VOICE' ? '保持原味' : '模仿参考'}
+                    {key === 'VECTORS' ? '精细调节' : key === 'SAME_AS_VOICE' ? '保持原味' : key === 'DESCRIPTION' ? '文字描述' : '模仿参考'}
                   </button>
                 );
               })}
@@ -581,6 +610,38 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
                 </div>
               </div>
             )}
+
+            {project.emotionType === EmotionType.DESCRIPTION && (
+              <div className="bg-gray-900/40 rounded-xl p-4 border border-white/5 space-y-3">
+                <p className="text-[11px] text-gray-400 leading-relaxed">
+                  <i className="fas fa-pen-fancy text-gray-600 mr-1.5"></i>
+                  用文字描述想要的情感和语气，例如“低声，害怕”或“whispering, scared”，无需上传情感参考音频。
+                </p>
+                <textarea
+                  rows={2}
+                  maxLength={200}
+                  disabled={isProcessing}
+                  placeholder="低声，害怕"
+                  value={project.emotionText}
+                  onChange={(e) => setProject(prev => ({ ...prev, emotionText: e.target.value }))}
+                  className="w-full bg-black/40 border border-white/5 rounded-lg p-2 text-xs text-gray-200 placeholder-gray-600 focus:outline-none focus:border-red-500/50 resize-none disabled:opacity-30"
+                />
+                <div className="pt-2 border-t border-white/5 space-y-1">
+                  <div className="flex justify-between text-[10px]">
+                    <span className="text-gray-400 font-medium">情感强度</span>
+                    <span className="text-red-400 font-mono">{project.emotionAlpha.toFixed(2)}</span>
+                  </div>
+                  <input
+                    type="range" min="0" max="1" step="0.01"
+                    disabled={isProcessing}
+                    value={project.emotionAlpha}
+                    onChange={(e) => setProject(prev => ({ ...prev, emotionAlpha: parseFloat(e.target.value) }))}
+                    className="w-full h-1.5 bg-gray-700 rounded-lg appearance-none cursor-pointer accent-red-500 disabled:opacity-30"
+                  />
+                  <p className="text-[10px] text-gray-600 pt-1">建议 0.6 左右，过高可能影响发音自然度</p>
+                </div>
+              </div>
+            )}
           </div>
 
           <button
@@ -603,6 +664,17 @@ const VoiceStudio: React.FC<VoiceStudioProps> = ({ user, onUserUpdate }) => {
               </>
             )}
           </button>
+
+          {queueStats && (
+            <p className="text-xs text-gray-500 text-center">
+              <i className="fas fa-stream mr-2"></i>
+              {queueStats.paused
+                ? '队列维护中，新任务将在恢复后处理'
+                : queueStats.pending === 0
+                  ? '当前无需排队'
+                  : `当前 ${queueStats.pending} 个任务排队，预计等待约 ${Math.max(1, Math.ceil(queueStats.estimated_wait_seconds / 60))} 分钟`}
+            </p>
+          )}
         </div>
 
         <div className="glass-morphism rounded-3xl p-8 shadow-2xl flex flex-col h-[650px]">
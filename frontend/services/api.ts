@@ -4,16 +4,21 @@ const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080
 
 // Token 存储 key
 const TOKEN_KEY = 'voxclone_token';
+const REFRESH_TOKEN_KEY = 'voxclone_refresh_token';
 const USER_KEY = 'voxclone_user';
 
 // User 类型定义
 export interface User {
   id: string;
-  phone: string;
+  phone?: string;
+  email?: string;
+  email_verified_at?: string;
   nickname?: string;
   avatar?: string;
   credits: number;
   status: 'active' | 'disabled';
+  role: 'user' | 'admin' | 'support' | 'billing';
+  plan_id?: string;
   last_login_at?: string;
   created_at: string;
   updated_at: string;
@@ -22,6 +27,10 @@ export interface User {
 // 登录响应
 export interface LoginResponse {
   token: string;
+  refresh_token: string;
+  expires_in: number;
+  refresh_expires_at: string;
+  session_id: string;
   user: User;
 }
 
@@ -43,6 +52,18 @@ export const removeToken = (): void => {
   localStorage.removeItem(TOKEN_KEY);
 };
 
+export const getRefreshToken = (): string | null => {
+  return localStorage.getItem(REFRESH_TOKEN_KEY);
+};
+
+export const setRefreshToken = (token: string): void => {
+  localStorage.setItem(REFRESH_TOKEN_KEY, token);
+};
+
+export const removeRefreshToken = (): void => {
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
+};
+
 // User 缓存管理
 export const getCachedUser = (): User | null => {
   const userStr = localStorage.getItem(USER_KEY);
@@ -67,13 +88,57 @@ export const removeCachedUser = (): void => {
 // 清除所有认证数据
 export const clearAuth = (): void => {
   removeToken();
+  removeRefreshToken();
   removeCachedUser();
 };
 
+// 保存登录/刷新返回的令牌
+const saveTokens = (response: LoginResponse): void => {
+  setToken(response.token);
+  setRefreshToken(response.refresh_token);
+  setCachedUser(response.user);
+};
+
+// 使用刷新令牌换取新的访问令牌（刷新令牌同时轮换）
+let refreshPromise: Promise<boolean> | null = null;
+
+export async function refreshAccessToken(): Promise<boolean> {
+  const refreshToken = getRefreshToken();
+  if (!refreshToken) {
+    return false;
+  }
+
+  // 并发请求共享同一次刷新
+  if (!refreshPromise) {
+    refreshPromise = (async () => {
+      try {
+        const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ refresh_token: refreshToken }),
+        });
+        if (!response.ok) {
+          clearAuth();
+          return false;
+        }
+        saveTokens(await response.json());
+        return true;
+      } catch {
+        return false;
+      } finally {
+        refreshPromise = null;
+      }
+    })();
+  }
+
+  return refreshPromise;
+}
+
 // 通用请求函数
 async function request<T>(
   endpoint: string,
-  options: RequestInit = {}
+  options: RequestInit = {},
+  retry: boolean = true
 ): Promise<T> {
   const token = getToken();
 
@@ -91,6 +156,11 @@ async function request<T>(
     headers,
   });
 
+  // 访问令牌过期时刷新后重试一次
+  if (response.status === 401 && retry && token && (await refreshAccessToken())) {
+    return request<T>(endpoint, options, false);
+  }
+
   const data = await response.json();
 
   if (!response.ok) {
@@ -100,25 +170,102 @@ async function request<T>(
   return data as T;
 }
 
-// 发送验证码
-export async function sendVerificationCode(phone: string): Promise<void> {
+// 发送验证码 (countryCode 为国际区号, 如 '86')
+export async function sendVerificationCode(phone: string, countryCode = '86'): Promise<void> {
   await request<{ message: string }>('/auth/send-code', {
     method: 'POST',
-    body: JSON.stringify({ phone }),
+    body: JSON.stringify({ phone, country_code: countryCode }),
   });
 }
 
 // 登录
-export async function login(phone: string, code: string): Promise<LoginResponse> {
+export async function login(phone: string, code: string, countryCode = '86'): Promise<LoginResponse> {
   const response = await request<LoginResponse>('/auth/login', {
     method: 'POST',
-    body: JSON.stringify({ phone, code }),
+    body: JSON.stringify({ phone, code, country_code: countryCode }),
   });
 
   // 保存 token 和 user
-  setToken(response.token);
-  setCachedUser(response.user);
+  saveTokens(response);
+
+  return response;
+}
+
+// 邮箱 + 密码注册 (发送验证邮件)
+export async function registerWithEmail(email: string, password: string): Promise<void> {
+  await request<{ message: string }>('/auth/email/register', {
+    method: 'POST',
+    body: JSON.stringify({ email, password }),
+  });
+}
+
+// 邮箱 + 密码登录
+export async function loginWithEmail(email: string, password: string): Promise<LoginResponse> {
+  const response = await request<LoginResponse>('/auth/email/login', {
+    method: 'POST',
+    body: JSON.stringify({ email, password }),
+  });
+  saveTokens(response);
+  return response;
+}
+
+// 发送免密登录链接
+export async function sendMagicLink(email: string): Promise<void> {
+  await request<{ message: string }>('/auth/email/magic-link', {
+    method: 'POST',
+    body: JSON.stringify({ email }),
+  });
+}
+
+// 使用邮件链接中的 token 完成注册/绑定/免密登录
+export async function verifyEmailToken(token: string): Promise<LoginResponse> {
+  const response = await request<LoginResponse>('/auth/email/verify', {
+    method: 'POST',
+    body: JSON.stringify({ token }),
+  });
+  saveTokens(response);
+  return response;
+}
+
+// 发送重置密码邮件
+export async function forgotPassword(email: string): Promise<void> {
+  await request<{ message: string }>('/auth/password/forgot', {
+    method: 'POST',
+    body: JSON.stringify({ email }),
+  });
+}
+
+// 使用重置链接设置新密码
+export async function resetPassword(token: string, password: string): Promise<void> {
+  await request<{ message: string }>('/auth/password/reset', {
+    method: 'POST',
+    body: JSON.stringify({ token, password }),
+  });
+}
+
+// 第三方登录服务商
+export interface OAuthProvider {
+  name: string;
+  display_name: string;
+}
 
+export async function getOAuthProviders(): Promise<OAuthProvider[]> {
+  const response = await request<{ providers: OAuthProvider[] }>('/auth/oauth/providers');
+  return response.providers;
+}
+
+// 第三方登录入口地址 (浏览器直接跳转)
+export function getOAuthStartURL(provider: string): string {
+  return `${API_BASE_URL}/auth/oauth/${encodeURIComponent(provider)}/start`;
+}
+
+// 使用第三方登录回调中的一次性 code 换取登录态
+export async function exchangeOAuthCode(code: string): Promise<LoginResponse> {
+  const response = await request<LoginResponse>('/auth/oauth/exchange', {
+    method: 'POST',
+    body: JSON.stringify({ code }),
+  });
+  saveTokens(response);
   return response;
 }
 
@@ -127,9 +274,17 @@ export async function getCurrentUser(): Promise<User> {
   return request<User>('/auth/me');
 }
 
-// 登出
-export function logout(): void {
-  clearAuth();
+// 登出（撤销服务端会话）
+export async function logout(): Promise<void> {
+  try {
+    if (getToken()) {
+      await request<{ message: string }>('/auth/logout', { method: 'POST' });
+    }
+  } catch {
+    // 会话可能已失效，忽略错误
+  } finally {
+    clearAuth();
+  }
 }
 
 // 检查是否已登录
@@ -6,7 +6,10 @@ import { UploadResponse, FileUrlResponse } from '../types';
 const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080/api/v1';
 
 // 上传音频文件
-export async function uploadAudioFile(file: File): Promise<UploadResponse> {
+export async function uploadAudioFile(
+  file: File,
+  kind: 'reference' | 'emotion_prompt' = 'reference'
+): Promise<UploadResponse> {
   const token = getToken();
   if (!token) {
     throw new Error('未登录');
@@ -14,6 +17,7 @@ export async function uploadAudioFile(file: File): Promise<UploadResponse> {
 
   const formData = new FormData();
   formData.append('file', file);
+  formData.append('kind', kind);
 
   const response = await fetch(`${API_BASE_URL}/upload`, {
     method: 'POST',
@@ -4,9 +4,11 @@ import { getToken } from './api';
 import {
   CreateTaskRequest,
   CreateTaskResponse,
+  CreateScriptTaskRequest,
   TaskResponse,
   TaskListResponse,
   BackendTaskStatus,
+  QueueStats,
 } from '../types';
 
 const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080/api/v1';
@@ -49,6 +51,14 @@ export async function createTask(req: CreateTaskRequest): Promise<CreateTaskResp
   });
 }
 
+// 创建多角色剧本任务
+export async function createScriptTask(req: CreateScriptTaskRequest): Promise<CreateTaskResponse> {
+  return request<CreateTaskResponse>('/tasks/script', {
+    method: 'POST',
+    body: JSON.stringify(req),
+  });
+}
+
 // 获取任务详情
 export async function getTask(taskId: string): Promise<TaskResponse> {
   return request<TaskResponse>(`/tasks/${taskId}`);
@@ -69,6 +79,24 @@ export async function getTasks(params: {
   return request<TaskListResponse>(`/tasks${query ? `?${query}` : ''}`);
 }
 
+// 修改任务执行时间，runAt 为 null 时立即排队
+export async function rescheduleTask(taskId: string, runAt: string | null): Promise<{ id: string; status: BackendTaskStatus; run_at?: string }> {
+  return request(`/tasks/${taskId}/schedule`, {
+    method: 'PUT',
+    body: JSON.stringify({ run_at: runAt }),
+  });
+}
+
+// 获取队列状态（无需登录）
+export async function getQueueStats(): Promise<QueueStats> {
+  const response = await fetch(`${API_BASE_URL}/queue/stats`);
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.error || '请求失败');
+  }
+  return data as QueueStats;
+}
+
 // 轮询任务状态直到完成
 export async function pollTaskUntilDone(
   taskId: string,
@@ -93,7 +121,7 @@ export async function pollTaskUntilDone(
       onStatusChange(task.status);
     }
 
-    if (task.status === 'completed' || task.status === 'failed') {
+    if (task.status === 'completed' || task.status === 'failed' || task.status === 'cancelled') {
       return task;
     }
 
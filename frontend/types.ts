@@ -2,17 +2,19 @@
 export enum EmotionType {
   SAME_AS_VOICE = 'same_as_voice',
   VECTORS = 'emotion_vectors',
-  REFERENCE_AUDIO = 'reference_audio'
+  REFERENCE_AUDIO = 'reference_audio',
+  DESCRIPTION = 'description'
 }
 
 // 后端情感模式（API 传输用）
-export type EmotionMode = 'same_as_reference' | 'emotion_prompt' | 'emotion_vector' | 'emotion_text';
+export type EmotionMode = 'same_as_reference' | 'emotion_prompt' | 'emotion_vector' | 'emotion_text' | 'emotion_description';
 
 // 前端到后端情感类型映射
 export const emotionTypeToMode: Record<EmotionType, EmotionMode> = {
   [EmotionType.SAME_AS_VOICE]: 'same_as_reference',
   [EmotionType.VECTORS]: 'emotion_vector',
   [EmotionType.REFERENCE_AUDIO]: 'emotion_prompt',
+  [EmotionType.DESCRIPTION]: 'emotion_description',
 };
 
 export interface EmotionVectors {
@@ -32,6 +34,7 @@ export interface VoiceProject {
   emotionType: EmotionType;      // 情感控制模式
   emotionVectors: EmotionVectors; // 8维情感向量（仅 VECTORS 模式使用）
   emotionReference: string | null; // Base64 格式的情感参考音频（仅 REFERENCE_AUDIO 模式使用）
+  emotionText: string;             // 情感描述，如“低声，害怕”（仅 DESCRIPTION 模式使用）
   /**
    * 情感强度/混合系数 (0-1)
    * - 在 VECTORS 模式下：控制情感向量对生成语音的影响强度
@@ -61,7 +64,19 @@ export interface GenerationResult {
 // ========== 后端 API 类型 ==========
 
 // 后端任务状态
-export type BackendTaskStatus = 'pending' | 'processing' | 'completed' | 'failed';
+export type BackendTaskStatus = 'scheduled' | 'pending' | 'processing' | 'completed' | 'failed' | 'cancelled';
+
+// 高级生成参数（可用参数由套餐决定，不传使用推理引擎默认值）
+export interface AdvancedParams {
+  temperature?: number;                 // 0.1-2，默认 0.8
+  top_p?: number;                       // 0-1，默认 0.8
+  top_k?: number;                       // 0-100，默认 30
+  num_beams?: number;                   // 1-10，默认 3
+  repetition_penalty?: number;          // 0.1-20，默认 10
+  length_penalty?: number;              // -2-2，默认 0
+  max_mel_tokens?: number;              // 50-1815，默认 1500
+  max_text_tokens_per_segment?: number; // 20-600，默认 120
+}
 
 // 创建任务请求
 export interface CreateTaskRequest {
@@ -71,12 +86,57 @@ export interface CreateTaskRequest {
   emotion_prompt_file_id?: string;
   emotion_vector?: number[];
   emotion_alpha?: number;
+  emotion_text?: string; // 情感描述，emotion_mode 为 emotion_description 时必填
+  run_at?: string; // 定时执行时间（RFC 3339），不传则立即排队
+  advanced?: AdvancedParams;
+}
+
+// 剧本台词
+export interface ScriptLineRequest {
+  speaker: string;               // speakers 中的角色名
+  text: string;                  // 最多 1000 字
+  emotion_mode?: EmotionMode;    // 默认 same_as_reference
+  emotion_prompt_file_id?: string;
+  emotion_vector?: number[];
+  emotion_alpha?: number;
+  emotion_text?: string;
+  pause_after_ms?: number;       // 该台词之后的静音，0-10000 毫秒
+}
+
+// 创建多角色剧本任务请求，按台词条数消耗积分
+export interface CreateScriptTaskRequest {
+  speakers: Record<string, string>; // 角色名 -> 参考音频文件 ID，1-10 个
+  lines: ScriptLineRequest[];       // 1-100 条
+  run_at?: string;
+  advanced?: AdvancedParams;        // 作用于所有台词
+}
+
+// 任务类型
+export type TaskType = 'single' | 'script';
+
+// 剧本任务的台词及其结果
+export interface TaskLine {
+  id: string;
+  position: number;
+  speaker: string;
+  text: string;
+  reference_audio_file_id: string;
+  emotion_mode: EmotionMode;
+  emotion_prompt_file_id?: string;
+  emotion_vector?: string;
+  emotion_alpha?: number;
+  emotion_text?: string;
+  pause_after_ms: number;
+  stem_audio_file_id?: string; // 该台词单独的音频，任务完成后返回
+  start_ms?: number;           // 在完整音频中的起止时间
+  end_ms?: number;
 }
 
 // 创建任务响应
 export interface CreateTaskResponse {
   id: string;
   status: BackendTaskStatus;
+  run_at?: string;
   created_at: string;
 }
 
@@ -84,14 +144,23 @@ export interface CreateTaskResponse {
 export interface TaskResponse {
   id: string;
   status: BackendTaskStatus;
+  type: TaskType;
+  priority: number;
+  run_at?: string;
+  queue_position?: number;      // 排队中任务的估算位置，1 表示下一个处理
+  estimated_start_at?: string;  // 预计开始时间，队列暂停时不返回
+  estimated_finish_at?: string; // 预计完成时间，队列暂停时不返回
   text: string;
   reference_audio_file_id: string;
   emotion_mode: EmotionMode;
   emotion_prompt_file_id?: string;
   emotion_vector?: string;
   emotion_alpha?: number;
+  emotion_text?: string;
+  advanced?: AdvancedParams;
   result_audio_file_id?: string;
   error_message?: string;
+  lines?: TaskLine[]; // 仅剧本任务
   created_at: string;
   updated_at: string;
 }
@@ -100,11 +169,16 @@ export interface TaskResponse {
 export interface TaskListItem {
   id: string;
   status: BackendTaskStatus;
+  type: TaskType;
   text: string;
   result_audio_file_id?: string;
   error_message?: string;
   created_at: string;
   updated_at: string;
+  run_at?: string;
+  queue_position?: number;
+  estimated_start_at?: string;
+  estimated_finish_at?: string;
 }
 
 // 任务列表响应
@@ -115,6 +189,15 @@ export interface TaskListResponse {
   page_size: number;
 }
 
+// 队列状态（公开接口）
+export interface QueueStats {
+  pending: number;
+  processing: number;
+  paused: boolean;
+  avg_seconds_per_char: number;
+  estimated_wait_seconds: number;
+}
+
 // 文件上传响应
 export interface UploadResponse {
   id: string;